@@ -0,0 +1,123 @@
+// Package ordersconv converts between the orders.v1 messages and the
+// models the services use. The Order Service's gRPC server and the proxy's
+// gRPC client both use it, so the two ends map fields the same way.
+package ordersconv
+
+import (
+	"time"
+
+	ordersv1 "github.com/jogardn/strangler-demo/api/orders/v1"
+	"github.com/jogardn/strangler-demo/pkg/models"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func OrderFromProto(in *ordersv1.Order) *models.Order {
+	order := &models.Order{
+		ID:           in.GetId(),
+		CustomerID:   in.GetCustomerId(),
+		Items:        []models.OrderItem{},
+		TotalAmount:  models.Money(in.GetTotalAmountCents()),
+		Currency:     in.GetCurrency(),
+		DeliveryDate: TimeFromProto(in.GetDeliveryDate()),
+		Status:       in.GetStatus(),
+		CreatedAt:    TimeFromProto(in.GetCreatedAt()),
+		Version:      int(in.GetVersion()),
+	}
+	for _, item := range in.GetItems() {
+		order.Items = append(order.Items, models.OrderItem{
+			ProductID:      item.GetProductId(),
+			Quantity:       int(item.GetQuantity()),
+			UnitPrice:      models.Money(item.GetUnitPriceCents()),
+			Specifications: item.GetSpecifications(),
+		})
+	}
+	if c := in.GetCancellation(); c != nil {
+		order.Cancellation = &models.Cancellation{
+			ReasonCode:  c.GetReasonCode(),
+			Note:        c.GetNote(),
+			State:       c.GetState(),
+			Message:     c.GetMessage(),
+			RequestedAt: TimeFromProto(c.GetRequestedAt()),
+		}
+	}
+	return order
+}
+
+func OrderToProto(order *models.Order) *ordersv1.Order {
+	out := &ordersv1.Order{
+		Id:               order.ID,
+		CustomerId:       order.CustomerID,
+		TotalAmountCents: int64(order.TotalAmount),
+		Currency:         order.Currency,
+		DeliveryDate:     TimeToProto(order.DeliveryDate),
+		Status:           order.Status,
+		CreatedAt:        TimeToProto(order.CreatedAt),
+		Version:          int32(order.Version),
+	}
+	for _, item := range order.Items {
+		out.Items = append(out.Items, &ordersv1.OrderItem{
+			ProductId:      item.ProductID,
+			Quantity:       int32(item.Quantity),
+			UnitPriceCents: int64(item.UnitPrice),
+			Specifications: item.Specifications,
+		})
+	}
+	if c := order.Cancellation; c != nil {
+		out.Cancellation = &ordersv1.Cancellation{
+			ReasonCode:  c.ReasonCode,
+			Note:        c.Note,
+			State:       c.State,
+			Message:     c.Message,
+			RequestedAt: TimeToProto(c.RequestedAt),
+		}
+	}
+	return out
+}
+
+func QueryFromProto(req *ordersv1.ListOrdersRequest) models.OrderQuery {
+	return models.OrderQuery{
+		CustomerID:     req.GetCustomerId(),
+		Status:         req.GetStatus(),
+		CreatedFrom:    TimeFromProto(req.GetCreatedFrom()),
+		CreatedTo:      TimeFromProto(req.GetCreatedTo()),
+		DeliveryFrom:   TimeFromProto(req.GetDeliveryFrom()),
+		DeliveryTo:     TimeFromProto(req.GetDeliveryTo()),
+		ProductID:      req.GetProductId(),
+		Specifications: req.GetSpecifications(),
+		Sort:           req.GetSort(),
+		Limit:          int(req.GetLimit()),
+		Cursor:         req.GetCursor(),
+	}
+}
+
+func QueryToProto(query models.OrderQuery) *ordersv1.ListOrdersRequest {
+	return &ordersv1.ListOrdersRequest{
+		CustomerId:     query.CustomerID,
+		Status:         query.Status,
+		CreatedFrom:    TimeToProto(query.CreatedFrom),
+		CreatedTo:      TimeToProto(query.CreatedTo),
+		DeliveryFrom:   TimeToProto(query.DeliveryFrom),
+		DeliveryTo:     TimeToProto(query.DeliveryTo),
+		ProductId:      query.ProductID,
+		Specifications: query.Specifications,
+		Sort:           query.Sort,
+		Limit:          int32(query.Limit),
+		Cursor:         query.Cursor,
+	}
+}
+
+// TimeFromProto reads an unset timestamp as the zero time.
+func TimeFromProto(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+// TimeToProto leaves the zero time unset.
+func TimeToProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
@@ -0,0 +1,50 @@
+package ordersconv
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jogardn/strangler-demo/pkg/models"
+)
+
+func TestOrderRoundTrip(t *testing.T) {
+	created := time.Date(2025, 6, 1, 10, 30, 0, 0, time.UTC)
+	order := &models.Order{
+		ID:         "order-1",
+		CustomerID: "customer-1",
+		Items: []models.OrderItem{
+			{ProductID: "P-1", Quantity: 2, UnitPrice: 1299, Specifications: map[string]string{"color": "red"}},
+		},
+		TotalAmount:  2598,
+		Currency:     "EUR",
+		DeliveryDate: created.AddDate(0, 0, 14),
+		Status:       models.StatusPending,
+		CreatedAt:    created,
+		Version:      3,
+		Cancellation: &models.Cancellation{ReasonCode: "customer_request", State: "requested", RequestedAt: created.Add(time.Hour)},
+	}
+
+	if got := OrderFromProto(OrderToProto(order)); !reflect.DeepEqual(got, order) {
+		t.Errorf("Expected %+v back, got %+v", order, got)
+	}
+}
+
+func TestQueryRoundTrip(t *testing.T) {
+	query := models.OrderQuery{
+		CustomerID:     "customer-1",
+		CreatedFrom:    time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		Specifications: map[string]string{"color": "red"},
+		Sort:           "-created_at",
+		Limit:          20,
+		Cursor:         "abc",
+	}
+
+	got := QueryFromProto(QueryToProto(query))
+	if !reflect.DeepEqual(got, query) {
+		t.Errorf("Expected %+v back, got %+v", query, got)
+	}
+	if !got.CreatedTo.IsZero() || QueryToProto(query).GetCreatedTo() != nil {
+		t.Errorf("Expected an open bound to stay unset, got %v", got.CreatedTo)
+	}
+}
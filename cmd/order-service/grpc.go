@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	ordersv1 "github.com/jogardn/strangler-demo/api/orders/v1"
+	"github.com/jogardn/strangler-demo/api/orders/v1/ordersconv"
+	"github.com/jogardn/strangler-demo/internal/repository"
+	"github.com/jogardn/strangler-demo/pkg/models"
+	"github.com/jogardn/strangler-demo/pkg/validation"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcServer serves ordersv1.OrderService for internal consumers. It stores
+// orders through the same code as the REST handlers, so both APIs validate,
+// audit and publish orders alike.
+type grpcServer struct {
+	ordersv1.UnimplementedOrderServiceServer
+	service *OrderService
+}
+
+func newGRPCServer(service *OrderService) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(unaryLoggingInterceptor(service.logger)),
+		grpc.StreamInterceptor(streamLoggingInterceptor(service.logger)),
+	)
+	ordersv1.RegisterOrderServiceServer(server, &grpcServer{service: service})
+	return server
+}
+
+func (g *grpcServer) CreateOrder(ctx context.Context, req *ordersv1.CreateOrderRequest) (*ordersv1.CreateOrderResponse, error) {
+	if req.GetOrder() == nil {
+		return nil, status.Error(codes.InvalidArgument, "order is required")
+	}
+	order := ordersconv.OrderFromProto(req.GetOrder())
+	order.IdempotencyKey = req.GetIdempotencyKey()
+
+	source := models.AuditSourceGRPC
+	if req.GetSource() == models.AuditSourceProxy {
+		source = models.AuditSourceProxy
+	}
+	audit := repository.Audit{Actor: grpcActor(req.GetActor()), Source: source}
+	return createOrderResponse(order, g.service.createOrder(order, audit))
+}
+
+func (g *grpcServer) CreateHistoricalOrder(ctx context.Context, req *ordersv1.CreateHistoricalOrderRequest) (*ordersv1.CreateOrderResponse, error) {
+	if req.GetOrder() == nil {
+		return nil, status.Error(codes.InvalidArgument, "order is required")
+	}
+	source, err := models.ParseImportSource(req.GetSource())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	order := ordersconv.OrderFromProto(req.GetOrder())
+	order.IdempotencyKey = req.GetIdempotencyKey()
+
+	audit := repository.Audit{Actor: grpcActor(req.GetActor()), Source: source}
+	return createOrderResponse(order, g.service.createHistoricalOrder(order, audit))
+}
+
+func (g *grpcServer) GetOrder(ctx context.Context, req *ordersv1.GetOrderRequest) (*ordersv1.Order, error) {
+	order, err := g.service.repo.Get(req.GetId())
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "order not found")
+		}
+		g.service.logger.WithError(err).Error("Failed to get order")
+		return nil, status.Error(codes.Internal, "failed to get order")
+	}
+	return ordersconv.OrderToProto(order), nil
+}
+
+// ListOrders sends the orders as they are read, so the page is never held
+// in memory, and then the cursor of the next page.
+func (g *grpcServer) ListOrders(req *ordersv1.ListOrdersRequest, stream ordersv1.OrderService_ListOrdersServer) error {
+	query := ordersconv.QueryFromProto(req)
+	if err := query.Validate(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	count := 0
+	nextCursor, err := g.service.repo.List(query, func(order *models.Order) error {
+		count++
+		return stream.Send(&ordersv1.ListOrdersResponse{
+			Result: &ordersv1.ListOrdersResponse_Order{Order: ordersconv.OrderToProto(order)},
+		})
+	})
+	if err != nil {
+		if ctxErr := stream.Context().Err(); ctxErr != nil {
+			// The client went away
+			return status.FromContextError(ctxErr).Err()
+		}
+		g.service.logger.WithError(err).WithField("streamed", count).Error("Failed to get orders")
+		return status.Error(codes.Internal, "failed to get orders")
+	}
+
+	if nextCursor != "" {
+		if err := stream.Send(&ordersv1.ListOrdersResponse{
+			Result: &ordersv1.ListOrdersResponse_NextCursor{NextCursor: nextCursor},
+		}); err != nil {
+			return err
+		}
+	}
+
+	g.service.logger.WithField("count", count).Info("Retrieved orders from database")
+	return nil
+}
+
+// createOrderResponse maps the outcome of createOrder and
+// createHistoricalOrder to the gRPC answer. Like the REST API, a retry of a
+// stored order succeeds with the stored order, and a different order with
+// its idempotency key or ID is rejected.
+func createOrderResponse(order *models.Order, err error) (*ordersv1.CreateOrderResponse, error) {
+	var errs validation.Errors
+	var duplicate *repository.DuplicateOrderError
+	switch {
+	case err == nil:
+		return &ordersv1.CreateOrderResponse{Order: ordersconv.OrderToProto(order)}, nil
+	case errors.As(err, &duplicate) && duplicate.Conflict == repository.ConflictIdempotencyKey:
+		return nil, status.Error(codes.InvalidArgument, "idempotency key was already used with a different order")
+	case errors.As(err, &duplicate) && duplicate.Conflict == repository.ConflictID:
+		return nil, status.Errorf(codes.AlreadyExists, "order ID %s is already used by a different order", duplicate.Existing.ID)
+	case errors.As(err, &duplicate):
+		return &ordersv1.CreateOrderResponse{Order: ordersconv.OrderToProto(duplicate.Existing), Replayed: true}, nil
+	case errors.As(err, &errs):
+		return nil, validationStatus(errs)
+	default:
+		return nil, status.Error(codes.Internal, "failed to save order")
+	}
+}
+
+// validationStatus reports every invalid field in a BadRequest detail, the
+// counterpart of the errors list of a REST 422.
+func validationStatus(errs validation.Errors) error {
+	badRequest := &errdetails.BadRequest{}
+	for _, fieldErr := range errs {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       fieldErr.Path,
+			Reason:      fieldErr.Code,
+			Description: fieldErr.Message,
+		})
+	}
+
+	st := status.New(codes.InvalidArgument, "order validation failed")
+	if detailed, err := st.WithDetails(badRequest); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// grpcActor is the gRPC counterpart of actor.
+func grpcActor(actor string) string {
+	if actor != "" {
+		return actor
+	}
+	return models.AnonymousActor
+}
+
+func unaryLoggingInterceptor(logger *logrus.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logGRPCCall(logger, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+func streamLoggingInterceptor(logger *logrus.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, stream)
+		logGRPCCall(logger, info.FullMethod, start, err)
+		return err
+	}
+}
+
+func logGRPCCall(logger *logrus.Logger, method string, start time.Time, err error) {
+	logger.WithFields(logrus.Fields{
+		"method":   method,
+		"code":     status.Code(err).String(),
+		"duration": time.Since(start).Milliseconds(),
+	}).Info("gRPC call completed")
+}
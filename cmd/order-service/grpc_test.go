@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	ordersv1 "github.com/jogardn/strangler-demo/api/orders/v1"
+	"github.com/jogardn/strangler-demo/api/orders/v1/ordersconv"
+	"github.com/jogardn/strangler-demo/internal/events"
+	"github.com/jogardn/strangler-demo/pkg/models"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newGRPCClient serves the service's gRPC API in memory.
+func newGRPCClient(t *testing.T, s *OrderService) ordersv1.OrderServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	server := newGRPCServer(s)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial gRPC server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return ordersv1.NewOrderServiceClient(conn)
+}
+
+func testProtoOrder(id string) *ordersv1.Order {
+	order := testOrder()
+	order.ID = id
+	return ordersconv.OrderToProto(&order)
+}
+
+func TestGRPCCreateOrderSharesRESTPersistence(t *testing.T) {
+	s, repo := newTestService()
+	client := newGRPCClient(t, s)
+
+	resp, err := client.CreateOrder(context.Background(), &ordersv1.CreateOrderRequest{
+		Order:          testProtoOrder("order-1"),
+		IdempotencyKey: "key-1",
+		Actor:          "alice",
+		Source:         models.AuditSourceProxy,
+	})
+	if err != nil || resp.GetReplayed() || resp.GetOrder().GetCurrency() != models.DefaultCurrency || resp.GetOrder().GetTotalAmountCents() != 25990 {
+		t.Fatalf("Expected the stored order, got %+v (%v)", resp, err)
+	}
+	published := repo.Events()
+	if len(published) != 1 || published[0].Topic != events.OrderCreatedTopic {
+		t.Errorf("Expected an order created event, got %+v", published)
+	}
+
+	// The order is the same one the REST API serves
+	if rec := serve(s, "GET", "/orders/order-1", nil, nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected the order to be served over REST, got %d", rec.Code)
+	}
+	history, _ := repo.History("order-1")
+	if len(history) != 1 || history[0].Actor != "alice" || history[0].Source != models.AuditSourceProxy {
+		t.Errorf("Expected the creation by alice in the audit trail, got %+v", history)
+	}
+
+	retry, err := client.CreateOrder(context.Background(), &ordersv1.CreateOrderRequest{
+		Order:          testProtoOrder("order-2"),
+		IdempotencyKey: "key-1",
+	})
+	if err != nil || !retry.GetReplayed() || retry.GetOrder().GetId() != "order-1" {
+		t.Errorf("Expected the retry to replay the stored order, got %+v (%v)", retry, err)
+	}
+
+	changed := testProtoOrder("order-2")
+	changed.CustomerId = "customer-2"
+	_, err = client.CreateOrder(context.Background(), &ordersv1.CreateOrderRequest{Order: changed, IdempotencyKey: "key-1"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected a reused key with a different order to be rejected, got %v", err)
+	}
+	changed.Id = "order-1"
+	_, err = client.CreateOrder(context.Background(), &ordersv1.CreateOrderRequest{Order: changed})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("Expected a taken ID to be rejected, got %v", err)
+	}
+	if len(repo.Events()) != 1 {
+		t.Errorf("Expected a single event, got %d", len(repo.Events()))
+	}
+
+	if _, err := client.CreateOrder(context.Background(), &ordersv1.CreateOrderRequest{Order: testProtoOrder("order-3")}); err != nil {
+		t.Fatalf("CreateOrder failed: %v", err)
+	}
+	if history, _ := repo.History("order-3"); len(history) != 1 || history[0].Source != models.AuditSourceGRPC {
+		t.Errorf("Expected a create that did not come from the proxy to be recorded as gRPC, got %+v", history)
+	}
+}
+
+func TestGRPCCreateOrderRejectsInvalidOrder(t *testing.T) {
+	s, repo := newTestService()
+	client := newGRPCClient(t, s)
+
+	order := testProtoOrder("order-1")
+	order.Items[0].Quantity = 0
+	_, err := client.CreateOrder(context.Background(), &ordersv1.CreateOrderRequest{Order: order})
+
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("Expected INVALID_ARGUMENT, got %v", err)
+	}
+	var fields []string
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.GetFieldViolations() {
+				fields = append(fields, violation.GetField())
+			}
+		}
+	}
+	if len(fields) == 0 || fields[0] != "items[0].quantity" {
+		t.Errorf("Expected the invalid quantity to be reported, got %v", fields)
+	}
+	if len(repo.Events()) != 0 {
+		t.Errorf("Expected nothing to be published, got %+v", repo.Events())
+	}
+
+	if _, err := client.CreateOrder(context.Background(), &ordersv1.CreateOrderRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected INVALID_ARGUMENT without an order, got %v", err)
+	}
+}
+
+func TestGRPCCreateHistoricalOrder(t *testing.T) {
+	s, repo := newTestService()
+	client := newGRPCClient(t, s)
+
+	_, err := client.CreateHistoricalOrder(context.Background(), &ordersv1.CreateHistoricalOrderRequest{
+		Order:  testProtoOrder("order-1"),
+		Source: models.AuditSourceMigration,
+	})
+	if err != nil {
+		t.Fatalf("Expected the historical order to be stored, got %v", err)
+	}
+	if len(repo.Events()) != 0 {
+		t.Errorf("Expected no events for a historical order, got %+v", repo.Events())
+	}
+	history, _ := repo.History("order-1")
+	if len(history) != 1 || history[0].Source != models.AuditSourceMigration || history[0].Actor != models.AnonymousActor {
+		t.Errorf("Expected an anonymous migration in the audit trail, got %+v", history)
+	}
+
+	_, err = client.CreateHistoricalOrder(context.Background(), &ordersv1.CreateHistoricalOrderRequest{
+		Order:  testProtoOrder("order-2"),
+		Source: models.AuditSourceProxy,
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected INVALID_ARGUMENT for a live source, got %v", err)
+	}
+}
+
+func TestGRPCGetOrder(t *testing.T) {
+	s, _ := newTestService()
+	client := newGRPCClient(t, s)
+	serve(s, "POST", "/orders", testOrder(), nil)
+
+	order, err := client.GetOrder(context.Background(), &ordersv1.GetOrderRequest{Id: "order-1"})
+	if err != nil || order.GetId() != "order-1" || len(order.GetItems()) != 1 || order.GetVersion() != 1 {
+		t.Errorf("Expected the order created over REST, got %+v (%v)", order, err)
+	}
+	if _, err := client.GetOrder(context.Background(), &ordersv1.GetOrderRequest{Id: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NOT_FOUND, got %v", err)
+	}
+}
+
+// listOrders reads a whole ListOrders stream.
+func listOrders(t *testing.T, client ordersv1.OrderServiceClient, req *ordersv1.ListOrdersRequest) ([]*ordersv1.Order, string, error) {
+	t.Helper()
+	stream, err := client.ListOrders(context.Background(), req)
+	if err != nil {
+		return nil, "", err
+	}
+	var orders []*ordersv1.Order
+	var nextCursor string
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return orders, nextCursor, nil
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if order := resp.GetOrder(); order != nil {
+			orders = append(orders, order)
+		} else {
+			nextCursor = resp.GetNextCursor()
+		}
+	}
+}
+
+func TestGRPCListOrdersStreamsPages(t *testing.T) {
+	s, _ := newTestService()
+	client := newGRPCClient(t, s)
+	for _, id := range []string{"order-1", "order-2", "order-3"} {
+		order := testOrder()
+		order.ID = id
+		serve(s, "POST", "/orders", order, nil)
+	}
+
+	orders, cursor, err := listOrders(t, client, &ordersv1.ListOrdersRequest{Limit: 2})
+	if err != nil || len(orders) != 2 || cursor == "" {
+		t.Fatalf("Expected a first page of 2 with a cursor, got %d orders, cursor %q (%v)", len(orders), cursor, err)
+	}
+	rest, cursor, err := listOrders(t, client, &ordersv1.ListOrdersRequest{Limit: 2, Cursor: cursor})
+	if err != nil || len(rest) != 1 || cursor != "" {
+		t.Errorf("Expected a last page of 1 without a cursor, got %d orders, cursor %q (%v)", len(rest), cursor, err)
+	}
+
+	if _, _, err := listOrders(t, client, &ordersv1.ListOrdersRequest{Status: "lost"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected INVALID_ARGUMENT for an unknown status, got %v", err)
+	}
+}
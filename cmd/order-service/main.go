@@ -4,24 +4,49 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jogardn/strangler-demo/internal/events"
+	"github.com/jogardn/strangler-demo/internal/migrations"
+	"github.com/jogardn/strangler-demo/internal/outbox"
+	"github.com/jogardn/strangler-demo/internal/repository"
+	"github.com/jogardn/strangler-demo/internal/sap"
 	"github.com/jogardn/strangler-demo/pkg/models"
+	"github.com/jogardn/strangler-demo/pkg/validation"
 	_ "github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	idempotencyKeyHeader   = "Idempotency-Key"
+	idempotentReplayHeader = "Idempotent-Replayed"
+	ifMatchHeader          = "If-Match"
+	etagHeader             = "ETag"
+	actorHeader            = "X-Actor"
+	changeSourceHeader     = "X-Change-Source"
+)
+
+// sapAudit is recorded for changes applied from SAP's answers.
+var sapAudit = repository.Audit{Actor: "sap", Source: models.AuditSourceSAP}
+
 type OrderService struct {
-	db       *sql.DB
-	logger   *logrus.Logger
-	producer *events.KafkaProducer
+	repo   repository.OrderRepository
+	logger *logrus.Logger
+	// outbox publishes the events the repository writes with each change
+	outbox *outbox.Relay
+	// converter adds SAP's company code amount to events. Without a rate
+	// table, SAP converts on its own
+	converter *sap.Converter
 }
 
 func main() {
@@ -41,6 +66,7 @@ func main() {
 	
 	// Service configuration
 	port := getEnv("ORDER_SERVICE_PORT", "8081")
+	grpcPort := getEnv("ORDER_SERVICE_GRPC_PORT", "9090")
 
 	// Connect to database
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
@@ -62,9 +88,22 @@ func main() {
 		time.Sleep(2 * time.Second)
 	}
 
-	// Create tables if they don't exist
-	if err := createTables(db); err != nil {
-		logger.WithError(err).Fatal("Failed to create tables")
+	migrator, err := migrations.New(db, logger)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to load migrations")
+	}
+
+	// order-service migrate up|down [n]|status manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(migrator, os.Args[2:], os.Stdout); err != nil {
+			logger.WithError(err).Fatal("Migration command failed")
+		}
+		return
+	}
+
+	// Bring the schema up to date before serving
+	if _, err := migrator.Up(); err != nil {
+		logger.WithError(err).Fatal("Failed to migrate database")
 	}
 
 	// Initialize Kafka producer
@@ -74,20 +113,49 @@ func main() {
 	}
 	defer producer.Close()
 
+	// Relay events from the outbox to Kafka
+	relay := outbox.NewRelay(db, producer, outbox.Config{
+		PollInterval: time.Duration(getEnvInt("OUTBOX_POLL_INTERVAL_MS", 1000, logger)) * time.Millisecond,
+		BatchSize:    getEnvInt("OUTBOX_BATCH_SIZE", 100, logger),
+		MaxBackoff:   time.Duration(getEnvInt("OUTBOX_MAX_BACKOFF_SECONDS", 300, logger)) * time.Second,
+		Retention:    time.Duration(getEnvInt("OUTBOX_RETENTION_HOURS", 24, logger)) * time.Hour,
+	}, logger)
+
+	converter, err := sap.LoadConverter(getEnv("EXCHANGE_RATES_FILE", ""), getEnv("COMPANY_CODE_CURRENCY", ""))
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to load exchange rates")
+	}
+
 	// Create service
 	service := &OrderService{
-		db:       db,
-		logger:   logger,
-		producer: producer,
+		repo:      repository.NewPostgres(db),
+		logger:    logger,
+		outbox:    relay,
+		converter: converter,
 	}
 
+	// Consume SAP's answers to cancellation requests
+	consumerCtx, stopConsumer := context.WithCancel(context.Background())
+	defer stopConsumer()
+	go service.consumeCancellationResults(consumerCtx, kafkaBrokers)
+	go relay.Run(consumerCtx)
+
 	// Set up routes
 	router := mux.NewRouter()
 	router.HandleFunc("/health", service.HealthCheck).Methods("GET")
 	router.HandleFunc("/orders", service.CreateOrder).Methods("POST")
 	router.HandleFunc("/orders/historical", service.CreateOrderHistorical).Methods("POST")
+	router.HandleFunc("/orders/batch", service.CreateOrderBatch).Methods("POST")
 	router.HandleFunc("/orders", service.ListOrders).Methods("GET")
+	router.HandleFunc("/orders/search", service.SearchOrders).Methods("GET")
 	router.HandleFunc("/orders/{id}", service.GetOrder).Methods("GET")
+	router.HandleFunc("/orders/{id}", service.UpdateOrder).Methods("PUT")
+	router.HandleFunc("/orders/{id}/status", service.UpdateOrderStatus).Methods("PATCH")
+	router.HandleFunc("/orders/{id}/history", service.OrderHistory).Methods("GET")
+	router.HandleFunc("/orders/{id}/cancel", service.CancelOrder).Methods("POST")
+	router.HandleFunc("/customers/{id}/orders/summary", service.CustomerOrderSummary).Methods("GET")
+	router.HandleFunc("/analytics/orders", service.OrderAnalytics).Methods("GET")
+	router.HandleFunc("/admin/outbox", service.OutboxBacklog).Methods("GET")
 
 	// Middleware
 	router.Use(loggingMiddleware(logger))
@@ -109,12 +177,26 @@ func main() {
 		}
 	}()
 
+	// Serve the gRPC API next to the REST API
+	grpcListener, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to listen for gRPC")
+	}
+	grpcSrv := newGRPCServer(service)
+	go func() {
+		logger.WithField("port", grpcPort).Info("Starting order service gRPC API")
+		if err := grpcSrv.Serve(grpcListener); err != nil {
+			logger.WithError(err).Fatal("Failed to start gRPC server")
+		}
+	}()
+
 	// Graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	<-sigChan
 
 	logger.Info("Shutting down server...")
+	stopConsumer()
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -122,6 +204,19 @@ func main() {
 		logger.WithError(err).Error("Server forced to shutdown")
 	}
 
+	// Let gRPC calls finish within the same deadline
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcSrv.GracefulStop()
+		close(grpcStopped)
+	}()
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		logger.Error("gRPC server forced to shutdown")
+		grpcSrv.Stop()
+	}
+
 	logger.Info("Server gracefully stopped")
 }
 
@@ -132,34 +227,34 @@ func (s *OrderService) CreateOrder(w http.ResponseWriter, r *http.Request) {
 		s.respondWithError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
-
-	// Save to database
-	if err := s.saveOrder(&order); err != nil {
-		s.logger.WithError(err).Error("Failed to save order")
-		s.respondWithError(w, http.StatusInternalServerError, "Failed to save order")
+	order.IdempotencyKey = r.Header.Get(idempotencyKeyHeader)
+	if r.URL.Query().Get("dry_run") == "true" {
+		s.dryRunOrder(w, &order)
 		return
 	}
 
-	// Publish event
-	event := events.OrderCreatedEvent{
-		OrderID:     order.ID,
-		CustomerID:  order.CustomerID,
-		TotalAmount: order.TotalAmount,
-		CreatedAt:   order.CreatedAt,
-	}
-
-	if err := s.producer.PublishOrderCreated(event); err != nil {
-		s.logger.WithError(err).Error("Failed to publish order created event")
-		// Don't fail the request, just log the error
+	if err := s.createOrder(&order, liveAudit(r)); err != nil {
+		var errs validation.Errors
+		var duplicate *repository.DuplicateOrderError
+		switch {
+		case errors.As(err, &errs):
+			s.respondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"success": false,
+				"message": "Order validation failed",
+				"errors":  errs,
+			})
+		case errors.As(err, &duplicate):
+			// A retry of an order that was already stored is answered with
+			// the stored order and publishes no second event
+			s.respondWithDuplicate(w, duplicate)
+		default:
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to save order")
+		}
+		return
 	}
 
-	s.logger.WithFields(logrus.Fields{
-		"order_id":     order.ID,
-		"customer_id":  order.CustomerID,
-		"total_amount": order.TotalAmount,
-	}).Info("Order created successfully")
-
 	// Return response
+	setETag(w, &order)
 	response := models.OrderResponse{
 		Success: true,
 		Message: "Order created successfully",
@@ -176,19 +271,25 @@ func (s *OrderService) CreateOrderHistorical(w http.ResponseWriter, r *http.Requ
 		s.respondWithError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	order.IdempotencyKey = r.Header.Get(idempotencyKeyHeader)
 
-	// Save to database (no event publishing for historical orders)
-	if err := s.saveOrder(&order); err != nil {
-		s.logger.WithError(err).Error("Failed to save historical order")
-		s.respondWithError(w, http.StatusInternalServerError, "Failed to save historical order")
+	// The audit trail tells imported orders apart from live ones by their
+	// source
+	source, err := models.ParseImportSource(r.Header.Get(changeSourceHeader))
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	s.logger.WithFields(logrus.Fields{
-		"order_id":     order.ID,
-		"customer_id":  order.CustomerID,
-		"total_amount": order.TotalAmount,
-	}).Info("Historical order created successfully (no events published)")
+	if err := s.createHistoricalOrder(&order, repository.Audit{Actor: actor(r), Source: source}); err != nil {
+		var duplicate *repository.DuplicateOrderError
+		if errors.As(err, &duplicate) {
+			s.respondWithDuplicate(w, duplicate)
+			return
+		}
+		s.respondWithError(w, http.StatusInternalServerError, "Failed to save historical order")
+		return
+	}
 
 	// Return response
 	response := models.OrderResponse{
@@ -200,30 +301,249 @@ func (s *OrderService) CreateOrderHistorical(w http.ResponseWriter, r *http.Requ
 	s.respondWithJSON(w, http.StatusCreated, response)
 }
 
+// createOrder validates and stores a new order for the REST and gRPC APIs.
+// It fails with validation.Errors for an invalid order and with
+// *repository.DuplicateOrderError for a retry of a stored order.
+func (s *OrderService) createOrder(order *models.Order, audit repository.Audit) error {
+	if err := s.checkOrder(order); err != nil {
+		return err
+	}
+
+	// Save to database. The order created event is written to the outbox in
+	// the same transaction, so SAP hears about every stored order.
+	if err := s.repo.Save(order, audit, s.orderCreatedEvent(order)); err != nil {
+		var duplicate *repository.DuplicateOrderError
+		if !errors.As(err, &duplicate) {
+			s.logger.WithError(err).Error("Failed to save order")
+		}
+		return err
+	}
+	s.notifyOutbox()
+
+	s.logger.WithFields(logrus.Fields{
+		"order_id":     order.ID,
+		"customer_id":  order.CustomerID,
+		"total_amount": order.TotalAmount,
+	}).Info("Order created successfully")
+	return nil
+}
+
+// checkOrder fills in the defaults of a new order and validates it. It
+// fails with validation.Errors for an invalid order.
+func (s *OrderService) checkOrder(order *models.Order) error {
+	order.Currency = models.CurrencyOrDefault(order.Currency)
+
+	if errs := validation.ValidateOrder(order); len(errs) > 0 {
+		s.logger.WithFields(logrus.Fields{
+			"order_id": order.ID,
+			"errors":   errs.Error(),
+		}).Warn("Order failed validation")
+		return errs
+	}
+	return nil
+}
+
+// dryRunOrder answers with the order as createOrder would store it, but
+// stores nothing and publishes no event. The proxy's shadow phase compares
+// it with SAP's answer. A dry run is not checked against stored orders.
+func (s *OrderService) dryRunOrder(w http.ResponseWriter, order *models.Order) {
+	if errs := s.checkOrder(order); errs != nil {
+		s.respondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"success": false,
+			"message": "Order validation failed",
+			"errors":  errs,
+		})
+		return
+	}
+
+	order.Version = 1
+	s.respondWithJSON(w, http.StatusOK, models.OrderResponse{
+		Success: true,
+		Message: "Order is valid; dry run stored nothing",
+		Order:   order,
+	})
+}
+
+// createHistoricalOrder stores a copy of an order without validating it or
+// publishing an event. Like createOrder, it fails with
+// *repository.DuplicateOrderError for an order that is already stored.
+func (s *OrderService) createHistoricalOrder(order *models.Order, audit repository.Audit) error {
+	order.Currency = models.CurrencyOrDefault(order.Currency)
+
+	// Save to database (no event publishing for historical orders)
+	if err := s.repo.Save(order, audit, nil); err != nil {
+		var duplicate *repository.DuplicateOrderError
+		if !errors.As(err, &duplicate) {
+			s.logger.WithError(err).Error("Failed to save historical order")
+		}
+		return err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"order_id":     order.ID,
+		"customer_id":  order.CustomerID,
+		"total_amount": order.TotalAmount,
+		"source":       audit.Source,
+	}).Info("Historical order created successfully (no events published)")
+	return nil
+}
+
+// CreateOrderBatch stores up to models.MaxBatchSize orders in one
+// transaction and reports the outcome of each. Invalid orders and orders
+// that already exist do not fail the rest of the batch. Orders are matched
+// by ID and idempotency key, so a retried batch reports the orders it
+// already stored as existing. A different order reusing either fails.
+func (s *OrderService) CreateOrderBatch(w http.ResponseWriter, r *http.Request) {
+	var req models.BatchOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.WithError(err).Error("Failed to decode order batch request")
+		s.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(req.Orders) == 0 {
+		s.respondWithError(w, http.StatusBadRequest, "Batch must contain at least one order")
+		return
+	}
+	if len(req.Orders) > models.MaxBatchSize {
+		s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Batch must not contain more than %d orders", models.MaxBatchSize))
+		return
+	}
+
+	results := make([]models.BatchOrderResult, len(req.Orders))
+	var orders []*models.Order
+	var orderEvents []*repository.Event
+	var positions []int
+	for i := range req.Orders {
+		order := &req.Orders[i]
+		results[i] = models.BatchOrderResult{Index: i, OrderID: order.ID}
+		order.Currency = models.CurrencyOrDefault(order.Currency)
+		if errs := validation.ValidateOrder(order); len(errs) > 0 {
+			results[i].Status = http.StatusUnprocessableEntity
+			results[i].Message = "Order validation failed"
+			results[i].Errors = errs
+			continue
+		}
+		orders = append(orders, order)
+		orderEvents = append(orderEvents, s.orderCreatedEvent(order))
+		positions = append(positions, i)
+	}
+
+	if len(orders) > 0 {
+		saved, err := s.repo.SaveBatch(orders, liveAudit(r), orderEvents)
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to save order batch")
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to save orders")
+			return
+		}
+		s.notifyOutbox()
+
+		for j, saveErr := range saved {
+			result := &results[positions[j]]
+			var duplicate *repository.DuplicateOrderError
+			switch {
+			case saveErr == nil:
+				result.Status = http.StatusCreated
+				result.Success = true
+				result.Message = "Order created successfully"
+				result.Order = orders[j]
+			case errors.As(saveErr, &duplicate) && duplicate.Conflict != "":
+				result.Status, result.Message = conflictStatus(duplicate)
+			case errors.As(saveErr, &duplicate):
+				result.Status = http.StatusOK
+				result.Success = true
+				result.Message = "Order already exists"
+				result.Order = duplicate.Existing
+			default:
+				s.logger.WithError(saveErr).WithField("order_id", orders[j].ID).Error("Failed to save order of batch")
+				result.Status = http.StatusInternalServerError
+				result.Message = "Failed to save order"
+			}
+		}
+	}
+
+	response := models.NewBatchOrderResponse(results)
+	s.logger.WithFields(logrus.Fields{
+		"total":   response.Total,
+		"created": response.Created,
+		"existed": response.Existed,
+		"failed":  response.Failed,
+	}).Info("Order batch processed")
+
+	s.respondWithJSON(w, http.StatusOK, response)
+}
+
+// ListOrders streams one page of orders. See models.ParseOrderQuery for the
+// filter, sort and paging parameters. Orders are written as they are read,
+// so the page is never held in memory.
 func (s *OrderService) ListOrders(w http.ResponseWriter, r *http.Request) {
-	orders, err := s.getAllOrders()
+	query, err := models.ParseOrderQuery(r.URL.Query())
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to get orders")
-		s.respondWithError(w, http.StatusInternalServerError, "Failed to get orders")
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	s.streamOrders(w, query)
+}
 
-	s.logger.WithField("count", len(orders)).Info("Retrieved orders from database")
+// SearchOrders finds the orders with an item of a product or with
+// specifications, e.g. ?product_id=WIDGET-001&specifications.color=blue,
+// and streams them like ListOrders. The listing filters, sort and paging
+// apply as well.
+func (s *OrderService) SearchOrders(w http.ResponseWriter, r *http.Request) {
+	query, err := models.ParseOrderSearch(r.URL.Query())
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.streamOrders(w, query)
+}
 
-	s.respondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-		"orders":  orders,
-		"count":   len(orders),
+func (s *OrderService) streamOrders(w http.ResponseWriter, query models.OrderQuery) {
+	// The status is only sent with the first order, so a query that fails
+	// up front still gets a 500
+	started := false
+	start := func() {
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, `{"success":true,"orders":[`)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	count := 0
+	nextCursor, err := s.repo.List(query, func(order *models.Order) error {
+		start()
+		if count > 0 {
+			io.WriteString(w, ",")
+		}
+		count++
+		return encoder.Encode(order)
 	})
+	if err != nil {
+		s.logger.WithError(err).WithField("streamed", count).Error("Failed to get orders")
+		if !started {
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to get orders")
+		}
+		// Otherwise the response is left truncated so the client sees
+		// invalid JSON rather than a short page
+		return
+	}
+
+	start()
+	cursorJSON, _ := json.Marshal(nextCursor)
+	fmt.Fprintf(w, `],"count":%d,"next_cursor":%s,"has_more":%t}`, count, cursorJSON, nextCursor != "")
+
+	s.logger.WithField("count", count).Info("Retrieved orders from database")
 }
 
 func (s *OrderService) GetOrder(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	orderID := vars["id"]
 
-	order, err := s.getOrderByID(orderID)
+	order, err := s.repo.Get(orderID)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, repository.ErrNotFound) {
 			s.respondWithError(w, http.StatusNotFound, "Order not found")
 			return
 		}
@@ -232,12 +552,421 @@ func (s *OrderService) GetOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, order)
 	s.respondWithJSON(w, http.StatusOK, order)
 }
 
+// UpdateOrder changes the delivery date and items of an order that has not
+// been picked yet. The request must carry the order's ETag in If-Match, so
+// an update based on a stale read is rejected with 412 instead of silently
+// overwriting a newer change.
+func (s *OrderService) UpdateOrder(w http.ResponseWriter, r *http.Request) {
+	orderID := mux.Vars(r)["id"]
+
+	ifMatch := r.Header.Get(ifMatchHeader)
+	if ifMatch == "" {
+		s.respondWithError(w, http.StatusPreconditionRequired, "If-Match header with the order's ETag is required")
+		return
+	}
+
+	var update models.OrderUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if update.IsEmpty() {
+		s.respondWithError(w, http.StatusBadRequest, "Update must change delivery_date or items")
+		return
+	}
+
+	version, err := models.ParseIfMatch(ifMatch)
+	if err != nil {
+		s.respondWithVersionMismatch(w, orderID)
+		return
+	}
+
+	var changed []string
+	order, err := s.repo.Update(orderID, version, liveAudit(r), func(order *models.Order) (*repository.Event, error) {
+		if err := models.CheckModifiable(order.Status); err != nil {
+			return nil, err
+		}
+		changed = update.Apply(order)
+		if len(changed) == 0 {
+			return nil, nil
+		}
+		if errs := validation.ValidateOrder(order); len(errs) > 0 {
+			return nil, errs
+		}
+		return s.orderUpdatedEvent(order, changed), nil
+	})
+	if err != nil {
+		var errs validation.Errors
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			s.respondWithError(w, http.StatusNotFound, "Order not found")
+		case errors.Is(err, repository.ErrVersionMismatch):
+			s.respondWithVersionMismatch(w, orderID)
+		case errors.Is(err, models.ErrOrderNotModifiable):
+			s.respondWithError(w, http.StatusConflict, err.Error())
+		case errors.As(err, &errs):
+			s.respondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"success": false,
+				"message": "Order validation failed",
+				"errors":  errs,
+			})
+		default:
+			s.logger.WithError(err).WithField("order_id", orderID).Error("Failed to update order")
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to update order")
+		}
+		return
+	}
+
+	message := "Order unchanged"
+	if len(changed) > 0 {
+		s.notifyOutbox()
+		message = "Order updated successfully"
+		s.logger.WithFields(logrus.Fields{
+			"order_id":       orderID,
+			"version":        order.Version,
+			"changed_fields": changed,
+		}).Info("Order updated")
+	}
+
+	setETag(w, order)
+	s.respondWithJSON(w, http.StatusOK, models.OrderResponse{
+		Success: true,
+		Message: message,
+		Order:   order,
+	})
+}
+
+// respondWithVersionMismatch answers an update whose If-Match does not match
+// the stored version, with the current ETag so the client can re-read.
+func (s *OrderService) respondWithVersionMismatch(w http.ResponseWriter, orderID string) {
+	order, err := s.repo.Get(orderID)
+	if errors.Is(err, repository.ErrNotFound) {
+		s.respondWithError(w, http.StatusNotFound, "Order not found")
+		return
+	}
+	payload := map[string]interface{}{
+		"success": false,
+		"message": "Order has changed since it was read; fetch it again and retry",
+	}
+	if err == nil {
+		setETag(w, order)
+		payload["current_version"] = order.Version
+	}
+	s.respondWithJSON(w, http.StatusPreconditionFailed, payload)
+}
+
+// actor returns who made a change, as named by the X-Actor header. The
+// header is trusted as sent: the Order Service expects to be reachable only
+// by the proxy and internal tools, and does not authenticate callers.
+func actor(r *http.Request) string {
+	if actor := r.Header.Get(actorHeader); actor != "" {
+		return actor
+	}
+	return models.AnonymousActor
+}
+
+// liveAudit describes a change made through the REST API. The proxy marks
+// its requests with the proxy change source; any other caller is recorded as
+// the API.
+func liveAudit(r *http.Request) repository.Audit {
+	source := models.AuditSourceAPI
+	if r.Header.Get(changeSourceHeader) == models.AuditSourceProxy {
+		source = models.AuditSourceProxy
+	}
+	return repository.Audit{Actor: actor(r), Source: source}
+}
+
+// OrderHistory returns the audit trail of an order, oldest change first.
+func (s *OrderService) OrderHistory(w http.ResponseWriter, r *http.Request) {
+	orderID := mux.Vars(r)["id"]
+
+	history, err := s.repo.History(orderID)
+	if err != nil {
+		s.logger.WithError(err).WithField("order_id", orderID).Error("Failed to get order history")
+		s.respondWithError(w, http.StatusInternalServerError, "Failed to get order history")
+		return
+	}
+	if len(history) == 0 {
+		// Orders stored before the audit trail existed have no history, but
+		// unknown orders are not found
+		if _, err := s.repo.Get(orderID); errors.Is(err, repository.ErrNotFound) {
+			s.respondWithError(w, http.StatusNotFound, "Order not found")
+			return
+		} else if err != nil {
+			s.logger.WithError(err).WithField("order_id", orderID).Error("Failed to get order")
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to get order history")
+			return
+		}
+	}
+
+	s.respondWithJSON(w, http.StatusOK, models.OrderHistoryResponse{
+		Success: true,
+		OrderID: orderID,
+		History: history,
+	})
+}
+
+// setETag sends the order's version as its ETag.
+func setETag(w http.ResponseWriter, order *models.Order) {
+	if order.Version > 0 {
+		w.Header().Set(etagHeader, models.ETag(order.Version))
+	}
+}
+
+type statusUpdateRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// UpdateOrderStatus moves an order to a new status if the lifecycle allows
+// it and publishes an order.status_changed event. Cancelling needs SAP's
+// confirmation, so it goes through CancelOrder instead, and the status of an
+// order awaiting that confirmation cannot change.
+func (s *OrderService) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	orderID := mux.Vars(r)["id"]
+
+	var req statusUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !models.IsValidStatus(req.Status) {
+		s.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown order status %q", req.Status))
+		return
+	}
+	if req.Status == models.StatusCancelled {
+		s.respondWithError(w, http.StatusBadRequest, "Orders are cancelled with POST /orders/{id}/cancel, which SAP confirms")
+		return
+	}
+
+	previous, err := s.repo.UpdateStatus(orderID, req.Status, liveAudit(r), func(previous string) repository.Event {
+		return repository.Event{
+			Topic: events.OrderStatusChangedTopic,
+			Key:   orderID,
+			Payload: events.OrderStatusChangedEvent{
+				OrderID:    orderID,
+				FromStatus: previous,
+				ToStatus:   req.Status,
+				Reason:     req.Reason,
+				ChangedAt:  time.Now(),
+				EventTime:  time.Now(),
+			},
+		}
+	})
+	if err != nil {
+		var transitionErr *models.TransitionError
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			s.respondWithError(w, http.StatusNotFound, "Order not found")
+		case errors.As(err, &transitionErr):
+			s.respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+				"success":             false,
+				"message":             transitionErr.Error(),
+				"current_status":      transitionErr.From,
+				"allowed_transitions": transitionErr.Allowed,
+			})
+		case errors.Is(err, repository.ErrCancellationPending):
+			s.respondWithError(w, http.StatusConflict, err.Error())
+		case errors.Is(err, models.ErrUnknownStatus):
+			// Orders imported before the lifecycle existed can hold other values
+			s.respondWithError(w, http.StatusConflict, err.Error())
+		default:
+			s.logger.WithError(err).WithField("order_id", orderID).Error("Failed to update order status")
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to update order status")
+		}
+		return
+	}
+	s.notifyOutbox()
+
+	s.logger.WithFields(logrus.Fields{
+		"order_id":    orderID,
+		"from_status": previous,
+		"to_status":   req.Status,
+		"reason":      req.Reason,
+	}).Info("Order status changed")
+
+	order, err := s.repo.Get(orderID)
+	if err != nil {
+		s.logger.WithError(err).WithField("order_id", orderID).Error("Failed to reload order")
+		s.respondWithError(w, http.StatusInternalServerError, "Failed to get order")
+		return
+	}
+
+	setETag(w, order)
+	s.respondWithJSON(w, http.StatusOK, models.OrderResponse{
+		Success: true,
+		Message: fmt.Sprintf("Order status changed from %s to %s", previous, req.Status),
+		Order:   order,
+	})
+}
+
+// CancelOrder records a cancellation request and asks SAP, through the
+// outbox, to cancel the order. The order is only marked cancelled once SAP
+// confirms, so the response is 202 Accepted.
+func (s *OrderService) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	orderID := mux.Vars(r)["id"]
+
+	var req models.CancellationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !models.IsValidCancellationReason(req.ReasonCode) {
+		s.respondWithJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"success":       false,
+			"message":       fmt.Sprintf("Unknown cancellation reason %q", req.ReasonCode),
+			"valid_reasons": models.CancellationReasons(),
+		})
+		return
+	}
+
+	requestedAt := time.Now()
+	event := repository.Event{
+		Topic: events.OrderCancelledTopic,
+		Key:   orderID,
+		Payload: events.OrderCancelledEvent{
+			OrderID:     orderID,
+			ReasonCode:  req.ReasonCode,
+			Note:        req.Note,
+			RequestedAt: requestedAt,
+			EventTime:   time.Now(),
+		},
+	}
+	if err := s.repo.RequestCancellation(orderID, req, requestedAt, liveAudit(r), event); err != nil {
+		var transitionErr *models.TransitionError
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			s.respondWithError(w, http.StatusNotFound, "Order not found")
+		case errors.As(err, &transitionErr):
+			s.respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+				"success":        false,
+				"message":        transitionErr.Error(),
+				"current_status": transitionErr.From,
+			})
+		case errors.Is(err, repository.ErrCancellationPending), errors.Is(err, models.ErrUnknownStatus):
+			s.respondWithError(w, http.StatusConflict, err.Error())
+		default:
+			s.logger.WithError(err).WithField("order_id", orderID).Error("Failed to request cancellation")
+			s.respondWithError(w, http.StatusInternalServerError, "Failed to cancel order")
+		}
+		return
+	}
+	s.notifyOutbox()
+
+	s.logger.WithFields(logrus.Fields{
+		"order_id":    orderID,
+		"reason_code": req.ReasonCode,
+	}).Info("Order cancellation requested")
+
+	order, err := s.repo.Get(orderID)
+	if err != nil {
+		s.logger.WithError(err).WithField("order_id", orderID).Error("Failed to reload order")
+		s.respondWithError(w, http.StatusInternalServerError, "Failed to get order")
+		return
+	}
+
+	setETag(w, order)
+	s.respondWithJSON(w, http.StatusAccepted, models.OrderResponse{
+		Success: true,
+		Message: "Cancellation requested, waiting for SAP confirmation",
+		Order:   order,
+	})
+}
+
+// cancellationResultHandler applies SAP's cancellation results.
+type cancellationResultHandler struct {
+	service *OrderService
+}
+
+func (h *cancellationResultHandler) HandleCancellationResult(event events.OrderCancellationResultEvent) error {
+	s := h.service
+	logger := s.logger.WithFields(logrus.Fields{
+		"order_id":   event.OrderID,
+		"accepted":   event.Accepted,
+		"sap_status": event.SAPStatus,
+	})
+
+	if !event.Accepted {
+		if err := s.repo.RejectCancellation(event.OrderID, event.Message, sapAudit); err != nil {
+			return err
+		}
+		logger.Info("SAP rejected order cancellation")
+		return nil
+	}
+
+	previous, err := s.repo.ConfirmCancellation(event.OrderID, event.Message, sapAudit, func(previous string) repository.Event {
+		return repository.Event{
+			Topic: events.OrderStatusChangedTopic,
+			Key:   event.OrderID,
+			Payload: events.OrderStatusChangedEvent{
+				OrderID:    event.OrderID,
+				FromStatus: previous,
+				ToStatus:   models.StatusCancelled,
+				Reason:     "cancellation confirmed by SAP",
+				ChangedAt:  event.ProcessedAt,
+				EventTime:  time.Now(),
+			},
+		}
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) || errors.Is(err, models.ErrInvalidTransition) || errors.Is(err, models.ErrUnknownStatus) {
+			// The order changed or disappeared while SAP was deciding
+			logger.WithError(err).Warn("Cancellation confirmed by SAP could not be applied")
+			return nil
+		}
+		return err
+	}
+	if previous == "" {
+		// Redelivered result for an order that is already cancelled
+		return nil
+	}
+	s.notifyOutbox()
+
+	logger.Info("Order cancelled")
+	return nil
+}
+
+// IsRetryable retries database errors; results that no longer apply are
+// dropped by the handler without an error.
+func (h *cancellationResultHandler) IsRetryable(err error) bool {
+	return true
+}
+
+// consumeCancellationResults applies SAP's cancellation results until the
+// context is cancelled. Kafka may still be starting, so connecting is
+// retried.
+func (s *OrderService) consumeCancellationResults(ctx context.Context, brokers string) {
+	handler := &cancellationResultHandler{service: s}
+
+	var consumer *events.KafkaConsumerWithRetry
+	for i := 0; ; i++ {
+		var err error
+		consumer, err = events.NewKafkaConsumerWithRetry(brokers, "order-service-group", handler, s.logger)
+		if err == nil {
+			break
+		}
+		s.logger.WithError(err).WithField("attempt", i+1).Warn("Failed to connect to Kafka, retrying...")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+	defer consumer.Close()
+
+	if err := consumer.Start(ctx); err != nil {
+		s.logger.WithError(err).Error("Cancellation result consumer stopped")
+	}
+}
+
 func (s *OrderService) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	// Check database connection
-	if err := s.db.Ping(); err != nil {
+	if err := s.repo.Ping(); err != nil {
 		s.respondWithJSON(w, http.StatusServiceUnavailable, map[string]string{
 			"status": "unhealthy",
 			"service": "order-service",
@@ -252,166 +981,227 @@ func (s *OrderService) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (s *OrderService) saveOrder(order *models.Order) error {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return err
+// respondWithDuplicate answers a retry with the stored order. An order that
+// only shares its ID or idempotency key with the stored one is rejected.
+func (s *OrderService) respondWithDuplicate(w http.ResponseWriter, duplicate *repository.DuplicateOrderError) {
+	if duplicate.Conflict != "" {
+		s.logger.WithFields(logrus.Fields{
+			"order_id": duplicate.Existing.ID,
+			"conflict": duplicate.Conflict,
+		}).Warn("Order conflicts with a different stored order")
+		code, message := conflictStatus(duplicate)
+		s.respondWithError(w, code, message)
+		return
 	}
-	defer tx.Rollback()
 
-	// Insert order
-	query := `
-		INSERT INTO orders (id, customer_id, total_amount, delivery_date, status, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`
-	_, err = tx.Exec(query, order.ID, order.CustomerID, order.TotalAmount, 
-		order.DeliveryDate, order.Status, order.CreatedAt)
-	if err != nil {
-		return err
-	}
+	s.logger.WithFields(logrus.Fields{
+		"order_id":        duplicate.Existing.ID,
+		"idempotency_key": duplicate.Existing.IdempotencyKey,
+	}).Info("Order already exists - returning stored order")
 
-	// Insert order items
-	for _, item := range order.Items {
-		itemQuery := `
-			INSERT INTO order_items (order_id, product_id, quantity, unit_price, specifications)
-			VALUES ($1, $2, $3, $4, $5)
-		`
-		specJSON, _ := json.Marshal(item.Specifications)
-		_, err = tx.Exec(itemQuery, order.ID, item.ProductID, item.Quantity, 
-			item.UnitPrice, string(specJSON))
-		if err != nil {
-			return err
-		}
+	w.Header().Set(idempotentReplayHeader, "true")
+	setETag(w, duplicate.Existing)
+	s.respondWithJSON(w, http.StatusOK, models.OrderResponse{
+		Success: true,
+		Message: "Order already exists",
+		Order:   duplicate.Existing,
+	})
+}
+
+// conflictStatus is the status and message rejecting an order that reused
+// the idempotency key or the ID of a different order. A reused key is
+// answered like the proxy answers it.
+func conflictStatus(duplicate *repository.DuplicateOrderError) (int, string) {
+	if duplicate.Conflict == repository.ConflictIdempotencyKey {
+		return http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different order"
 	}
+	return http.StatusConflict, fmt.Sprintf("Order ID %s is already used by a different order", duplicate.Existing.ID)
+}
 
-	return tx.Commit()
+// orderCreatedEvent is the event published for a new order.
+func (s *OrderService) orderCreatedEvent(order *models.Order) *repository.Event {
+	return &repository.Event{
+		Topic: events.OrderCreatedTopic,
+		Key:   order.ID,
+		Payload: events.OrderCreatedEvent{
+			OrderID:         order.ID,
+			CustomerID:      order.CustomerID,
+			Items:           order.Items,
+			TotalAmount:     order.TotalAmount,
+			Currency:        order.Currency,
+			ConvertedAmount: s.convert(order),
+			DeliveryDate:    order.DeliveryDate,
+			CreatedAt:       order.CreatedAt,
+			EventTime:       time.Now(),
+		},
+	}
 }
 
-func (s *OrderService) getAllOrders() ([]*models.Order, error) {
-	// Get all orders
-	query := `
-		SELECT id, customer_id, total_amount, delivery_date, status, created_at
-		FROM orders ORDER BY created_at DESC
-	`
-	rows, err := s.db.Query(query)
+// convert returns the order total in SAP's company code currency, or nil
+// without a rate table. An order in a currency the table does not know is
+// still stored; its event goes out without the converted amount.
+func (s *OrderService) convert(order *models.Order) *models.ConvertedAmount {
+	converted, err := s.converter.ConvertTotal(order)
 	if err != nil {
-		return nil, err
+		s.logger.WithError(err).WithFields(logrus.Fields{
+			"order_id": order.ID,
+			"currency": order.Currency,
+		}).Warn("Failed to convert order total into the company code currency")
+		return nil
 	}
-	defer rows.Close()
+	return converted
+}
 
-	var orders []*models.Order
-	orderMap := make(map[string]*models.Order)
-
-	for rows.Next() {
-		order := &models.Order{}
-		err := rows.Scan(
-			&order.ID, &order.CustomerID, &order.TotalAmount,
-			&order.DeliveryDate, &order.Status, &order.CreatedAt,
-		)
-		if err != nil {
-			return nil, err
+// orderUpdatedEvent is the event published for an update, carrying only the
+// changed fields.
+func (s *OrderService) orderUpdatedEvent(order *models.Order, changed []string) *repository.Event {
+	event := events.OrderUpdatedEvent{
+		OrderID:       order.ID,
+		Version:       order.Version,
+		ChangedFields: changed,
+		UpdatedAt:     time.Now(),
+		EventTime:     time.Now(),
+	}
+	for _, field := range changed {
+		switch field {
+		case models.FieldDeliveryDate:
+			deliveryDate := order.DeliveryDate
+			event.DeliveryDate = &deliveryDate
+		case models.FieldItems:
+			event.Items = order.Items
+		case models.FieldTotalAmount:
+			totalAmount := order.TotalAmount
+			event.TotalAmount = &totalAmount
+			event.ConvertedAmount = s.convert(order)
 		}
-		orders = append(orders, order)
-		orderMap[order.ID] = order
 	}
+	return &repository.Event{Topic: events.OrderUpdatedTopic, Key: order.ID, Payload: event}
+}
 
-	// Get all order items for each order
-	for _, order := range orders {
-		itemsQuery := `
-			SELECT product_id, quantity, unit_price, specifications
-			FROM order_items WHERE order_id = $1
-		`
-		itemRows, err := s.db.Query(itemsQuery, order.ID)
-		if err != nil {
-			return nil, err
-		}
+// CustomerOrderSummary sums a customer's orders: counts by status, revenue,
+// average order value, items and quantities. Without from and to, all of
+// the customer's orders are included.
+func (s *OrderService) CustomerOrderSummary(w http.ResponseWriter, r *http.Request) {
+	customerID := mux.Vars(r)["id"]
+	window, err := models.ParseAnalyticsWindow(r.URL.Query(), 0, time.Now())
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-		for itemRows.Next() {
-			var item models.OrderItem
-			var specJSON string
-			err := itemRows.Scan(&item.ProductID, &item.Quantity, &item.UnitPrice, &specJSON)
-			if err != nil {
-				itemRows.Close()
-				return nil, err
-			}
-			json.Unmarshal([]byte(specJSON), &item.Specifications)
-			order.Items = append(order.Items, item)
-		}
-		itemRows.Close()
+	summary, err := s.repo.CustomerSummary(customerID, window)
+	if err != nil {
+		s.logger.WithError(err).WithField("customer_id", customerID).Error("Failed to summarize customer orders")
+		s.respondWithError(w, http.StatusInternalServerError, "Failed to summarize customer orders")
+		return
 	}
 
-	return orders, nil
+	s.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"summary": summary,
+	})
 }
 
-func (s *OrderService) getOrderByID(orderID string) (*models.Order, error) {
-	order := &models.Order{}
-	
-	// Get order
-	query := `
-		SELECT id, customer_id, total_amount, delivery_date, status, created_at
-		FROM orders WHERE id = $1
-	`
-	err := s.db.QueryRow(query, orderID).Scan(
-		&order.ID, &order.CustomerID, &order.TotalAmount,
-		&order.DeliveryDate, &order.Status, &order.CreatedAt,
-	)
+// OrderAnalytics sums the orders created in a window, by default the last
+// models.DefaultAnalyticsDays days: counts by status, revenue per day,
+// average order value and the top customers by revenue.
+func (s *OrderService) OrderAnalytics(w http.ResponseWriter, r *http.Request) {
+	window, err := models.ParseAnalyticsWindow(r.URL.Query(), models.DefaultAnalyticsDays, time.Now())
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	top, err := models.ParseTopCustomers(r.URL.Query())
+	if err != nil {
+		s.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	analytics, err := s.repo.Analytics(window, top)
 	if err != nil {
-		return nil, err
+		s.logger.WithError(err).Error("Failed to compute order analytics")
+		s.respondWithError(w, http.StatusInternalServerError, "Failed to compute order analytics")
+		return
 	}
 
-	// Get order items
-	itemsQuery := `
-		SELECT product_id, quantity, unit_price, specifications
-		FROM order_items WHERE order_id = $1
-	`
-	rows, err := s.db.Query(itemsQuery, orderID)
+	s.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"analytics": analytics,
+	})
+}
+
+// notifyOutbox wakes the relay after events were committed.
+func (s *OrderService) notifyOutbox() {
+	if s.outbox != nil {
+		s.outbox.Notify()
+	}
+}
+
+// OutboxBacklog reports the events waiting to be published to Kafka.
+func (s *OrderService) OutboxBacklog(w http.ResponseWriter, r *http.Request) {
+	backlog, err := s.outbox.Backlog()
 	if err != nil {
-		return nil, err
+		s.logger.WithError(err).Error("Failed to read outbox backlog")
+		s.respondWithError(w, http.StatusInternalServerError, "Failed to read outbox backlog")
+		return
+	}
+
+	s.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"backlog":   backlog,
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// runMigrate handles the migrate subcommand: up applies pending migrations,
+// down [n] reverts the latest n (default 1) and status lists them.
+func runMigrate(migrator *migrations.Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: order-service migrate up|down [n]|status")
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var item models.OrderItem
-		var specJSON string
-		err := rows.Scan(&item.ProductID, &item.Quantity, &item.UnitPrice, &specJSON)
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
 		if err != nil {
-			return nil, err
+			return err
+		}
+		fmt.Fprintf(out, "Applied %d migration(s)\n", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		reverted, err := migrator.Down(steps)
+		if err != nil {
+			return err
 		}
-		json.Unmarshal([]byte(specJSON), &item.Specifications)
-		order.Items = append(order.Items, item)
-	}
-
-	return order, nil
-}
-
-func createTables(db *sql.DB) error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS orders (
-			id VARCHAR(255) PRIMARY KEY,
-			customer_id VARCHAR(255) NOT NULL,
-			total_amount DECIMAL(10,2) NOT NULL,
-			delivery_date TIMESTAMP NOT NULL,
-			status VARCHAR(50) NOT NULL,
-			created_at TIMESTAMP NOT NULL
-		)`,
-		`CREATE TABLE IF NOT EXISTS order_items (
-			id SERIAL PRIMARY KEY,
-			order_id VARCHAR(255) NOT NULL REFERENCES orders(id),
-			product_id VARCHAR(255) NOT NULL,
-			quantity INTEGER NOT NULL,
-			unit_price DECIMAL(10,2) NOT NULL,
-			specifications JSONB
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders(customer_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items(order_id)`,
-	}
-
-	for _, query := range queries {
-		if _, err := db.Exec(query); err != nil {
+		fmt.Fprintf(out, "Reverted %d migration(s)\n", reverted)
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
 			return err
 		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied() {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			if status.Modified {
+				state += " (modified since applied)"
+			}
+			if status.Unknown {
+				state += " (unknown to this binary)"
+			}
+			fmt.Fprintf(out, "%04d  %-30s  %s\n", status.Version, status.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
 	}
-
 	return nil
 }
 
@@ -456,4 +1246,23 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
+}
+
+// getEnvInt reads an integer setting, falling back to the default if it is
+// missing or invalid.
+func getEnvInt(key string, defaultValue int, logger *logrus.Logger) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		logger.WithFields(logrus.Fields{
+			"env_var": key,
+			"value":   value,
+			"default": defaultValue,
+		}).Warn("Invalid integer setting, using default")
+		return defaultValue
+	}
+	return parsed
 }
\ No newline at end of file
@@ -0,0 +1,570 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jogardn/strangler-demo/internal/events"
+	"github.com/jogardn/strangler-demo/internal/repository"
+	"github.com/jogardn/strangler-demo/internal/sap"
+	"github.com/jogardn/strangler-demo/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+// The handlers run on the in-memory repository. The Postgres repository is
+// tested in internal/repository.
+func newTestService() (*OrderService, *repository.Memory) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	repo := repository.NewMemory()
+	return &OrderService{repo: repo, logger: logger}, repo
+}
+
+// testDeliveryDate is shared by every test order, so two of them are the
+// same order unless a test changes one.
+var testDeliveryDate = time.Now().Add(24 * time.Hour)
+
+func testOrder() models.Order {
+	return models.Order{
+		ID:         "order-1",
+		CustomerID: "customer-1",
+		Items: []models.OrderItem{
+			{ProductID: "WIDGET-001", Quantity: 10, UnitPrice: 2599},
+		},
+		TotalAmount:  25990,
+		DeliveryDate: testDeliveryDate,
+		Status:       models.StatusPending,
+		CreatedAt:    time.Now(),
+	}
+}
+
+// serve sends a request through the service's routes.
+func serve(s *OrderService, method, path string, body interface{}, headers map[string]string) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	router.HandleFunc("/orders", s.CreateOrder).Methods("POST")
+	router.HandleFunc("/orders/historical", s.CreateOrderHistorical).Methods("POST")
+	router.HandleFunc("/orders/batch", s.CreateOrderBatch).Methods("POST")
+	router.HandleFunc("/orders", s.ListOrders).Methods("GET")
+	router.HandleFunc("/orders/search", s.SearchOrders).Methods("GET")
+	router.HandleFunc("/orders/{id}", s.GetOrder).Methods("GET")
+	router.HandleFunc("/orders/{id}", s.UpdateOrder).Methods("PUT")
+	router.HandleFunc("/orders/{id}/status", s.UpdateOrderStatus).Methods("PATCH")
+	router.HandleFunc("/orders/{id}/cancel", s.CancelOrder).Methods("POST")
+	router.HandleFunc("/orders/{id}/history", s.OrderHistory).Methods("GET")
+	router.HandleFunc("/customers/{id}/orders/summary", s.CustomerOrderSummary).Methods("GET")
+	router.HandleFunc("/analytics/orders", s.OrderAnalytics).Methods("GET")
+
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCreateOrderPublishesEvent(t *testing.T) {
+	s, repo := newTestService()
+
+	rec := serve(s, "POST", "/orders", testOrder(), nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	published := repo.Events()
+	if len(published) != 1 || published[0].Topic != events.OrderCreatedTopic || published[0].Key != "order-1" {
+		t.Errorf("Expected an order created event, got %+v", published)
+	}
+}
+
+func TestCreateOrderEventCarriesConvertedAmount(t *testing.T) {
+	s, repo := newTestService()
+	rates := filepath.Join(t.TempDir(), "rates.json")
+	os.WriteFile(rates, []byte(`{"base": "EUR", "rates": {"USD": 0.92}}`), 0o644)
+	converter, err := sap.LoadConverter(rates, "")
+	if err != nil {
+		t.Fatalf("Failed to load rates: %v", err)
+	}
+	s.converter = converter
+
+	// An order without a currency is in US dollars
+	if rec := serve(s, "POST", "/orders", testOrder(), nil); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	// A currency without a rate does not stop the order
+	order := testOrder()
+	order.ID = "order-2"
+	order.Currency = "CHF"
+	if rec := serve(s, "POST", "/orders", order, nil); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	published := repo.Events()
+	if len(published) != 2 {
+		t.Fatalf("Expected two events, got %+v", published)
+	}
+	created := published[0].Payload.(events.OrderCreatedEvent)
+	if created.TotalAmount != 25990 || created.Currency != "USD" ||
+		created.ConvertedAmount == nil || *created.ConvertedAmount != (models.ConvertedAmount{Amount: 23911, Currency: "EUR"}) {
+		t.Errorf("Expected 259.90 USD converted to 239.11 EUR, got %+v", created)
+	}
+	if created := published[1].Payload.(events.OrderCreatedEvent); created.Currency != "CHF" || created.ConvertedAmount != nil {
+		t.Errorf("Expected a CHF order without a converted amount, got %+v", created)
+	}
+}
+
+func TestCreateOrderRejectsInvalidOrder(t *testing.T) {
+	s, repo := newTestService()
+	order := testOrder()
+	order.Items = nil
+
+	rec := serve(s, "POST", "/orders", order, nil)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422, got %d", rec.Code)
+	}
+	if len(repo.Events()) != 0 {
+		t.Error("Expected no event for an invalid order")
+	}
+}
+
+func TestCreateOrderReplaysRetry(t *testing.T) {
+	s, repo := newTestService()
+	headers := map[string]string{idempotencyKeyHeader: "key-1"}
+
+	serve(s, "POST", "/orders", testOrder(), headers)
+	retry := testOrder()
+	retry.ID = "order-2"
+	rec := serve(s, "POST", "/orders", retry, headers)
+
+	if rec.Code != http.StatusOK || rec.Header().Get(idempotentReplayHeader) != "true" {
+		t.Fatalf("Expected a replayed 200, got %d", rec.Code)
+	}
+	var response models.OrderResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	if response.Order == nil || response.Order.ID != "order-1" {
+		t.Errorf("Expected the stored order, got %+v", response.Order)
+	}
+	if len(repo.Events()) != 1 {
+		t.Errorf("Expected a single event, got %d", len(repo.Events()))
+	}
+}
+
+func TestCreateOrderRejectsDifferentOrderWithTakenKeyOrID(t *testing.T) {
+	s, repo := newTestService()
+	headers := map[string]string{idempotencyKeyHeader: "key-1"}
+	serve(s, "POST", "/orders", testOrder(), headers)
+
+	changed := testOrder()
+	changed.ID = "order-2"
+	changed.Items[0].Quantity = 20
+	changed.TotalAmount = 51980
+	if rec := serve(s, "POST", "/orders", changed, headers); rec.Code != http.StatusUnprocessableEntity || rec.Header().Get(idempotentReplayHeader) != "" {
+		t.Errorf("Expected a reused key with a different order to get 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	changed.ID = "order-1"
+	if rec := serve(s, "POST", "/orders/historical", changed, nil); rec.Code != http.StatusConflict {
+		t.Errorf("Expected a taken ID to get 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := serve(s, "POST", "/orders/batch", models.BatchOrderRequest{Orders: []models.Order{changed}}, nil)
+	var response models.BatchOrderResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	if response.Failed != 1 || response.Results[0].Status != http.StatusConflict {
+		t.Errorf("Expected the batch to report the taken ID with 409, got %+v", response)
+	}
+
+	if stored, _ := repo.Get("order-1"); stored.TotalAmount != 25990 || len(repo.Events()) != 1 {
+		t.Errorf("Expected only the first order to be stored and published, got %+v", stored)
+	}
+}
+
+func TestCreateOrderDryRunStoresNothing(t *testing.T) {
+	s, repo := newTestService()
+
+	rec := serve(s, "POST", "/orders?dry_run=true", testOrder(), nil)
+	var response models.OrderResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	if rec.Code != http.StatusOK || response.Order == nil || response.Order.Version != 1 || response.Order.Currency != models.DefaultCurrency {
+		t.Fatalf("Expected the order as it would be stored, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := repo.Get("order-1"); !errors.Is(err, repository.ErrNotFound) || len(repo.Events()) != 0 {
+		t.Errorf("Expected a dry run to store and publish nothing, got %v and %d events", err, len(repo.Events()))
+	}
+
+	invalid := testOrder()
+	invalid.Items = nil
+	if rec := serve(s, "POST", "/orders?dry_run=true", invalid, nil); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for an invalid order, got %d", rec.Code)
+	}
+}
+
+func TestCreateOrderHistoricalPublishesNothing(t *testing.T) {
+	s, repo := newTestService()
+
+	rec := serve(s, "POST", "/orders/historical", testOrder(), nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", rec.Code)
+	}
+	if len(repo.Events()) != 0 {
+		t.Errorf("Expected no events for a historical order, got %+v", repo.Events())
+	}
+	if rec := serve(s, "GET", "/orders/order-1", nil, nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected the historical order to be stored, got %d", rec.Code)
+	}
+}
+
+func TestGetOrderNotFound(t *testing.T) {
+	s, _ := newTestService()
+
+	if rec := serve(s, "GET", "/orders/missing", nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rec.Code)
+	}
+}
+
+func TestListOrdersStreamsPage(t *testing.T) {
+	s, _ := newTestService()
+	for _, id := range []string{"order-1", "order-2", "order-3"} {
+		order := testOrder()
+		order.ID = id
+		serve(s, "POST", "/orders", order, nil)
+	}
+
+	rec := serve(s, "GET", "/orders?limit=2", nil, nil)
+	var body struct {
+		Success    bool           `json:"success"`
+		Orders     []models.Order `json:"orders"`
+		Count      int            `json:"count"`
+		NextCursor string         `json:"next_cursor"`
+		HasMore    bool           `json:"has_more"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expected valid JSON, got %v: %s", err, rec.Body.String())
+	}
+	if !body.Success || body.Count != 2 || len(body.Orders) != 2 || !body.HasMore || body.NextCursor == "" {
+		t.Errorf("Expected a first page of 2 with a cursor, got %+v", body)
+	}
+
+	if rec := serve(s, "GET", "/orders?limit=0", nil, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid limit, got %d", rec.Code)
+	}
+}
+
+func TestListOrdersEmpty(t *testing.T) {
+	s, _ := newTestService()
+
+	rec := serve(s, "GET", "/orders", nil, nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"orders":[]`) {
+		t.Errorf("Expected an empty page, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestUpdateOrderStatus(t *testing.T) {
+	s, repo := newTestService()
+	serve(s, "POST", "/orders", testOrder(), nil)
+
+	rec := serve(s, "PATCH", "/orders/order-1/status", statusUpdateRequest{Status: models.StatusConfirmed}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	published := repo.Events()
+	if last := published[len(published)-1]; last.Topic != events.OrderStatusChangedTopic {
+		t.Errorf("Expected a status changed event, got %s", last.Topic)
+	}
+
+	rec = serve(s, "PATCH", "/orders/order-1/status", statusUpdateRequest{Status: models.StatusPending}, nil)
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), `"current_status":"confirmed"`) {
+		t.Errorf("Expected 409 with the current status, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := serve(s, "PATCH", "/orders/missing/status", statusUpdateRequest{Status: models.StatusConfirmed}, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rec.Code)
+	}
+}
+
+func TestUpdateOrderStatusLeavesCancellationToSAP(t *testing.T) {
+	s, repo := newTestService()
+	serve(s, "POST", "/orders", testOrder(), nil)
+
+	rec := serve(s, "PATCH", "/orders/order-1/status", statusUpdateRequest{Status: models.StatusCancelled}, nil)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "/cancel") {
+		t.Errorf("Expected 400 pointing to the cancel endpoint, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	serve(s, "POST", "/orders/order-1/cancel", models.CancellationRequest{ReasonCode: models.CancelReasonCustomerRequest}, nil)
+	rec = serve(s, "PATCH", "/orders/order-1/status", statusUpdateRequest{Status: models.StatusConfirmed}, nil)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 while SAP has not answered the cancellation, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if order, _ := repo.Get("order-1"); order.Status != models.StatusPending {
+		t.Errorf("Expected the status to stay pending, got %s", order.Status)
+	}
+}
+
+func TestCancelOrderWaitsForSAP(t *testing.T) {
+	s, repo := newTestService()
+	serve(s, "POST", "/orders", testOrder(), nil)
+	cancel := models.CancellationRequest{ReasonCode: models.CancelReasonCustomerRequest}
+
+	rec := serve(s, "POST", "/orders/order-1/cancel", cancel, nil)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(s, "POST", "/orders/order-1/cancel", cancel, nil); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a second request, got %d", rec.Code)
+	}
+
+	handler := &cancellationResultHandler{service: s}
+	err := handler.HandleCancellationResult(events.OrderCancellationResultEvent{
+		OrderID:     "order-1",
+		Accepted:    true,
+		Message:     "cancelled in SAP",
+		ProcessedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to apply SAP's result: %v", err)
+	}
+
+	order, _ := repo.Get("order-1")
+	if order.Status != models.StatusCancelled || order.Cancellation.State != models.CancellationConfirmed {
+		t.Errorf("Expected a confirmed cancellation, got %s %+v", order.Status, order.Cancellation)
+	}
+
+	var topics []string
+	for _, event := range repo.Events() {
+		topics = append(topics, event.Topic)
+	}
+	expected := []string{events.OrderCreatedTopic, events.OrderCancelledTopic, events.OrderStatusChangedTopic}
+	if strings.Join(topics, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected events %v, got %v", expected, topics)
+	}
+}
+
+func TestCancelOrderRejectsUnknownReason(t *testing.T) {
+	s, _ := newTestService()
+	serve(s, "POST", "/orders", testOrder(), nil)
+
+	rec := serve(s, "POST", "/orders/order-1/cancel", models.CancellationRequest{ReasonCode: "bored"}, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", rec.Code)
+	}
+}
+
+func TestUpdateOrderRequiresMatchingVersion(t *testing.T) {
+	s, repo := newTestService()
+	if rec := serve(s, "POST", "/orders", testOrder(), nil); rec.Header().Get("ETag") != `"1"` {
+		t.Fatalf("Expected ETag \"1\" on create, got %q", rec.Header().Get("ETag"))
+	}
+	update := models.OrderUpdate{Items: []models.OrderItem{{ProductID: "WIDGET-001", Quantity: 4, UnitPrice: 2599}}}
+
+	if rec := serve(s, "PUT", "/orders/order-1", update, nil); rec.Code != http.StatusPreconditionRequired {
+		t.Errorf("Expected 428 without If-Match, got %d", rec.Code)
+	}
+
+	rec := serve(s, "PUT", "/orders/order-1", update, map[string]string{"If-Match": `"1"`})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("ETag") != `"2"` {
+		t.Errorf("Expected ETag \"2\", got %q", rec.Header().Get("ETag"))
+	}
+	order, _ := repo.Get("order-1")
+	if order.TotalAmount != 10396 || order.Version != 2 {
+		t.Errorf("Expected the total to follow the items at version 2, got %s at %d", order.TotalAmount, order.Version)
+	}
+
+	published := repo.Events()
+	last := published[len(published)-1]
+	event, ok := last.Payload.(events.OrderUpdatedEvent)
+	if last.Topic != events.OrderUpdatedTopic || !ok {
+		t.Fatalf("Expected an order updated event, got %s", last.Topic)
+	}
+	if strings.Join(event.ChangedFields, ",") != "items,total_amount" || event.Version != 2 {
+		t.Errorf("Expected items and total at version 2, got %v at %d", event.ChangedFields, event.Version)
+	}
+
+	rec = serve(s, "PUT", "/orders/order-1", update, map[string]string{"If-Match": `"1"`})
+	if rec.Code != http.StatusPreconditionFailed || rec.Header().Get("ETag") != `"2"` {
+		t.Errorf("Expected 412 with the current ETag, got %d %q", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+func TestUpdateOrderRejectsPickedAndInvalidOrders(t *testing.T) {
+	s, _ := newTestService()
+	serve(s, "POST", "/orders", testOrder(), nil)
+
+	invalid := models.OrderUpdate{Items: []models.OrderItem{{ProductID: "WIDGET-001", Quantity: 0, UnitPrice: 2599}}}
+	if rec := serve(s, "PUT", "/orders/order-1", invalid, map[string]string{"If-Match": "*"}); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	serve(s, "PATCH", "/orders/order-1/status", statusUpdateRequest{Status: models.StatusConfirmed}, nil)
+	serve(s, "PATCH", "/orders/order-1/status", statusUpdateRequest{Status: models.StatusPicked}, nil)
+	deliveryDate := time.Now().Add(72 * time.Hour)
+	rec := serve(s, "PUT", "/orders/order-1", models.OrderUpdate{DeliveryDate: &deliveryDate}, map[string]string{"If-Match": "*"})
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 once picked, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := serve(s, "PUT", "/orders/missing", models.OrderUpdate{DeliveryDate: &deliveryDate}, map[string]string{"If-Match": "*"}); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rec.Code)
+	}
+}
+
+func TestCreateOrderBatchReportsEachOrder(t *testing.T) {
+	s, repo := newTestService()
+	serve(s, "POST", "/orders", testOrder(), nil)
+
+	second := testOrder()
+	second.ID = "order-2"
+	invalid := testOrder()
+	invalid.ID = "order-3"
+	invalid.Items = nil
+	batch := models.BatchOrderRequest{Orders: []models.Order{testOrder(), second, invalid}}
+
+	rec := serve(s, "POST", "/orders/batch", batch, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var response models.BatchOrderResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	if response.Success || response.Created != 1 || response.Existed != 1 || response.Failed != 1 {
+		t.Errorf("Expected 1 created, 1 existing and 1 failed, got %+v", response)
+	}
+	statuses := []int{http.StatusOK, http.StatusCreated, http.StatusUnprocessableEntity}
+	for i, result := range response.Results {
+		if result.Index != i || result.Status != statuses[i] {
+			t.Errorf("Expected result %d to have status %d, got %+v", i, statuses[i], result)
+		}
+	}
+
+	// Only the new order publishes an event
+	if published := repo.Events(); len(published) != 2 || published[1].Key != "order-2" {
+		t.Errorf("Expected one more created event for order-2, got %+v", published)
+	}
+}
+
+func TestCreateOrderBatchRejectsOversizedBatch(t *testing.T) {
+	s, _ := newTestService()
+
+	if rec := serve(s, "POST", "/orders/batch", models.BatchOrderRequest{}, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an empty batch, got %d", rec.Code)
+	}
+	batch := models.BatchOrderRequest{Orders: make([]models.Order, models.MaxBatchSize+1)}
+	if rec := serve(s, "POST", "/orders/batch", batch, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an oversized batch, got %d", rec.Code)
+	}
+}
+
+func TestSearchOrdersBySpecification(t *testing.T) {
+	s, _ := newTestService()
+	blue := testOrder()
+	blue.Items[0].Specifications = map[string]string{"color": "blue", "size": "large"}
+	serve(s, "POST", "/orders", blue, nil)
+	red := testOrder()
+	red.ID = "order-2"
+	red.Items[0].Specifications = map[string]string{"color": "red"}
+	serve(s, "POST", "/orders", red, nil)
+
+	rec := serve(s, "GET", "/orders/search?product_id=WIDGET-001&specifications.color=blue", nil, nil)
+	var page struct {
+		Orders []models.Order `json:"orders"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &page)
+	if rec.Code != http.StatusOK || len(page.Orders) != 1 || page.Orders[0].ID != "order-1" {
+		t.Errorf("Expected only order-1, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := serve(s, "GET", "/orders/search?customer_id=customer-1", nil, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without item filters, got %d", rec.Code)
+	}
+}
+
+func TestCustomerOrderSummaryAndAnalytics(t *testing.T) {
+	s, _ := newTestService()
+	serve(s, "POST", "/orders", testOrder(), nil)
+	other := testOrder()
+	other.ID = "order-2"
+	other.CustomerID = "customer-2"
+	serve(s, "POST", "/orders", other, nil)
+
+	rec := serve(s, "GET", "/customers/customer-1/orders/summary", nil, nil)
+	var summary struct {
+		Summary models.CustomerOrderSummary `json:"summary"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &summary)
+	if rec.Code != http.StatusOK || summary.Summary.OrderCount != 1 || summary.Summary.RevenueIn(models.DefaultCurrency).Revenue != 25990 || summary.Summary.TotalQuantity != 10 {
+		t.Errorf("Expected one order of customer-1, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = serve(s, "GET", "/analytics/orders?top=1", nil, nil)
+	var analytics struct {
+		Analytics models.OrderAnalytics `json:"analytics"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &analytics)
+	if rec.Code != http.StatusOK || analytics.Analytics.OrderCount != 2 || analytics.Analytics.OrdersByStatus[models.StatusPending] != 2 ||
+		len(analytics.Analytics.TopCustomers) != 1 || analytics.Analytics.Window.From.IsZero() {
+		t.Errorf("Expected analytics of 2 orders over the default window, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := serve(s, "GET", "/analytics/orders?from=2025-06-02T00:00:00Z&to=2025-06-01T00:00:00Z", nil, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an empty window, got %d", rec.Code)
+	}
+}
+
+func TestOrderHistoryRecordsActorAndSource(t *testing.T) {
+	s, _ := newTestService()
+
+	imported := testOrder()
+	imported.ID = "order-imported"
+	serve(s, "POST", "/orders/historical", imported, map[string]string{"X-Change-Source": "migration"})
+	shadowed := testOrder()
+	shadowed.ID = "order-shadowed"
+	serve(s, "POST", "/orders/historical", shadowed, map[string]string{"X-Change-Source": "shadow"})
+	serve(s, "POST", "/orders", testOrder(), map[string]string{"X-Actor": "alice", "X-Change-Source": models.AuditSourceProxy})
+	serve(s, "PATCH", "/orders/order-1/status", map[string]string{"status": models.StatusConfirmed}, nil)
+
+	var body models.OrderHistoryResponse
+	rec := serve(s, "GET", "/orders/order-1/history", nil, nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 with history, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(body.History) != 2 ||
+		body.History[0].Action != models.AuditActionCreated || body.History[0].Actor != "alice" || body.History[0].Source != models.AuditSourceProxy ||
+		body.History[1].Action != models.AuditActionStatusChanged || body.History[1].Actor != models.AnonymousActor || body.History[1].Source != models.AuditSourceAPI {
+		t.Errorf("Expected the creation by alice through the proxy and an anonymous status change through the API, got %+v", body.History)
+	}
+
+	rec = serve(s, "GET", "/orders/order-imported/history", nil, nil)
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if len(body.History) != 1 || body.History[0].Source != models.AuditSourceMigration {
+		t.Errorf("Expected the migrated order to be told apart from live ones, got %+v", body.History)
+	}
+	rec = serve(s, "GET", "/orders/order-shadowed/history", nil, nil)
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if len(body.History) != 1 || body.History[0].Source != models.AuditSourceShadow {
+		t.Errorf("Expected the shadow copy to be told apart from other imports, got %+v", body.History)
+	}
+
+	if rec := serve(s, "POST", "/orders/historical", testOrder(), map[string]string{"X-Change-Source": "proxy"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a live source on the historical endpoint, got %d", rec.Code)
+	}
+	if rec := serve(s, "GET", "/orders/missing/history", nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing order, got %d", rec.Code)
+	}
+}
@@ -3,18 +3,22 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jogardn/strangler-demo/internal/circuitbreaker"
 	"github.com/jogardn/strangler-demo/internal/orders"
+	"github.com/jogardn/strangler-demo/internal/passthrough"
 	"github.com/jogardn/strangler-demo/internal/sap"
 	"github.com/jogardn/strangler-demo/internal/websocket"
+	"github.com/jogardn/strangler-demo/pkg/models"
 	"github.com/sirupsen/logrus"
 )
 
@@ -38,31 +42,122 @@ func main() {
 	if orderServiceURL != "" {
 		// Configure circuit breaker settings for Order Service
 		orderServiceCBConfig := getOrderServiceCircuitBreakerConfig(logger)
-		orderServiceClient = orderServiceClientWithCircuitBreaker(orderServiceURL, logger, cbManager, orderServiceCBConfig)
+		client, err := orderServiceClientWithCircuitBreaker(orderServiceURL, logger, cbManager, orderServiceCBConfig)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to create order service client")
+		}
+		defer client.Close()
+		orderServiceClient = client
 		logger.WithField("url", orderServiceURL).Info("Order service client configured")
 	} else {
-		logger.Info("Order service URL not configured - running in Phase 1 mode")
+		logger.Info("Order service URL not configured - only the SAP-only phase is available")
 	}
 
 	// Create WebSocket hub
 	wsHub := websocket.NewHub(logger)
 	go wsHub.Run()
 
+	// Share of new orders sent to the Order Service (the rest go to SAP)
+	canaryWeight := 0
+	if orderServiceClient != nil {
+		canaryWeight = parseIntWithDefault("CANARY_WEIGHT", "100", logger)
+	}
+	canaryRouter := orders.NewCanaryRouter(canaryWeight, logger)
+
+	// Bounded store for shadow-mode comparisons
+	shadowStore := orders.NewShadowStore(parseIntWithDefault("SHADOW_REPORT_SIZE", "200", logger))
+
+	// Rule-based routing takes precedence over the canary weight
+	ruleEngine, err := orders.NewRuleEngine(getEnv("ROUTING_RULES_FILE", ""), logger)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to load routing rules")
+	}
+
+	// Dual writes run as sagas journaled to disk so they survive a restart
+	var sagaCoordinator *orders.SagaCoordinator
+	sagaCtx, stopSagas := context.WithCancel(context.Background())
+	defer stopSagas()
+	if orderServiceClient != nil {
+		// Completed and compensated sagas are kept this long for the saga report
+		retention := time.Duration(parseIntWithDefault("SAGA_RETENTION_HOURS", "24", logger)) * time.Hour
+		journal, sagas, err := orders.OpenSagaJournal(getEnv("SAGA_JOURNAL_PATH", "data/saga-journal.jsonl"), retention)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to open saga journal")
+		}
+		defer journal.Close()
+
+		sagaCoordinator = orders.NewSagaCoordinator(
+			sapClient,
+			orderServiceClient,
+			journal,
+			sagas,
+			parseIntWithDefault("SAGA_MAX_RETRIES", "5", logger),
+			time.Duration(parseIntWithDefault("SAGA_RETRY_DELAY_SECONDS", "10", logger))*time.Second,
+			logger,
+		)
+		go sagaCoordinator.Run(sagaCtx, 5*time.Second)
+	}
+
+	phaseManager := orders.NewPhaseManager(sapClient, orderServiceClient, canaryRouter, ruleEngine, shadowStore, sagaCoordinator, logger)
+	phaseManager.SetWebSocketHub(wsHub)
+	initialPhase := orders.PhaseSAPOnly
+	if orderServiceClient != nil {
+		initialPhase = orders.Phase(getEnv("MIGRATION_PHASE", string(orders.PhaseEventDriven)))
+	}
+	if err := phaseManager.SetPhase(initialPhase, "startup"); err != nil {
+		logger.WithError(err).Fatal("Invalid MIGRATION_PHASE")
+	}
+
 	orderHandler := orders.NewHandler(sapClient, orderServiceClient, logger)
 	orderHandler.SetWebSocketHub(wsHub)
+	orderHandler.SetPhaseManager(phaseManager)
+	// Responses to POST /orders and /orders/batch are kept per Idempotency-Key for replay
+	idempotencyTTL := time.Duration(parseIntWithDefault("IDEMPOTENCY_TTL_SECONDS", "86400", logger)) * time.Second
+	orderHandler.SetIdempotencyStore(orders.NewIdempotencyStore(idempotencyTTL))
 
 	router := mux.NewRouter()
 	router.HandleFunc("/health", orderHandler.HealthCheck).Methods("GET", "OPTIONS")
-	router.HandleFunc("/api/health/all", allServicesHealthCheck(sapClient, orderServiceClient, cbManager, logger)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/api/health/all", allServicesHealthCheck(sapClient, orderServiceClient, cbManager, canaryRouter, phaseManager, logger)).Methods("GET", "OPTIONS")
 	router.HandleFunc("/orders", orderHandler.CreateOrder).Methods("POST", "OPTIONS")
 	router.HandleFunc("/orders", orderHandler.GetOrders).Methods("GET", "OPTIONS")
+	router.HandleFunc("/orders/batch", orderHandler.CreateOrderBatch).Methods("POST", "OPTIONS")
+	router.HandleFunc("/orders/search", orderHandler.SearchOrders).Methods("GET", "OPTIONS")
+	router.HandleFunc("/orders/{id}", orderHandler.GetOrder).Methods("GET", "OPTIONS")
+	router.HandleFunc("/orders/{id}", orderHandler.UpdateOrder).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/orders/{id}/cancel", orderHandler.CancelOrder).Methods("POST", "OPTIONS")
+	router.HandleFunc("/orders/{id}/history", orderHandler.OrderHistory).Methods("GET", "OPTIONS")
+	router.HandleFunc("/customers/{id}/orders/summary", orderHandler.CustomerOrderSummary).Methods("GET", "OPTIONS")
+	router.HandleFunc("/analytics/orders", orderHandler.OrderAnalytics).Methods("GET", "OPTIONS")
 	router.HandleFunc("/compare/orders", orderHandler.CompareOrders).Methods("GET", "OPTIONS")
 	router.HandleFunc("/compare/orders/{id}", orderHandler.CompareOrder).Methods("GET", "OPTIONS")
 	router.HandleFunc("/metrics/circuit-breakers", circuitBreakerMetrics(cbManager)).Methods("GET", "OPTIONS")
 	router.HandleFunc("/circuit-breakers/reset", resetCircuitBreakers(cbManager, logger)).Methods("POST", "OPTIONS")
 	router.HandleFunc("/circuit-breakers/reset/{name}", resetCircuitBreaker(cbManager, logger)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/admin/canary", getCanaryWeight(canaryRouter)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/admin/canary", setCanaryWeight(canaryRouter, orderServiceClient != nil, wsHub, logger)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/admin/phase", getPhase(phaseManager)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/admin/phase", setPhase(phaseManager, logger)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/admin/routing-rules", getRoutingRules(ruleEngine)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/admin/routing-rules/reload", reloadRoutingRules(ruleEngine, wsHub, logger)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/shadow/report", shadowReport(shadowStore)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/admin/sagas", getSagas(sagaCoordinator)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/admin/sagas/{id}", getSaga(sagaCoordinator)).Methods("GET", "OPTIONS")
 	router.HandleFunc("/ws", wsHub.HandleWebSocket)
 
+	// Anything not handled above falls through to the legacy system, except
+	// the prefixes already migrated to the Order Service
+	if getEnv("PASSTHROUGH_ENABLED", "true") == "true" {
+		passthroughProxy, err := passthrough.New(sapURL, orderServiceURL, splitList(getEnv("PASSTHROUGH_ORDER_SERVICE_PREFIXES", "")), cbManager, logger)
+		if err != nil {
+			logger.WithError(err).Fatal("Invalid passthrough configuration")
+		}
+		logger.WithFields(logrus.Fields(passthroughProxy.Routes())).Info("Passthrough to legacy routes enabled")
+		// Paths the proxy owns are not forwarded for other methods
+		unowned := passthrough.ExceptOwnedRoutes(router, passthroughProxy)
+		// Registered last so every explicit route above takes precedence
+		router.PathPrefix("/").Handler(unowned)
+	}
+
 	router.Use(corsMiddleware())
 	router.Use(loggingMiddleware(logger))
 
@@ -86,6 +181,7 @@ func main() {
 	<-sigChan
 
 	logger.Info("Shutting down server...")
+	stopSagas()
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -109,15 +205,34 @@ func loggingMiddleware(logger *logrus.Logger) mux.MiddlewareFunc {
 
 			next.ServeHTTP(w, r)
 
+			// Passthrough responses name their backend; everything else was
+			// answered by the proxy's own handlers
+			backend := w.Header().Get(passthrough.ServedByHeader)
+			if backend == "" {
+				backend = "proxy"
+			}
+
 			logger.WithFields(logrus.Fields{
 				"method":   r.Method,
 				"path":     r.URL.Path,
+				"backend":  backend,
 				"duration": time.Since(start).Milliseconds(),
 			}).Info("Request completed")
 		})
 	}
 }
 
+// splitList parses a comma-separated environment value, skipping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -131,7 +246,8 @@ func corsMiddleware() mux.MiddlewareFunc {
 			// Allow all origins for development
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, If-Match, X-Actor")
+			w.Header().Set("Access-Control-Expose-Headers", "X-Data-Source, Idempotent-Replayed, X-Served-By, ETag")
 
 			// Handle preflight requests
 			if r.Method == "OPTIONS" {
@@ -144,7 +260,7 @@ func corsMiddleware() mux.MiddlewareFunc {
 	}
 }
 
-func allServicesHealthCheck(sapClient *sap.Client, orderServiceClient *orders.OrderServiceClient, cbManager *circuitbreaker.Manager, logger *logrus.Logger) http.HandlerFunc {
+func allServicesHealthCheck(sapClient *sap.Client, orderServiceClient *orders.OrderServiceClient, cbManager *circuitbreaker.Manager, canaryRouter *orders.CanaryRouter, phaseManager *orders.PhaseManager, logger *logrus.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		healthStatus := make(map[string]interface{})
 
@@ -162,7 +278,7 @@ func allServicesHealthCheck(sapClient *sap.Client, orderServiceClient *orders.Or
 			orderServiceCB := cbManager.Get("order-service")
 
 			// Try to get orders to check if service is healthy
-			_, err := orderServiceClient.GetOrders()
+			_, err := orderServiceClient.GetOrders(models.OrderQuery{Limit: 1})
 			responseTime := time.Since(start).Milliseconds()
 
 			cbState := "unknown"
@@ -212,7 +328,7 @@ func allServicesHealthCheck(sapClient *sap.Client, orderServiceClient *orders.Or
 		// Check SAP health
 		start := time.Now()
 		sapCB := cbManager.Get("sap")
-		_, err := sapClient.GetOrders()
+		_, err := sapClient.GetOrders(models.OrderQuery{Limit: 1})
 		responseTime := time.Since(start).Milliseconds()
 
 		cbState := "unknown"
@@ -247,6 +363,9 @@ func allServicesHealthCheck(sapClient *sap.Client, orderServiceClient *orders.Or
 			}
 		}
 
+		healthStatus["routing"] = canaryRouter.Metrics()
+		healthStatus["migration_phase"] = phaseManager.Status()
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(healthStatus)
 	}
@@ -305,6 +424,184 @@ func resetCircuitBreaker(cbManager *circuitbreaker.Manager, logger *logrus.Logge
 	}
 }
 
+func getCanaryWeight(canaryRouter *orders.CanaryRouter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"routing":   canaryRouter.Metrics(),
+			"timestamp": time.Now().Format(time.RFC3339),
+		})
+	}
+}
+
+func setCanaryWeight(canaryRouter *orders.CanaryRouter, orderServiceConfigured bool, wsHub *websocket.Hub, logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Weight *int `json:"weight"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Weight == nil {
+			http.Error(w, "Request body must contain a weight", http.StatusBadRequest)
+			return
+		}
+
+		if *req.Weight > 0 && !orderServiceConfigured {
+			http.Error(w, "Order service not configured - weight must be 0", http.StatusBadRequest)
+			return
+		}
+
+		if err := canaryRouter.SetWeight(*req.Weight); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		logger.WithField("weight", *req.Weight).Info("Canary weight changed via API")
+		wsHub.Broadcast("canary_weight_changed", canaryRouter.Metrics(), "proxy")
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":   true,
+			"message":   "Canary weight updated",
+			"routing":   canaryRouter.Metrics(),
+			"timestamp": time.Now().Format(time.RFC3339),
+		})
+	}
+}
+
+func getPhase(phaseManager *orders.PhaseManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"migration_phase": phaseManager.Status(),
+			"timestamp":       time.Now().Format(time.RFC3339),
+		})
+	}
+}
+
+func setPhase(phaseManager *orders.PhaseManager, logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Phase  string `json:"phase"`
+			Reason string `json:"reason"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Phase == "" {
+			http.Error(w, "Request body must contain a phase", http.StatusBadRequest)
+			return
+		}
+
+		if req.Reason == "" {
+			req.Reason = "admin API"
+		}
+
+		if err := phaseManager.SetPhase(orders.Phase(req.Phase), req.Reason); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		logger.WithField("phase", req.Phase).Info("Migration phase changed via API")
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":         true,
+			"message":         "Migration phase updated",
+			"migration_phase": phaseManager.Status(),
+			"timestamp":       time.Now().Format(time.RFC3339),
+		})
+	}
+}
+
+func getRoutingRules(ruleEngine *orders.RuleEngine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"routing_rules": ruleEngine.Status(),
+			"timestamp":     time.Now().Format(time.RFC3339),
+		})
+	}
+}
+
+func reloadRoutingRules(ruleEngine *orders.RuleEngine, wsHub *websocket.Hub, logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := ruleEngine.Reload(); err != nil {
+			logger.WithError(err).Error("Failed to reload routing rules")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+
+		logger.Info("Routing rules reloaded via API")
+		wsHub.Broadcast("routing_rules_reloaded", ruleEngine.Status(), "proxy")
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":       true,
+			"message":       "Routing rules reloaded",
+			"routing_rules": ruleEngine.Status(),
+			"timestamp":     time.Now().Format(time.RFC3339),
+		})
+	}
+}
+
+func shadowReport(shadowStore *orders.ShadowStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"shadow":    shadowStore.Report(),
+			"timestamp": time.Now().Format(time.RFC3339),
+		})
+	}
+}
+
+func getSagas(sagaCoordinator *orders.SagaCoordinator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if sagaCoordinator == nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "Dual write is not available without the order service",
+			})
+			return
+		}
+
+		includeCompleted := r.URL.Query().Get("include_completed") == "true"
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"sagas":     sagaCoordinator.Report(includeCompleted),
+			"timestamp": time.Now().Format(time.RFC3339),
+		})
+	}
+}
+
+func getSaga(sagaCoordinator *orders.SagaCoordinator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var saga *orders.Saga
+		exists := false
+		if sagaCoordinator != nil {
+			saga, exists = sagaCoordinator.Get(mux.Vars(r)["id"])
+		}
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "Saga not found",
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"saga":      saga,
+			"timestamp": time.Now().Format(time.RFC3339),
+		})
+	}
+}
+
 func parseIntWithDefault(envVar, defaultValue string, logger *logrus.Logger) int {
 	value := getEnv(envVar, defaultValue)
 	parsed, err := strconv.Atoi(value)
@@ -382,7 +679,24 @@ func sapClientWithCircuitBreaker(baseURL string, logger *logrus.Logger, cbManage
 	return sap.NewClient(baseURL, logger, cbManager)
 }
 
-func orderServiceClientWithCircuitBreaker(baseURL string, logger *logrus.Logger, cbManager *circuitbreaker.Manager, config circuitbreaker.Config) *orders.OrderServiceClient {
+// orderServiceClientWithCircuitBreaker talks to the Order Service over the
+// transport named by ORDER_SERVICE_TRANSPORT: http (the default) or grpc.
+// With grpc, creates, gets and listings go to ORDER_SERVICE_GRPC_ADDR and
+// the other calls still go to baseURL.
+func orderServiceClientWithCircuitBreaker(baseURL string, logger *logrus.Logger, cbManager *circuitbreaker.Manager, config circuitbreaker.Config) (*orders.OrderServiceClient, error) {
 	cbManager.GetOrCreate("order-service", config)
-	return orders.NewOrderServiceClient(baseURL, logger, cbManager)
+
+	switch transport := getEnv("ORDER_SERVICE_TRANSPORT", "http"); transport {
+	case "http":
+		return orders.NewOrderServiceClient(baseURL, logger, cbManager), nil
+	case "grpc":
+		grpcAddr := getEnv("ORDER_SERVICE_GRPC_ADDR", "")
+		if grpcAddr == "" {
+			return nil, fmt.Errorf("ORDER_SERVICE_GRPC_ADDR is required with ORDER_SERVICE_TRANSPORT=grpc")
+		}
+		logger.WithField("address", grpcAddr).Info("Order service client uses gRPC")
+		return orders.NewGRPCOrderServiceClient(baseURL, grpcAddr, logger, cbManager)
+	default:
+		return nil, fmt.Errorf("ORDER_SERVICE_TRANSPORT must be http or grpc, got %q", transport)
+	}
 }
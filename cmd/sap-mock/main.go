@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net/http"
@@ -15,20 +16,67 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/jogardn/strangler-demo/internal/events"
+	"github.com/jogardn/strangler-demo/internal/sap"
 	"github.com/jogardn/strangler-demo/pkg/models"
 	"github.com/sirupsen/logrus"
 )
 
 // In-memory storage for SAP orders
 type SAPOrderStore struct {
-	orders map[string]*models.Order
-	mutex  sync.RWMutex
+	orders  map[string]*sap.OrderRecord
+	mutex   sync.RWMutex
+	results *events.KafkaProducer
+	logger  *logrus.Logger
+	// converter books order totals in the company code currency. Without a
+	// rate table, totals are not converted
+	converter *sap.Converter
 }
 
+// errOrderNotReceived is returned for events about an order SAP has not
+// received yet. Events on different topics are not ordered, so it is retried.
+var errOrderNotReceived = errors.New("order not yet received by SAP")
+
 func NewSAPOrderStore() *SAPOrderStore {
 	return &SAPOrderStore{
-		orders: make(map[string]*models.Order),
+		orders: make(map[string]*sap.OrderRecord),
+	}
+}
+
+// store records an order with its SAP document ID and receipt time
+func (s *SAPOrderStore) store(order *models.Order) *sap.OrderRecord {
+	record := &sap.OrderRecord{
+		Order:      *order,
+		DocumentID: sapDocumentID(order.ID),
+		ReceivedAt: time.Now(),
 	}
+
+	s.mutex.Lock()
+	s.orders[order.ID] = record
+	s.mutex.Unlock()
+
+	return record
+}
+
+// companyCodeAmount converts the order total into the company code
+// currency. A conversion announced by the Order Service in that currency is
+// booked as is, so both sides agree on the rate.
+func (s *SAPOrderStore) companyCodeAmount(order *models.Order, announced *models.ConvertedAmount) (*models.ConvertedAmount, error) {
+	if s.converter != nil && announced != nil && announced.Currency == s.converter.Currency() {
+		converted := *announced
+		return &converted, nil
+	}
+	converted, err := s.converter.ConvertTotal(order)
+	if err != nil {
+		return nil, fmt.Errorf("SAP cannot book: %w", err)
+	}
+	return converted, nil
+}
+
+func sapDocumentID(orderID string) string {
+	if len(orderID) > 8 {
+		orderID = orderID[:8]
+	}
+	return fmt.Sprintf("SAP-%s", orderID)
 }
 
 // Configuration for failure simulation
@@ -42,8 +90,8 @@ var sapConfig = &SAPConfig{
 	SimulateOutage: false,
 }
 
-// Implement RetryableOrderEventHandler interface
-func (s *SAPOrderStore) HandleOrderCreated(event events.OrderCreatedEvent) error {
+// simulatedFailure applies the configured outage and failure rate
+func simulatedFailure() error {
 	// Check if we should simulate an outage
 	if sapConfig.SimulateOutage {
 		return fmt.Errorf("SAP system unavailable - simulated outage")
@@ -54,6 +102,15 @@ func (s *SAPOrderStore) HandleOrderCreated(event events.OrderCreatedEvent) error
 		return fmt.Errorf("SAP processing failed - simulated random failure")
 	}
 
+	return nil
+}
+
+// Implement RetryableOrderEventHandler interface
+func (s *SAPOrderStore) HandleOrderCreated(event events.OrderCreatedEvent) error {
+	if err := simulatedFailure(); err != nil {
+		return err
+	}
+
 	// Simulate SAP processing delay
 	delay := time.Duration(rand.Intn(2000)+1000) * time.Millisecond
 	
@@ -72,18 +129,27 @@ func (s *SAPOrderStore) HandleOrderCreated(event events.OrderCreatedEvent) error
 
 	// Create order from event data
 	order := &models.Order{
-		ID:          event.OrderID,
-		CustomerID:  event.CustomerID,
-		TotalAmount: event.TotalAmount,
-		Status:      "confirmed",
-		CreatedAt:   event.CreatedAt,
-		Items:       []models.OrderItem{}, // Event only has summary data
+		ID:           event.OrderID,
+		CustomerID:   event.CustomerID,
+		TotalAmount:  event.TotalAmount,
+		Currency:     models.CurrencyOrDefault(event.Currency),
+		DeliveryDate: event.DeliveryDate,
+		Status:       "confirmed",
+		CreatedAt:    event.CreatedAt,
+		Items:        event.Items,
+		Version:      1,
+	}
+	if order.Items == nil {
+		order.Items = []models.OrderItem{} // Older events only carry summary data
 	}
+	converted, err := s.companyCodeAmount(order, event.ConvertedAmount)
+	if err != nil {
+		return err
+	}
+	order.CompanyCodeAmount = converted
 
 	// Store the order
-	s.mutex.Lock()
-	s.orders[order.ID] = order
-	s.mutex.Unlock()
+	s.store(order)
 
 	logger.WithFields(logrus.Fields{
 		"order_id":     order.ID,
@@ -95,8 +161,142 @@ func (s *SAPOrderStore) HandleOrderCreated(event events.OrderCreatedEvent) error
 	return nil
 }
 
+// HandleOrderStatusChanged mirrors status changes made in the Order Service
+func (s *SAPOrderStore) HandleOrderStatusChanged(event events.OrderStatusChangedEvent) error {
+	if err := simulatedFailure(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	record, exists := s.orders[event.OrderID]
+	if !exists {
+		s.mutex.Unlock()
+		return errOrderNotReceived
+	}
+	previous := record.Status
+	record.Status = event.ToStatus
+	s.mutex.Unlock()
+
+	s.logger.WithFields(logrus.Fields{
+		"order_id":    event.OrderID,
+		"from_status": previous,
+		"to_status":   event.ToStatus,
+	}).Info("Order status updated in SAP")
+
+	return nil
+}
+
+// HandleOrderUpdated applies the changed fields of an order update. Updates
+// at or below the version SAP already holds are redeliveries and skipped.
+func (s *SAPOrderStore) HandleOrderUpdated(event events.OrderUpdatedEvent) error {
+	if err := simulatedFailure(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	record, exists := s.orders[event.OrderID]
+	if !exists {
+		s.mutex.Unlock()
+		return errOrderNotReceived
+	}
+	if event.Version <= record.Version {
+		s.mutex.Unlock()
+		s.logger.WithFields(logrus.Fields{
+			"order_id":    event.OrderID,
+			"version":     event.Version,
+			"sap_version": record.Version,
+		}).Info("Order update already applied in SAP")
+		return nil
+	}
+
+	if event.DeliveryDate != nil {
+		record.DeliveryDate = *event.DeliveryDate
+	}
+	if event.Items != nil {
+		record.Items = event.Items
+	}
+	if event.TotalAmount != nil {
+		changed := record.Order
+		changed.TotalAmount = *event.TotalAmount
+		converted, err := s.companyCodeAmount(&changed, event.ConvertedAmount)
+		if err != nil {
+			s.mutex.Unlock()
+			return err
+		}
+		record.TotalAmount = changed.TotalAmount
+		record.CompanyCodeAmount = converted
+	}
+	record.Version = event.Version
+	s.mutex.Unlock()
+
+	s.logger.WithFields(logrus.Fields{
+		"order_id":       event.OrderID,
+		"version":        event.Version,
+		"changed_fields": event.ChangedFields,
+	}).Info("Order updated in SAP")
+
+	return nil
+}
+
+// HandleOrderCancelled cancels the order unless it has already shipped, and
+// reports the outcome back to the Order Service
+func (s *SAPOrderStore) HandleOrderCancelled(event events.OrderCancelledEvent) error {
+	if err := simulatedFailure(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	record, exists := s.orders[event.OrderID]
+	if !exists {
+		s.mutex.Unlock()
+		return errOrderNotReceived
+	}
+
+	result := events.OrderCancellationResultEvent{
+		OrderID:     event.OrderID,
+		Accepted:    true,
+		ProcessedAt: time.Now(),
+	}
+	if record.Status != models.StatusCancelled {
+		// A redelivered request for an order that is already cancelled is
+		// confirmed again so a lost result can be recovered
+		if err := models.ValidateTransition(record.Status, models.StatusCancelled); err != nil {
+			result.Accepted = false
+			result.Message = fmt.Sprintf("SAP cannot cancel order in status %s", record.Status)
+		} else {
+			record.Status = models.StatusCancelled
+		}
+	}
+	result.SAPStatus = record.Status
+	s.mutex.Unlock()
+
+	if result.Accepted {
+		result.Message = fmt.Sprintf("Order cancelled in SAP (reason: %s)", event.ReasonCode)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"order_id":    event.OrderID,
+		"reason_code": event.ReasonCode,
+		"accepted":    result.Accepted,
+		"sap_status":  result.SAPStatus,
+	}).Info("Cancellation processed in SAP")
+
+	if err := s.results.PublishCancellationResult(result); err != nil {
+		return fmt.Errorf("%w: %v", errResultNotPublished, err)
+	}
+	return nil
+}
+
+// errResultNotPublished is retried: the cancellation is idempotent, so a
+// retry publishes the same result again
+var errResultNotPublished = errors.New("SAP unavailable - cancellation result not published")
+
 // IsRetryable determines if an error should trigger a retry
 func (s *SAPOrderStore) IsRetryable(err error) bool {
+	if errors.Is(err, errOrderNotReceived) || errors.Is(err, errResultNotPublished) {
+		return true
+	}
+
 	// System unavailable errors are retryable
 	if strings.Contains(err.Error(), "unavailable") {
 		return true
@@ -122,16 +322,37 @@ func main() {
 
 	// Create order store
 	store := NewSAPOrderStore()
+	store.logger = logger
+
+	// Orders are booked in the company code currency, converted with the
+	// rate table
+	var err error
+	store.converter, err = sap.LoadConverter(getEnv("EXCHANGE_RATES_FILE", ""), getEnv("COMPANY_CODE_CURRENCY", ""))
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to load exchange rates")
+	}
+	if store.converter != nil {
+		logger.WithField("company_code_currency", store.converter.Currency()).Info("Booking orders in the company code currency")
+	}
 
 	// Start Kafka consumer with retry logic
 	kafkaBrokers := getEnv("KAFKA_BROKERS", "localhost:9092")
 	logger.WithField("brokers", kafkaBrokers).Info("Initializing Kafka consumer with retry support...")
 	
 	var consumer *events.KafkaConsumerWithRetry
-	var err error
 	
 	// Retry connecting to Kafka
 	for i := 0; i < 10; i++ {
+		// Cancellation results are published back to the Order Service
+		if store.results == nil {
+			store.results, err = events.NewKafkaProducer(kafkaBrokers, logger)
+			if err != nil {
+				logger.WithError(err).WithField("attempt", i+1).Warn("Failed to connect to Kafka, retrying...")
+				time.Sleep(5 * time.Second)
+				continue
+			}
+		}
+
 		consumer, err = events.NewKafkaConsumerWithRetry(kafkaBrokers, "sap-consumer-group", store, logger)
 		if err == nil {
 			logger.Info("Successfully connected to Kafka with retry support")
@@ -163,6 +384,7 @@ func main() {
 	router.HandleFunc("/orders", createOrder(logger, store)).Methods("POST") // Legacy endpoint
 	router.HandleFunc("/orders", listOrders(logger, store)).Methods("GET")
 	router.HandleFunc("/orders/{id}", getOrder(logger, store)).Methods("GET")
+	router.HandleFunc("/orders/{id}", voidOrder(logger, store)).Methods("DELETE")
 	
 	// Failure simulation endpoints
 	router.HandleFunc("/admin/failure-rate", setFailureRate(logger)).Methods("POST")
@@ -203,6 +425,9 @@ func main() {
 	if err := consumer.Close(); err != nil {
 		logger.WithError(err).Error("Failed to close Kafka consumer")
 	}
+	if err := store.results.Close(); err != nil {
+		logger.WithError(err).Error("Failed to close Kafka producer")
+	}
 
 	// Cancel consumer context
 	cancel()
@@ -236,16 +461,21 @@ func createOrder(logger *logrus.Logger, store *SAPOrderStore) http.HandlerFunc {
 		time.Sleep(delay)
 
 		order.Status = "confirmed"
-		sapOrderID := fmt.Sprintf("SAP-%s", order.ID[:8])
+		order.Currency = models.CurrencyOrDefault(order.Currency)
+		converted, err := store.companyCodeAmount(&order, nil)
+		if err != nil {
+			logger.WithError(err).WithField("order_id", order.ID).Warn("Order cannot be converted into the company code currency")
+			respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		order.CompanyCodeAmount = converted
 
 		// Store the order in memory
-		store.mutex.Lock()
-		store.orders[order.ID] = &order
-		store.mutex.Unlock()
+		record := store.store(&order)
 
 		response := models.OrderResponse{
 			Success: true,
-			Message: fmt.Sprintf("Order created in SAP with ID: %s", sapOrderID),
+			Message: fmt.Sprintf("Order created in SAP with ID: %s", record.DocumentID),
 			Order:   &order,
 		}
 
@@ -260,22 +490,39 @@ func createOrder(logger *logrus.Logger, store *SAPOrderStore) http.HandlerFunc {
 	}
 }
 
+// listOrders returns one page of orders, filtered, sorted and paged like the
+// Order Service listing.
 func listOrders(logger *logrus.Logger, store *SAPOrderStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		query, err := models.ParseOrderQuery(r.URL.Query())
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
 		store.mutex.RLock()
-		orders := make([]*models.Order, 0, len(store.orders))
-		for _, order := range store.orders {
-			orders = append(orders, order)
+		records := make([]*sap.OrderRecord, 0, len(store.orders))
+		for _, record := range store.orders {
+			records = append(records, record)
 		}
+		orders, nextCursor, err := models.PageOrders(records, func(record *sap.OrderRecord) *models.Order {
+			return &record.Order
+		}, query)
 		store.mutex.RUnlock()
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 
 		logger.WithField("count", len(orders)).Info("Retrieved orders from SAP")
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": true,
-			"orders":  orders,
-			"count":   len(orders),
+			"success":     true,
+			"orders":      orders,
+			"count":       len(orders),
+			"next_cursor": nextCursor,
+			"has_more":    nextCursor != "",
 		})
 	}
 }
@@ -286,7 +533,7 @@ func getOrder(logger *logrus.Logger, store *SAPOrderStore) http.HandlerFunc {
 		orderID := vars["id"]
 
 		store.mutex.RLock()
-		order, exists := store.orders[orderID]
+		record, exists := store.orders[orderID]
 		store.mutex.RUnlock()
 
 		if !exists {
@@ -298,7 +545,35 @@ func getOrder(logger *logrus.Logger, store *SAPOrderStore) http.HandlerFunc {
 		logger.WithField("order_id", orderID).Info("Retrieved order from SAP")
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(order)
+		json.NewEncoder(w).Encode(record)
+	}
+}
+
+// voidOrder removes an order, compensating a dual write the proxy could not
+// complete in the Order Service
+func voidOrder(logger *logrus.Logger, store *SAPOrderStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		orderID := vars["id"]
+
+		store.mutex.Lock()
+		_, exists := store.orders[orderID]
+		delete(store.orders, orderID)
+		store.mutex.Unlock()
+
+		if !exists {
+			logger.WithField("order_id", orderID).Warn("Order to void not found in SAP")
+			respondWithError(w, http.StatusNotFound, "Order not found")
+			return
+		}
+
+		logger.WithField("order_id", orderID).Info("Order voided in SAP")
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.OrderResponse{
+			Success: true,
+			Message: fmt.Sprintf("Order %s voided in SAP", orderID),
+		})
 	}
 }
 
@@ -261,7 +261,7 @@ export function OrdersTable({
                   </span>
                 </td>
                 <td className="p-3 font-medium">
-                  ${order.total_amount.toFixed(2)}
+                  {order.total_amount.toFixed(2)} {order.currency ?? 'USD'}
                 </td>
                 <td className="p-3 text-sm text-muted-foreground">
                   {order.items.length} item{order.items.length !== 1 ? 's' : ''}
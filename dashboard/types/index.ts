@@ -3,6 +3,7 @@ export interface Order {
   customer_id: string;
   items: OrderItem[];
   total_amount: number;
+  currency?: string;
   delivery_date: string;
   status: string;
   created_at: string;
@@ -236,8 +236,21 @@ func (da *DataAnalyzer) compareFields(osOrder, sapOrder *models.Order) []DataInc
 		})
 	}
 
-	// Compare total amount
-	if math.Abs(osOrder.TotalAmount-sapOrder.TotalAmount) > 0.01 {
+	// Compare total amount in the order's own currency. SAP's company code
+	// amount is converted from it, so it is not compared
+	if !sameCurrency(osOrder, sapOrder) {
+		inconsistencies = append(inconsistencies, DataInconsistency{
+			OrderID:     osOrder.ID,
+			Type:        "field_mismatch",
+			Severity:    "critical",
+			Field:       "currency",
+			OSValue:     models.CurrencyOrDefault(osOrder.Currency),
+			SAPValue:    models.CurrencyOrDefault(sapOrder.Currency),
+			Description: "Currency mismatch between systems",
+			Impact:      "Financial discrepancy, amounts cannot be compared",
+			Suggestion:  "Check that the order currency is passed to SAP unchanged",
+		})
+	} else if osOrder.TotalAmount != sapOrder.TotalAmount {
 		inconsistencies = append(inconsistencies, DataInconsistency{
 			OrderID:     osOrder.ID,
 			Type:        "field_mismatch",
@@ -292,10 +305,17 @@ func (da *DataAnalyzer) compareFields(osOrder, sapOrder *models.Order) []DataInc
 func (da *DataAnalyzer) isExactMatch(osOrder, sapOrder *models.Order) bool {
 	return osOrder.ID == sapOrder.ID &&
 		osOrder.CustomerID == sapOrder.CustomerID &&
-		math.Abs(osOrder.TotalAmount-sapOrder.TotalAmount) < 0.01 &&
+		sameCurrency(osOrder, sapOrder) &&
+		osOrder.TotalAmount == sapOrder.TotalAmount &&
 		len(osOrder.Items) == len(sapOrder.Items)
 }
 
+// sameCurrency reports whether both orders are in the same currency. Orders
+// stored before orders had a currency are in models.DefaultCurrency.
+func sameCurrency(osOrder, sapOrder *models.Order) bool {
+	return models.CurrencyOrDefault(osOrder.Currency) == models.CurrencyOrDefault(sapOrder.Currency)
+}
+
 func (da *DataAnalyzer) compareOrderFields(osOrder, sapOrder *models.Order) []DataMismatch {
 	var mismatches []DataMismatch
 
@@ -308,7 +328,14 @@ func (da *DataAnalyzer) compareOrderFields(osOrder, sapOrder *models.Order) []Da
 		})
 	}
 
-	if math.Abs(osOrder.TotalAmount-sapOrder.TotalAmount) > 0.01 {
+	if !sameCurrency(osOrder, sapOrder) {
+		mismatches = append(mismatches, DataMismatch{
+			OrderID:  osOrder.ID,
+			Field:    "currency",
+			OSValue:  models.CurrencyOrDefault(osOrder.Currency),
+			SAPValue: models.CurrencyOrDefault(sapOrder.Currency),
+		})
+	} else if osOrder.TotalAmount != sapOrder.TotalAmount {
 		mismatches = append(mismatches, DataMismatch{
 			OrderID:    osOrder.ID,
 			Field:      "total_amount",
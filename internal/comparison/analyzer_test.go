@@ -0,0 +1,62 @@
+package comparison
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jogardn/strangler-demo/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+func testAnalyzer() *DataAnalyzer {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewDataAnalyzer(logger)
+}
+
+func testOrder(id string, amount models.Money, currency string) models.Order {
+	return models.Order{
+		ID:           id,
+		CustomerID:   "customer-1",
+		Items:        []models.OrderItem{{ProductID: "P-1", Quantity: 1, UnitPrice: amount}},
+		TotalAmount:  amount,
+		Currency:     currency,
+		DeliveryDate: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+		Status:       models.StatusConfirmed,
+	}
+}
+
+func TestCompareDataIgnoresCompanyCodeAmount(t *testing.T) {
+	osOrder := testOrder("order-1", 10000, "USD")
+	sapOrder := testOrder("order-1", 10000, "USD")
+	sapOrder.CompanyCodeAmount = &models.ConvertedAmount{Amount: 9200, Currency: "EUR"}
+	// Orders from before orders had a currency are in US dollars
+	legacy := testOrder("order-2", 2599, "")
+	sapLegacy := testOrder("order-2", 2599, "USD")
+
+	result := testAnalyzer().CompareData([]models.Order{osOrder, legacy}, []models.Order{sapOrder, sapLegacy})
+	if result.Analysis.PerfectMatches != 2 || len(result.Inconsistencies) != 0 {
+		t.Errorf("Expected converted amounts not to be mismatches, got %+v %+v", result.Analysis, result.Inconsistencies)
+	}
+}
+
+func TestCompareDataComparesAmountsExactly(t *testing.T) {
+	osOrders := []models.Order{testOrder("order-1", 10000, "USD"), testOrder("order-2", 10000, "USD")}
+	sapOrders := []models.Order{testOrder("order-1", 10001, "USD"), testOrder("order-2", 10000, "EUR")}
+
+	result := testAnalyzer().CompareData(osOrders, sapOrders)
+	fields := make(map[string]string)
+	for _, inconsistency := range result.Inconsistencies {
+		fields[inconsistency.OrderID] = inconsistency.Field
+	}
+	if len(result.Inconsistencies) != 2 || fields["order-1"] != "total_amount" || fields["order-2"] != "currency" {
+		t.Errorf("Expected a one cent total mismatch and a currency mismatch, got %+v", result.Inconsistencies)
+	}
+
+	for _, mismatch := range result.Analysis.DataMismatches {
+		if mismatch.Field == "total_amount" && mismatch.Difference != models.Money(-1) {
+			t.Errorf("Expected a difference of -0.01, got %v", mismatch.Difference)
+		}
+	}
+}
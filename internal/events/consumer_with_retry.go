@@ -18,15 +18,68 @@ const (
 	MaxRetryDelay       = 30 * time.Second
 )
 
+// RetryableEventHandler decides which processing errors are retried. A
+// handler also implements one or more of the per-topic interfaces below, and
+// the consumer subscribes to the topics of the interfaces it implements.
+type RetryableEventHandler interface {
+	IsRetryable(err error) bool
+}
+
 type RetryableOrderEventHandler interface {
 	HandleOrderCreated(event OrderCreatedEvent) error
 	IsRetryable(err error) bool
 }
 
+type OrderCreatedHandler interface {
+	HandleOrderCreated(event OrderCreatedEvent) error
+}
+
+type OrderStatusChangedHandler interface {
+	HandleOrderStatusChanged(event OrderStatusChangedEvent) error
+}
+
+type OrderCancelledHandler interface {
+	HandleOrderCancelled(event OrderCancelledEvent) error
+}
+
+type CancellationResultHandler interface {
+	HandleCancellationResult(event OrderCancellationResultEvent) error
+}
+
+type OrderUpdatedHandler interface {
+	HandleOrderUpdated(event OrderUpdatedEvent) error
+}
+
+// topicsFor lists the topics the handler can process.
+func topicsFor(handler RetryableEventHandler) []string {
+	var topics []string
+	if _, ok := handler.(OrderCreatedHandler); ok {
+		topics = append(topics, OrderCreatedTopic)
+	}
+	if _, ok := handler.(OrderStatusChangedHandler); ok {
+		topics = append(topics, OrderStatusChangedTopic)
+	}
+	if _, ok := handler.(OrderCancelledHandler); ok {
+		topics = append(topics, OrderCancelledTopic)
+	}
+	if _, ok := handler.(CancellationResultHandler); ok {
+		topics = append(topics, OrderCancellationResultTopic)
+	}
+	if _, ok := handler.(OrderUpdatedHandler); ok {
+		topics = append(topics, OrderUpdatedTopic)
+	}
+	return topics
+}
+
+// dlqTopicFor names the dead letter topic of a topic, e.g. order.created.dlq.
+func dlqTopicFor(topic string) string {
+	return topic + ".dlq"
+}
+
 type KafkaConsumerWithRetry struct {
 	consumerGroup sarama.ConsumerGroup
 	producer      sarama.SyncProducer
-	handler       RetryableOrderEventHandler
+	handler       RetryableEventHandler
 	logger        *logrus.Logger
 	topics        []string
 	metrics       *ConsumerMetrics
@@ -49,13 +102,20 @@ type MessageMetadata struct {
 }
 
 type consumerGroupHandlerWithRetry struct {
-	handler  RetryableOrderEventHandler
+	handler  RetryableEventHandler
 	producer sarama.SyncProducer
 	logger   *logrus.Logger
 	metrics  *ConsumerMetrics
 }
 
-func NewKafkaConsumerWithRetry(brokers, groupID string, handler RetryableOrderEventHandler, logger *logrus.Logger) (*KafkaConsumerWithRetry, error) {
+// NewKafkaConsumerWithRetry consumes every topic the handler has a Handle
+// method for. Each topic has its own dead letter topic.
+func NewKafkaConsumerWithRetry(brokers, groupID string, handler RetryableEventHandler, logger *logrus.Logger) (*KafkaConsumerWithRetry, error) {
+	topics := topicsFor(handler)
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("handler does not handle any event topic")
+	}
+
 	// Consumer config
 	consumerConfig := sarama.NewConfig()
 	consumerConfig.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
@@ -85,7 +145,7 @@ func NewKafkaConsumerWithRetry(brokers, groupID string, handler RetryableOrderEv
 		producer:      producer,
 		handler:       handler,
 		logger:        logger,
-		topics:        []string{OrderCreatedTopic},
+		topics:        topics,
 		metrics:       &ConsumerMetrics{},
 	}, nil
 }
@@ -180,9 +240,9 @@ func (h *consumerGroupHandlerWithRetry) handleMessageWithRetry(message *sarama.C
 	metadata := h.extractMetadata(message)
 	
 	// Unmarshal the event
-	var event OrderCreatedEvent
-	if err := json.Unmarshal(message.Value, &event); err != nil {
-		h.logger.WithError(err).Error("Failed to unmarshal order created event")
+	orderID, process, err := h.decode(message)
+	if err != nil {
+		h.logger.WithError(err).WithField("topic", message.Topic).Error("Failed to unmarshal event")
 		return err // Non-retryable error
 	}
 
@@ -192,10 +252,11 @@ func (h *consumerGroupHandlerWithRetry) handleMessageWithRetry(message *sarama.C
 	for attempt := 0; attempt <= metadata.RetryCount + MaxRetries; attempt++ {
 		if attempt > 0 {
 			h.logger.WithFields(logrus.Fields{
-				"order_id": event.OrderID,
+				"order_id": orderID,
+				"topic":    message.Topic,
 				"attempt":  attempt,
 				"delay":    retryDelay,
-			}).Info("Retrying order processing")
+			}).Info("Retrying event processing")
 			
 			time.Sleep(retryDelay)
 			h.metrics.RetryCount++
@@ -208,9 +269,12 @@ func (h *consumerGroupHandlerWithRetry) handleMessageWithRetry(message *sarama.C
 		}
 
 		// Attempt to process
-		err := h.handler.HandleOrderCreated(event)
+		err := process()
 		if err == nil {
-			h.logger.WithField("order_id", event.OrderID).Info("Successfully processed order after retries")
+			h.logger.WithFields(logrus.Fields{
+				"order_id": orderID,
+				"topic":    message.Topic,
+			}).Info("Successfully processed event")
 			return nil
 		}
 
@@ -220,10 +284,54 @@ func (h *consumerGroupHandlerWithRetry) handleMessageWithRetry(message *sarama.C
 			return err
 		}
 
-		h.logger.WithError(err).WithField("attempt", attempt+1).Warn("Retryable error processing order")
+		h.logger.WithError(err).WithField("attempt", attempt+1).Warn("Retryable error processing event")
 	}
 
-	return fmt.Errorf("exhausted retries for order %s", event.OrderID)
+	return fmt.Errorf("exhausted retries for %s event of order %s", message.Topic, orderID)
+}
+
+// decode unmarshals a message for its topic and returns the call that
+// processes it.
+func (h *consumerGroupHandlerWithRetry) decode(message *sarama.ConsumerMessage) (string, func() error, error) {
+	switch message.Topic {
+	case OrderCreatedTopic:
+		var event OrderCreatedEvent
+		if err := json.Unmarshal(message.Value, &event); err != nil {
+			return "", nil, err
+		}
+		handler := h.handler.(OrderCreatedHandler)
+		return event.OrderID, func() error { return handler.HandleOrderCreated(event) }, nil
+	case OrderStatusChangedTopic:
+		var event OrderStatusChangedEvent
+		if err := json.Unmarshal(message.Value, &event); err != nil {
+			return "", nil, err
+		}
+		handler := h.handler.(OrderStatusChangedHandler)
+		return event.OrderID, func() error { return handler.HandleOrderStatusChanged(event) }, nil
+	case OrderCancelledTopic:
+		var event OrderCancelledEvent
+		if err := json.Unmarshal(message.Value, &event); err != nil {
+			return "", nil, err
+		}
+		handler := h.handler.(OrderCancelledHandler)
+		return event.OrderID, func() error { return handler.HandleOrderCancelled(event) }, nil
+	case OrderCancellationResultTopic:
+		var event OrderCancellationResultEvent
+		if err := json.Unmarshal(message.Value, &event); err != nil {
+			return "", nil, err
+		}
+		handler := h.handler.(CancellationResultHandler)
+		return event.OrderID, func() error { return handler.HandleCancellationResult(event) }, nil
+	case OrderUpdatedTopic:
+		var event OrderUpdatedEvent
+		if err := json.Unmarshal(message.Value, &event); err != nil {
+			return "", nil, err
+		}
+		handler := h.handler.(OrderUpdatedHandler)
+		return event.OrderID, func() error { return handler.HandleOrderUpdated(event) }, nil
+	default:
+		return "", nil, fmt.Errorf("no handler for topic %s", message.Topic)
+	}
 }
 
 func (h *consumerGroupHandlerWithRetry) extractMetadata(message *sarama.ConsumerMessage) MessageMetadata {
@@ -262,8 +370,9 @@ func (h *consumerGroupHandlerWithRetry) sendToDLQ(message *sarama.ConsumerMessag
 	}
 
 	// Create DLQ message with original payload and metadata
+	dlqTopic := dlqTopicFor(message.Topic)
 	dlqMessage := &sarama.ProducerMessage{
-		Topic: OrderCreatedDLQTopic,
+		Topic: dlqTopic,
 		Key:   sarama.ByteEncoder(message.Key),
 		Value: sarama.ByteEncoder(message.Value),
 		Headers: []sarama.RecordHeader{
@@ -297,7 +406,7 @@ func (h *consumerGroupHandlerWithRetry) sendToDLQ(message *sarama.ConsumerMessag
 	}
 
 	h.logger.WithFields(logrus.Fields{
-		"dlq_topic":     OrderCreatedDLQTopic,
+		"dlq_topic":     dlqTopic,
 		"dlq_partition": partition,
 		"dlq_offset":    offset,
 		"original_key":  string(message.Key),
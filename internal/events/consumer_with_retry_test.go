@@ -0,0 +1,76 @@
+package events
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/IBM/sarama"
+)
+
+type cancellationOnlyHandler struct{}
+
+func (cancellationOnlyHandler) HandleCancellationResult(event OrderCancellationResultEvent) error {
+	return nil
+}
+
+func (cancellationOnlyHandler) IsRetryable(err error) bool { return false }
+
+type sapHandler struct {
+	cancellationOnlyHandler
+	cancelled []OrderCancelledEvent
+}
+
+func (h *sapHandler) HandleOrderCreated(event OrderCreatedEvent) error { return nil }
+
+func (h *sapHandler) HandleOrderCancelled(event OrderCancelledEvent) error {
+	h.cancelled = append(h.cancelled, event)
+	return nil
+}
+
+func TestTopicsForHandler(t *testing.T) {
+	topics := topicsFor(cancellationOnlyHandler{})
+	if !reflect.DeepEqual(topics, []string{OrderCancellationResultTopic}) {
+		t.Errorf("Expected only the cancellation result topic, got %v", topics)
+	}
+
+	topics = topicsFor(&sapHandler{})
+	expected := []string{OrderCreatedTopic, OrderCancelledTopic, OrderCancellationResultTopic}
+	if !reflect.DeepEqual(topics, expected) {
+		t.Errorf("Expected %v, got %v", expected, topics)
+	}
+}
+
+func TestDLQTopicFor(t *testing.T) {
+	if dlqTopicFor(OrderCreatedTopic) != OrderCreatedDLQTopic {
+		t.Errorf("Expected %s, got %s", OrderCreatedDLQTopic, dlqTopicFor(OrderCreatedTopic))
+	}
+	if dlqTopicFor(OrderCancelledTopic) != "order.cancelled.dlq" {
+		t.Errorf("Unexpected DLQ topic %s", dlqTopicFor(OrderCancelledTopic))
+	}
+}
+
+func TestDecodeDispatchesByTopic(t *testing.T) {
+	handler := &sapHandler{}
+	consumer := &consumerGroupHandlerWithRetry{handler: handler}
+
+	orderID, process, err := consumer.decode(&sarama.ConsumerMessage{
+		Topic: OrderCancelledTopic,
+		Value: []byte(`{"order_id":"order-1","reason_code":"customer_request"}`),
+	})
+	if err != nil {
+		t.Fatalf("Expected event to decode, got %v", err)
+	}
+	if orderID != "order-1" {
+		t.Errorf("Expected order-1, got %s", orderID)
+	}
+	if err := process(); err != nil {
+		t.Fatalf("Expected event to be processed, got %v", err)
+	}
+	if len(handler.cancelled) != 1 || handler.cancelled[0].ReasonCode != "customer_request" {
+		t.Errorf("Expected cancellation to reach the handler, got %v", handler.cancelled)
+	}
+
+	if _, _, err := consumer.decode(&sarama.ConsumerMessage{Topic: "unknown"}); err == nil {
+		t.Error("Expected an error for an unknown topic")
+	}
+}
@@ -5,21 +5,80 @@ import (
 	"time"
 
 	"github.com/IBM/sarama"
+	"github.com/jogardn/strangler-demo/pkg/models"
 	"github.com/sirupsen/logrus"
 )
 
 const (
-	OrderCreatedTopic = "order.created"
+	OrderCreatedTopic            = "order.created"
+	OrderStatusChangedTopic      = "order.status_changed"
+	OrderCancelledTopic          = "order.cancelled"
+	OrderCancellationResultTopic = "order.cancellation_result"
+	OrderUpdatedTopic            = "order.updated"
 )
 
+// OrderCreatedEvent carries the order total in the order's currency and,
+// when the Order Service has a rate table, converted into SAP's company code
+// currency. Events without a currency are in models.DefaultCurrency.
 type OrderCreatedEvent struct {
+	OrderID         string                  `json:"order_id"`
+	CustomerID      string                  `json:"customer_id"`
+	Items           []models.OrderItem      `json:"items,omitempty"`
+	TotalAmount     models.Money            `json:"total_amount"`
+	Currency        string                  `json:"currency,omitempty"`
+	ConvertedAmount *models.ConvertedAmount `json:"converted_amount,omitempty"`
+	DeliveryDate    time.Time               `json:"delivery_date"`
+	CreatedAt       time.Time               `json:"created_at"`
+	EventTime       time.Time               `json:"event_time"`
+}
+
+// OrderStatusChangedEvent is published for every allowed status transition.
+type OrderStatusChangedEvent struct {
+	OrderID    string    `json:"order_id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Reason     string    `json:"reason,omitempty"`
+	ChangedAt  time.Time `json:"changed_at"`
+	EventTime  time.Time `json:"event_time"`
+}
+
+// OrderCancelledEvent asks SAP to cancel an order. The Order Service only
+// marks the order cancelled once SAP reports the outcome.
+type OrderCancelledEvent struct {
 	OrderID     string    `json:"order_id"`
-	CustomerID  string    `json:"customer_id"`
-	TotalAmount float64   `json:"total_amount"`
-	CreatedAt   time.Time `json:"created_at"`
+	ReasonCode  string    `json:"reason_code"`
+	Note        string    `json:"note,omitempty"`
+	RequestedAt time.Time `json:"requested_at"`
 	EventTime   time.Time `json:"event_time"`
 }
 
+// OrderCancellationResultEvent is SAP's answer to an OrderCancelledEvent.
+type OrderCancellationResultEvent struct {
+	OrderID     string    `json:"order_id"`
+	Accepted    bool      `json:"accepted"`
+	SAPStatus   string    `json:"sap_status"`
+	Message     string    `json:"message,omitempty"`
+	ProcessedAt time.Time `json:"processed_at"`
+	EventTime   time.Time `json:"event_time"`
+}
+
+// OrderUpdatedEvent is published when an order's delivery date or items
+// change. Only the changed fields are set; Version is the order's version
+// after the update, so consumers can skip updates they already applied.
+type OrderUpdatedEvent struct {
+	OrderID       string             `json:"order_id"`
+	Version       int                `json:"version"`
+	ChangedFields []string           `json:"changed_fields"`
+	DeliveryDate  *time.Time         `json:"delivery_date,omitempty"`
+	Items         []models.OrderItem `json:"items,omitempty"`
+	TotalAmount   *models.Money      `json:"total_amount,omitempty"`
+	// ConvertedAmount is the new total in SAP's company code currency, set
+	// like in OrderCreatedEvent
+	ConvertedAmount *models.ConvertedAmount `json:"converted_amount,omitempty"`
+	UpdatedAt       time.Time               `json:"updated_at"`
+	EventTime       time.Time               `json:"event_time"`
+}
+
 type KafkaProducer struct {
 	producer sarama.SyncProducer
 	logger   *logrus.Logger
@@ -47,17 +106,43 @@ func NewKafkaProducer(brokers string, logger *logrus.Logger) (*KafkaProducer, er
 func (p *KafkaProducer) PublishOrderCreated(event OrderCreatedEvent) error {
 	// Set event time
 	event.EventTime = time.Now()
+	return p.publish(OrderCreatedTopic, event.OrderID, event)
+}
+
+func (p *KafkaProducer) PublishOrderStatusChanged(event OrderStatusChangedEvent) error {
+	event.EventTime = time.Now()
+	return p.publish(OrderStatusChangedTopic, event.OrderID, event)
+}
+
+func (p *KafkaProducer) PublishOrderCancelled(event OrderCancelledEvent) error {
+	event.EventTime = time.Now()
+	return p.publish(OrderCancelledTopic, event.OrderID, event)
+}
 
+func (p *KafkaProducer) PublishCancellationResult(event OrderCancellationResultEvent) error {
+	event.EventTime = time.Now()
+	return p.publish(OrderCancellationResultTopic, event.OrderID, event)
+}
+
+// publish sends an event keyed by order ID so all events of one order land
+// on the same partition and stay in order.
+func (p *KafkaProducer) publish(topic, orderID string, event interface{}) error {
 	// Marshal event
 	data, err := json.Marshal(event)
 	if err != nil {
 		return err
 	}
 
+	return p.PublishPayload(topic, orderID, data)
+}
+
+// PublishPayload sends an event that is already encoded, e.g. one read back
+// from the Order Service outbox.
+func (p *KafkaProducer) PublishPayload(topic, orderID string, data []byte) error {
 	// Create message
 	msg := &sarama.ProducerMessage{
-		Topic: OrderCreatedTopic,
-		Key:   sarama.StringEncoder(event.OrderID),
+		Topic: topic,
+		Key:   sarama.StringEncoder(orderID),
 		Value: sarama.ByteEncoder(data),
 	}
 
@@ -69,10 +154,10 @@ func (p *KafkaProducer) PublishOrderCreated(event OrderCreatedEvent) error {
 	}
 
 	p.logger.WithFields(logrus.Fields{
-		"topic":     OrderCreatedTopic,
+		"topic":     topic,
 		"partition": partition,
 		"offset":    offset,
-		"order_id":  event.OrderID,
+		"order_id":  orderID,
 	}).Info("Event published to Kafka")
 
 	return nil
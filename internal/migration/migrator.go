@@ -49,10 +49,18 @@ type MigrationError struct {
 }
 
 type MigrationStatistics struct {
-	OrdersPerSecond     float64 `json:"orders_per_second"`
-	AverageOrderSize    float64 `json:"average_order_size"`
-	LargestOrder        float64 `json:"largest_order"`
-	DataVolumeProcessed int64   `json:"data_volume_processed"`
+	OrdersPerSecond float64 `json:"orders_per_second"`
+	// OrderSizes is keyed by currency, since amounts in different
+	// currencies cannot be added up or compared
+	OrderSizes          map[string]OrderSizeStatistics `json:"order_sizes"`
+	DataVolumeProcessed int64                          `json:"data_volume_processed"`
+}
+
+// OrderSizeStatistics describes the totals of the orders in one currency.
+type OrderSizeStatistics struct {
+	OrderCount       int          `json:"order_count"`
+	AverageOrderSize models.Money `json:"average_order_size"`
+	LargestOrder     models.Money `json:"largest_order"`
 }
 
 func NewDataMigrator(orderServiceClient *orders.OrderServiceClient, sapClient *sap.Client, logger *logrus.Logger) *DataMigrator {
@@ -94,12 +102,12 @@ func (dm *DataMigrator) MigrateHistoricalOrders(ctx context.Context) (*Migration
 	}
 
 	// Get orders from both systems
-	osOrders, err := dm.orderServiceClient.GetOrders()
+	osOrders, err := models.CollectOrders(dm.orderServiceClient.GetOrders, models.OrderQuery{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get orders from Order Service: %w", err)
 	}
 
-	sapOrders, err := dm.sapClient.GetOrders()
+	sapOrders, err := models.CollectOrders(dm.sapClient.GetOrders, models.OrderQuery{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get orders from SAP: %w", err)
 	}
@@ -332,8 +340,10 @@ func (dm *DataMigrator) processBatchToOrderService(ctx context.Context, orders [
 		default:
 		}
 
-		// Create historical order without publishing events
-		_, err := dm.orderServiceClient.CreateOrderHistorical(&order)
+		// Create historical order without publishing events. The audit trail
+		// records it as copied by the migrator
+		order.Actor = "data-migrator"
+		_, err := dm.orderServiceClient.CreateOrderHistorical(&order, models.AuditSourceMigration)
 		if err != nil {
 			result.FailedMigrations++
 			result.ErrorDetails = append(result.ErrorDetails, MigrationError{
@@ -360,32 +370,29 @@ func (dm *DataMigrator) mergeResults(target, source *MigrationResult) {
 }
 
 func (dm *DataMigrator) calculateStatistics(result *MigrationResult, osOrders, sapOrders []models.Order) MigrationStatistics {
-	stats := MigrationStatistics{}
+	stats := MigrationStatistics{OrderSizes: make(map[string]OrderSizeStatistics)}
 
 	if result.ProcessingTime > 0 {
 		stats.OrdersPerSecond = float64(result.SuccessfulMigrations) / result.ProcessingTime.Seconds()
 	}
 
-	// Calculate average order size
-	var totalAmount float64
-	orderCount := len(osOrders) + len(sapOrders)
-	
-	for _, order := range osOrders {
-		totalAmount += order.TotalAmount
-		if order.TotalAmount > stats.LargestOrder {
-			stats.LargestOrder = order.TotalAmount
+	// Calculate order sizes per currency
+	totals := make(map[string]models.Money)
+	for _, orders := range [][]models.Order{osOrders, sapOrders} {
+		for _, order := range orders {
+			currency := models.CurrencyOrDefault(order.Currency)
+			sizes := stats.OrderSizes[currency]
+			sizes.OrderCount++
+			totals[currency] += order.TotalAmount
+			if order.TotalAmount > sizes.LargestOrder {
+				sizes.LargestOrder = order.TotalAmount
+			}
+			stats.OrderSizes[currency] = sizes
 		}
 	}
-	
-	for _, order := range sapOrders {
-		totalAmount += order.TotalAmount
-		if order.TotalAmount > stats.LargestOrder {
-			stats.LargestOrder = order.TotalAmount
-		}
-	}
-
-	if orderCount > 0 {
-		stats.AverageOrderSize = totalAmount / float64(orderCount)
+	for currency, sizes := range stats.OrderSizes {
+		sizes.AverageOrderSize = totals[currency].DividedBy(sizes.OrderCount)
+		stats.OrderSizes[currency] = sizes
 	}
 
 	// Estimate data volume (rough calculation)
@@ -398,12 +405,12 @@ func (dm *DataMigrator) ValidateMigration(ctx context.Context) (*ValidationResul
 	dm.logger.Info("Starting post-migration validation")
 
 	// Get fresh data from both systems
-	osOrders, err := dm.orderServiceClient.GetOrders()
+	osOrders, err := models.CollectOrders(dm.orderServiceClient.GetOrders, models.OrderQuery{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get orders from Order Service: %w", err)
 	}
 
-	sapOrders, err := dm.sapClient.GetOrders()
+	sapOrders, err := models.CollectOrders(dm.sapClient.GetOrders, models.OrderQuery{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get orders from SAP: %w", err)
 	}
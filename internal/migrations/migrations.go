@@ -0,0 +1,307 @@
+// Package migrations holds the Order Service schema as numbered SQL files
+// embedded in the binary. Applied versions are recorded in the
+// schema_migrations table, so every environment converges on the same schema
+// whether it started empty, from scripts/db/init.sql or from the tables the
+// service used to create on startup.
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockID identifies the advisory lock held while migrating, so replicas
+// starting together do not apply the same migration twice.
+const lockID = 7_246_318_001
+
+// Migration is one schema change: sql/NNNN_name.up.sql and its
+// sql/NNNN_name.down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum identifies the up script, so an edited migration that was already
+// applied can be detected.
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// All returns the embedded migrations ordered by version.
+func All() ([]Migration, error) {
+	return load(files, "sql")
+}
+
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// AppliedMigration is a row of schema_migrations.
+type AppliedMigration struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	Checksum  string    `json:"checksum"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// MigrationStatus describes one migration known to the binary or the
+// database.
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	// Modified is set when the embedded up script no longer matches the one
+	// that was applied
+	Modified bool `json:"modified,omitempty"`
+	// Unknown is set for versions applied by a newer binary
+	Unknown bool `json:"unknown,omitempty"`
+}
+
+// Applied reports whether the migration has been applied.
+func (s MigrationStatus) Applied() bool {
+	return s.AppliedAt != nil
+}
+
+// Migrator applies the embedded migrations to a database.
+type Migrator struct {
+	db         *sql.DB
+	logger     *logrus.Logger
+	migrations []Migration
+}
+
+func New(db *sql.DB, logger *logrus.Logger) (*Migrator, error) {
+	migrations, err := All()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, logger: logger, migrations: migrations}, nil
+}
+
+// Up applies every pending migration in order, each in its own transaction,
+// and returns how many were applied.
+func (m *Migrator) Up() (int, error) {
+	applied := 0
+	err := m.locked(func(conn *sql.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			err := m.apply(conn, migration, migration.Up, func(tx *sql.Tx) error {
+				_, err := tx.Exec(`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+					migration.Version, migration.Name, migration.Checksum())
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			m.logger.WithFields(logrus.Fields{
+				"version": migration.Version,
+				"name":    migration.Name,
+			}).Info("Applied migration")
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the latest steps applied migrations, newest first, and returns
+// how many were reverted.
+func (m *Migrator) Down(steps int) (int, error) {
+	reverted := 0
+	err := m.locked(func(conn *sql.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			err := m.apply(conn, migration, migration.Down, func(tx *sql.Tx) error {
+				_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			m.logger.WithFields(logrus.Fields{
+				"version": migration.Version,
+				"name":    migration.Name,
+			}).Info("Reverted migration")
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every migration with whether it has been applied, followed by
+// versions recorded in the database that this binary does not know.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.locked(func(conn *sql.Conn) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if applied, ok := done[migration.Version]; ok {
+				appliedAt := applied.AppliedAt
+				status.AppliedAt = &appliedAt
+				status.Modified = applied.Checksum != migration.Checksum()
+				delete(done, migration.Version)
+			}
+			statuses = append(statuses, status)
+		}
+
+		var unknown []MigrationStatus
+		for _, applied := range done {
+			appliedAt := applied.AppliedAt
+			unknown = append(unknown, MigrationStatus{
+				Version:   applied.Version,
+				Name:      applied.Name,
+				AppliedAt: &appliedAt,
+				Unknown:   true,
+			})
+		}
+		sort.Slice(unknown, func(i, j int) bool { return unknown[i].Version < unknown[j].Version })
+		statuses = append(statuses, unknown...)
+		return nil
+	})
+	return statuses, err
+}
+
+// apply runs a migration script and its bookkeeping in one transaction.
+func (m *Migrator) apply(conn *sql.Conn, migration Migration, script string, record func(*sql.Tx) error) error {
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(script); err != nil {
+		return fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// locked runs fn on a single connection holding the migration lock, after
+// making sure schema_migrations exists.
+func (m *Migrator) locked(fn func(*sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, lockID)
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum VARCHAR(64) NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func appliedVersions(conn *sql.Conn) (map[int]AppliedMigration, error) {
+	rows, err := conn.QueryContext(context.Background(),
+		`SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]AppliedMigration)
+	for rows.Next() {
+		var a AppliedMigration
+		if err := rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[a.Version] = a
+	}
+	return applied, rows.Err()
+}
@@ -0,0 +1,189 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+func TestEmbeddedMigrationsAreNumberedInOrder(t *testing.T) {
+	migrations, err := All()
+	if err != nil {
+		t.Fatalf("Failed to load embedded migrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("Expected embedded migrations")
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("Expected migration %d to have version %d, got %04d_%s", i, i+1, m.Version, m.Name)
+		}
+	}
+}
+
+func TestLoadRejectsIncompleteMigrations(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"missing down": {
+			"sql/0001_orders.up.sql": {Data: []byte("CREATE TABLE orders (id TEXT)")},
+		},
+		"mismatched names": {
+			"sql/0001_orders.up.sql":  {Data: []byte("CREATE TABLE orders (id TEXT)")},
+			"sql/0001_items.down.sql": {Data: []byte("DROP TABLE orders")},
+		},
+		"unexpected file": {
+			"sql/README.md": {Data: []byte("notes")},
+		},
+	}
+
+	for name, fsys := range tests {
+		if _, err := load(fsys, "sql"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadSortsByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0010_later.up.sql":     {Data: []byte("SELECT 10")},
+		"sql/0010_later.down.sql":   {Data: []byte("SELECT -10")},
+		"sql/0002_earlier.up.sql":   {Data: []byte("SELECT 2")},
+		"sql/0002_earlier.down.sql": {Data: []byte("SELECT -2")},
+	}
+
+	migrations, err := load(fsys, "sql")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Name != "earlier" || migrations[1].Down != "SELECT -10" {
+		t.Errorf("Expected earlier then later, got %+v", migrations)
+	}
+}
+
+// testMigrator returns a migrator on a fresh schema. It needs Postgres, so
+// the test is skipped unless ORDER_SERVICE_TEST_DSN is set to a key=value DSN.
+func testMigrator(t *testing.T) (*Migrator, *sql.DB) {
+	t.Helper()
+
+	dsn := os.Getenv("ORDER_SERVICE_TEST_DSN")
+	if dsn == "" {
+		t.Skip("ORDER_SERVICE_TEST_DSN not set")
+	}
+
+	schema := fmt.Sprintf("migrations_test_%d", time.Now().UnixNano())
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+		admin.Close()
+	})
+
+	db, err := sql.Open("postgres", dsn+" search_path="+schema)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	migrator, err := New(db, logger)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	return migrator, db
+}
+
+func TestUpDownRoundTrip(t *testing.T) {
+	migrator, db := testMigrator(t)
+	total := len(migrator.migrations)
+
+	applied, err := migrator.Up()
+	if err != nil || applied != total {
+		t.Fatalf("Expected %d migrations applied, got %d: %v", total, applied, err)
+	}
+	if applied, err := migrator.Up(); err != nil || applied != 0 {
+		t.Fatalf("Expected a second up to be a no-op, got %d: %v", applied, err)
+	}
+
+	// Every migration reverts cleanly and applies again
+	if reverted, err := migrator.Down(total); err != nil || reverted != total {
+		t.Fatalf("Expected %d migrations reverted, got %d: %v", total, reverted, err)
+	}
+	var tables int
+	db.QueryRow(`SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name <> 'schema_migrations'`).Scan(&tables)
+	if tables != 0 {
+		t.Errorf("Expected only schema_migrations after reverting everything, got %d other tables", tables)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Failed to re-apply migrations: %v", err)
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("Failed to read status: %v", err)
+	}
+	for _, status := range statuses {
+		if !status.Applied() || status.Modified || status.Unknown {
+			t.Errorf("Expected %04d_%s to be applied and unchanged, got %+v", status.Version, status.Name, status)
+		}
+	}
+}
+
+func TestUpAdoptsInitScriptSchema(t *testing.T) {
+	migrator, db := testMigrator(t)
+
+	// The tables scripts/db/init.sql used to create, without schema_migrations
+	_, err := db.Exec(`
+		CREATE TABLE orders (
+			id VARCHAR(255) PRIMARY KEY,
+			customer_id VARCHAR(255) NOT NULL,
+			total_amount DECIMAL(10,2) NOT NULL,
+			delivery_date TIMESTAMP NOT NULL,
+			status VARCHAR(50) NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		);
+		CREATE TABLE order_items (
+			id SERIAL PRIMARY KEY,
+			order_id VARCHAR(255) NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+			product_id VARCHAR(255) NOT NULL,
+			quantity INTEGER NOT NULL,
+			unit_price DECIMAL(10,2) NOT NULL,
+			specifications JSONB,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX idx_orders_created_at ON orders(created_at);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Expected migrations to adopt the existing tables, got %v", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO orders (id, customer_id, total_amount, delivery_date, status, created_at)
+		VALUES ('order-1', 'customer-1', 10, NOW(), 'pending', NOW());
+		INSERT INTO order_items (order_id, product_id, quantity, unit_price) VALUES ('order-1', 'P-1', 1, 10);
+		DELETE FROM orders WHERE id = 'order-1';
+	`)
+	if err != nil {
+		t.Fatalf("Expected deleting an order to cascade to its items, got %v", err)
+	}
+
+	var summaries int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM order_summaries`).Scan(&summaries); err != nil {
+		t.Errorf("Expected the order_summaries view, got %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+DROP VIEW IF EXISTS order_summaries;
+DROP INDEX IF EXISTS idx_order_items_product_id;
+
+ALTER TABLE order_items DROP CONSTRAINT IF EXISTS order_items_order_id_fkey;
+ALTER TABLE order_items ADD CONSTRAINT order_items_order_id_fkey
+    FOREIGN KEY (order_id) REFERENCES orders(id);
+
+ALTER TABLE order_items DROP COLUMN IF EXISTS created_at;
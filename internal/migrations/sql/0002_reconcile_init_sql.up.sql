@@ -0,0 +1,23 @@
+-- Bring databases created by the Order Service in line with the schema that
+-- scripts/db/init.sql used to create. Both kinds converge here.
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+ALTER TABLE order_items DROP CONSTRAINT IF EXISTS order_items_order_id_fkey;
+ALTER TABLE order_items ADD CONSTRAINT order_items_order_id_fkey
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_order_items_product_id ON order_items(product_id);
+
+CREATE OR REPLACE VIEW order_summaries AS
+SELECT
+    o.id,
+    o.customer_id,
+    o.total_amount,
+    o.delivery_date,
+    o.status,
+    o.created_at,
+    COUNT(oi.id) AS item_count,
+    SUM(oi.quantity) AS total_quantity
+FROM orders o
+LEFT JOIN order_items oi ON o.id = oi.order_id
+GROUP BY o.id, o.customer_id, o.total_amount, o.delivery_date, o.status, o.created_at;
@@ -0,0 +1,5 @@
+ALTER TABLE orders DROP COLUMN IF EXISTS cancellation_requested_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS cancellation_message;
+ALTER TABLE orders DROP COLUMN IF EXISTS cancellation_state;
+ALTER TABLE orders DROP COLUMN IF EXISTS cancellation_note;
+ALTER TABLE orders DROP COLUMN IF EXISTS cancellation_reason;
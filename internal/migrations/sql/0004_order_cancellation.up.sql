@@ -0,0 +1,5 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancellation_reason VARCHAR(50);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancellation_note TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancellation_state VARCHAR(20);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancellation_message TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancellation_requested_at TIMESTAMP;
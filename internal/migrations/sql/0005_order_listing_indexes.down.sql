@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS idx_orders_created_at ON orders(created_at);
+DROP INDEX IF EXISTS idx_orders_delivery_date_id;
+DROP INDEX IF EXISTS idx_orders_created_at_id;
+DROP INDEX IF EXISTS idx_orders_status;
@@ -0,0 +1,7 @@
+-- Keyset pagination sorts on (column, id)
+CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(status);
+CREATE INDEX IF NOT EXISTS idx_orders_created_at_id ON orders(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_orders_delivery_date_id ON orders(delivery_date, id);
+
+-- Superseded by idx_orders_created_at_id; only init.sql databases have it
+DROP INDEX IF EXISTS idx_orders_created_at;
@@ -0,0 +1,17 @@
+-- Events waiting to be published to Kafka. Pending rows are indexed on their
+-- own, so the relay's scan stays cheap however many sent rows are retained.
+CREATE TABLE IF NOT EXISTS order_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    topic VARCHAR(255) NOT NULL,
+    message_key VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_outbox_pending ON order_outbox(next_attempt_at, id) WHERE sent_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_order_outbox_pending_key ON order_outbox(message_key, id) WHERE sent_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_order_outbox_sent_at ON order_outbox(sent_at) WHERE sent_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Incremented by every change to an order and exposed as its ETag
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+-- Order search matches items by product and by specification containment
+-- (specifications @> '{"color":"blue"}'). jsonb_path_ops indexes only
+-- support @>, which keeps them smaller than the default GIN operator class.
+CREATE INDEX IF NOT EXISTS idx_order_items_specifications ON order_items USING GIN (specifications jsonb_path_ops);
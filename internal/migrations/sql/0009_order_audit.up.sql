@@ -0,0 +1,25 @@
+-- Every change to an order, written in the transaction that made it. Rows
+-- outlive their orders, so there is no foreign key, and the trigger below
+-- keeps them from being changed or removed.
+CREATE TABLE IF NOT EXISTS order_audit (
+    id BIGSERIAL PRIMARY KEY,
+    order_id VARCHAR(255) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    source VARCHAR(50) NOT NULL,
+    before_state JSONB,
+    after_state JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_audit_order_id ON order_audit(order_id, id);
+
+CREATE OR REPLACE FUNCTION order_audit_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'order_audit rows cannot be changed';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS order_audit_immutable ON order_audit;
+CREATE TRIGGER order_audit_immutable BEFORE UPDATE OR DELETE ON order_audit
+    FOR EACH ROW EXECUTE FUNCTION order_audit_immutable();
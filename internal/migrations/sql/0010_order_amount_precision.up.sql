@@ -0,0 +1,22 @@
+-- DECIMAL(10,2) tops out at 99,999,999.99. Widen amounts to the range the
+-- Order Service validates against. Postgres will not change the type of a
+-- column a view reads, so order_summaries is dropped and recreated as 0002
+-- defined it.
+DROP VIEW IF EXISTS order_summaries;
+
+ALTER TABLE orders ALTER COLUMN total_amount TYPE NUMERIC(18,2);
+ALTER TABLE order_items ALTER COLUMN unit_price TYPE NUMERIC(18,2);
+
+CREATE OR REPLACE VIEW order_summaries AS
+SELECT
+    o.id,
+    o.customer_id,
+    o.total_amount,
+    o.delivery_date,
+    o.status,
+    o.created_at,
+    COUNT(oi.id) AS item_count,
+    SUM(oi.quantity) AS total_quantity
+FROM orders o
+LEFT JOIN order_items oi ON o.id = oi.order_id
+GROUP BY o.id, o.customer_id, o.total_amount, o.delivery_date, o.status, o.created_at;
@@ -0,0 +1,2 @@
+-- Orders stored before orders had a currency were all in US dollars.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
@@ -0,0 +1,15 @@
+DROP VIEW IF EXISTS order_summaries;
+
+CREATE VIEW order_summaries AS
+SELECT
+    o.id,
+    o.customer_id,
+    o.total_amount,
+    o.delivery_date,
+    o.status,
+    o.created_at,
+    COUNT(oi.id) AS item_count,
+    SUM(oi.quantity) AS total_quantity
+FROM orders o
+LEFT JOIN order_items oi ON o.id = oi.order_id
+GROUP BY o.id, o.customer_id, o.total_amount, o.delivery_date, o.status, o.created_at;
@@ -0,0 +1,3 @@
+-- Orders stored before this migration have no hash; a conflict with one of
+-- them is treated as a retry, as it was before.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS request_hash CHAR(64);
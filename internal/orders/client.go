@@ -3,22 +3,69 @@ package orders
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"time"
 
+	ordersv1 "github.com/jogardn/strangler-demo/api/orders/v1"
 	"github.com/jogardn/strangler-demo/internal/circuitbreaker"
 	"github.com/jogardn/strangler-demo/pkg/models"
+	"github.com/jogardn/strangler-demo/pkg/validation"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
 )
 
+// ErrOrderNotFound is returned when the Order Service has no order with the requested ID.
+// A missing order is a normal answer, so it does not count as a circuit breaker failure.
+var ErrOrderNotFound = errors.New("order not found in order service")
+
+// ErrOrderConflict is returned when the Order Service refuses a change because of the
+// order's current state. Like a missing order, it does not count as a circuit breaker failure.
+var ErrOrderConflict = errors.New("order state conflict in order service")
+
+// ErrOrderVersionMismatch is returned when an update's If-Match no longer
+// matches the order's version in the Order Service.
+var ErrOrderVersionMismatch = errors.New("order has changed in order service")
+
+const (
+	// ActorHeader names who made a change, for the Order Service's audit
+	// trail. The proxy forwards it from the client's request.
+	ActorHeader = "X-Actor"
+	// ChangeSourceHeader tells the Order Service where a change comes from.
+	// Live changes sent by the proxy carry models.AuditSourceProxy;
+	// historical orders carry models.AuditSourceHistoricalImport,
+	// models.AuditSourceMigration, models.AuditSourceShadow or
+	// models.AuditSourceDualWrite.
+	ChangeSourceHeader = "X-Change-Source"
+)
+
+// setActor forwards who made a change, if known.
+func setActor(req *http.Request, actor string) {
+	if actor != "" {
+		req.Header.Set(ActorHeader, actor)
+	}
+}
+
+// setLiveChange marks a live change as sent by the proxy on behalf of
+// actor.
+func setLiveChange(req *http.Request, actor string) {
+	req.Header.Set(ChangeSourceHeader, models.AuditSourceProxy)
+	setActor(req, actor)
+}
+
 type OrderServiceClient struct {
 	baseURL        string
 	httpClient     *http.Client
 	logger         *logrus.Logger
 	circuitBreaker *circuitbreaker.CircuitBreaker
+	// grpc is set by NewGRPCOrderServiceClient. Creates, gets and listings
+	// then go over gRPC; everything else stays on REST
+	grpc     ordersv1.OrderServiceClient
+	grpcConn *grpc.ClientConn
 }
 
 func NewOrderServiceClient(baseURL string, logger *logrus.Logger, cbManager *circuitbreaker.Manager) *OrderServiceClient {
@@ -41,8 +88,12 @@ func NewOrderServiceClient(baseURL string, logger *logrus.Logger, cbManager *cir
 
 func (c *OrderServiceClient) CreateOrder(order *models.Order) (*models.OrderResponse, error) {
 	c.logger.WithField("order_id", order.ID).Info("Sending order to order service")
+	if c.grpc != nil {
+		return c.createOrderGRPC(order)
+	}
 
 	var orderResp *models.OrderResponse
+	var rejected error
 	err := c.circuitBreaker.Execute(func() error {
 		jsonData, err := json.Marshal(order)
 		if err != nil {
@@ -55,6 +106,10 @@ func (c *OrderServiceClient) CreateOrder(order *models.Order) (*models.OrderResp
 		}
 
 		req.Header.Set("Content-Type", "application/json")
+		if order.IdempotencyKey != "" {
+			req.Header.Set(IdempotencyKeyHeader, order.IdempotencyKey)
+		}
+		setLiveChange(req, order.Actor)
 
 		resp, err := c.httpClient.Do(req)
 		if err != nil {
@@ -67,6 +122,10 @@ func (c *OrderServiceClient) CreateOrder(order *models.Order) (*models.OrderResp
 			return fmt.Errorf("failed to decode order service response: %w", err)
 		}
 
+		if rejectedStatus(resp.StatusCode) {
+			rejected = fmt.Errorf("order service returned status %d: %s", resp.StatusCode, respData.Message)
+			return nil
+		}
 		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 			return fmt.Errorf("order service returned error status: %d", resp.StatusCode)
 		}
@@ -90,13 +149,161 @@ func (c *OrderServiceClient) CreateOrder(order *models.Order) (*models.OrderResp
 		return nil, err
 	}
 
+	if rejected != nil {
+		c.logger.WithFields(logrus.Fields{
+			"order_id": order.ID,
+			"error":    rejected.Error(),
+		}).Warn("Order service rejected order")
+		return nil, fmt.Errorf("order service rejected order: %w", rejected)
+	}
+
 	return orderResp, nil
 }
 
-func (c *OrderServiceClient) CreateOrderHistorical(order *models.Order) (*models.OrderResponse, error) {
-	c.logger.WithField("order_id", order.ID).Info("Sending historical order to order service")
+// rejectedStatus reports whether an HTTP status is an answer about the
+// request, such as an invalid order, rather than a failure of the Order
+// Service. It is the REST counterpart of rejectedByOrderService, so such
+// answers do not count as circuit breaker failures. Timeouts and rate
+// limits do.
+func rejectedStatus(code int) bool {
+	return code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests
+}
+
+// CreateOrderDryRun sends an order to the Order Service's live create path
+// as a dry run: the answer is the order as it would be stored, but nothing
+// is stored and no event is published. Dry runs always use REST.
+func (c *OrderServiceClient) CreateOrderDryRun(order *models.Order) (*models.OrderResponse, error) {
+	var orderResp *models.OrderResponse
+	var rejected error
+	err := c.circuitBreaker.Execute(func() error {
+		jsonData, err := json.Marshal(order)
+		if err != nil {
+			return fmt.Errorf("failed to marshal order: %w", err)
+		}
+
+		req, err := http.NewRequest("POST", c.baseURL+"/orders?dry_run=true", bytes.NewBuffer(jsonData))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		setLiveChange(req, order.Actor)
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send request to order service: %w", err)
+		}
+		defer resp.Body.Close()
+
+		var respData models.OrderResponse
+		if err := json.NewDecoder(resp.Body).Decode(&respData); err != nil {
+			return fmt.Errorf("failed to decode order service response: %w", err)
+		}
+
+		if rejectedStatus(resp.StatusCode) {
+			rejected = fmt.Errorf("order service returned status %d: %s", resp.StatusCode, respData.Message)
+			return nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("order service returned error status for dry run: %d", resp.StatusCode)
+		}
+
+		orderResp = &respData
+		return nil
+	})
+
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{
+			"order_id": order.ID,
+			"error": err.Error(),
+			"circuit_breaker_state": c.circuitBreaker.State().String(),
+		}).Error("Failed to dry run order in order service")
+		return nil, err
+	}
+
+	if rejected != nil {
+		return nil, fmt.Errorf("order service rejected order: %w", rejected)
+	}
+
+	return orderResp, nil
+}
+
+// CreateOrders sends a batch of orders to the Order Service, which stores
+// them in one transaction and reports the outcome of each.
+func (c *OrderServiceClient) CreateOrders(orders []*models.Order) (*models.BatchOrderResponse, error) {
+	c.logger.WithField("orders", len(orders)).Info("Sending order batch to order service")
+
+	var batchResp *models.BatchOrderResponse
+	err := c.circuitBreaker.Execute(func() error {
+		batch := models.BatchOrderRequest{Orders: make([]models.Order, len(orders))}
+		for i, order := range orders {
+			batch.Orders[i] = *order
+		}
+		jsonData, err := json.Marshal(batch)
+		if err != nil {
+			return fmt.Errorf("failed to marshal order batch: %w", err)
+		}
+
+		req, err := http.NewRequest("POST", c.baseURL+"/orders/batch", bytes.NewBuffer(jsonData))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		// The orders of a batch come from one request and share its actor
+		setLiveChange(req, orders[0].Actor)
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send request to order service: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("order service returned error status: %d", resp.StatusCode)
+		}
+
+		var respData models.BatchOrderResponse
+		if err := json.NewDecoder(resp.Body).Decode(&respData); err != nil {
+			return fmt.Errorf("failed to decode order service response: %w", err)
+		}
+		if len(respData.Results) != len(orders) {
+			return fmt.Errorf("order service returned %d results for %d orders", len(respData.Results), len(orders))
+		}
+
+		batchResp = &respData
+		c.logger.WithFields(logrus.Fields{
+			"orders":  len(orders),
+			"created": respData.Created,
+			"failed":  respData.Failed,
+		}).Info("Received batch response from order service")
+
+		return nil
+	})
+
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{
+			"orders": len(orders),
+			"error": err.Error(),
+			"circuit_breaker_state": c.circuitBreaker.State().String(),
+		}).Error("Failed to create order batch in order service")
+		return nil, err
+	}
+
+	return batchResp, nil
+}
+
+// CreateOrderHistorical stores a copy of an order without publishing an
+// event. source tells the audit trail where the copy comes from.
+func (c *OrderServiceClient) CreateOrderHistorical(order *models.Order, source string) (*models.OrderResponse, error) {
+	c.logger.WithFields(logrus.Fields{
+		"order_id": order.ID,
+		"source":   source,
+	}).Info("Sending historical order to order service")
+	if c.grpc != nil {
+		return c.createOrderHistoricalGRPC(order, source)
+	}
 
 	var orderResp *models.OrderResponse
+	var rejected error
 	err := c.circuitBreaker.Execute(func() error {
 		jsonData, err := json.Marshal(order)
 		if err != nil {
@@ -109,6 +316,11 @@ func (c *OrderServiceClient) CreateOrderHistorical(order *models.Order) (*models
 		}
 
 		req.Header.Set("Content-Type", "application/json")
+		if order.IdempotencyKey != "" {
+			req.Header.Set(IdempotencyKeyHeader, order.IdempotencyKey)
+		}
+		req.Header.Set(ChangeSourceHeader, source)
+		setActor(req, order.Actor)
 
 		resp, err := c.httpClient.Do(req)
 		if err != nil {
@@ -121,6 +333,10 @@ func (c *OrderServiceClient) CreateOrderHistorical(order *models.Order) (*models
 			return fmt.Errorf("failed to decode historical order service response: %w", err)
 		}
 
+		if rejectedStatus(resp.StatusCode) {
+			rejected = fmt.Errorf("order service returned status %d for historical order: %s", resp.StatusCode, respData.Message)
+			return nil
+		}
 		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 			return fmt.Errorf("order service returned error status for historical order: %d", resp.StatusCode)
 		}
@@ -144,15 +360,100 @@ func (c *OrderServiceClient) CreateOrderHistorical(order *models.Order) (*models
 		return nil, err
 	}
 
+	if rejected != nil {
+		c.logger.WithFields(logrus.Fields{
+			"order_id": order.ID,
+			"error":    rejected.Error(),
+		}).Warn("Order service rejected historical order")
+		return nil, fmt.Errorf("order service rejected historical order: %w", rejected)
+	}
+
 	return orderResp, nil
 }
 
-func (c *OrderServiceClient) GetOrders() ([]models.Order, error) {
+// GetOrders returns one page of the orders matching the query.
+func (c *OrderServiceClient) GetOrders(query models.OrderQuery) (*models.OrderPage, error) {
 	c.logger.Info("Fetching orders from order service")
+	return c.getOrderPage("/orders", query)
+}
+
+// SearchOrders returns one page of the orders with an item matching the
+// query's product and specifications.
+func (c *OrderServiceClient) SearchOrders(query models.OrderQuery) (*models.OrderPage, error) {
+	c.logger.WithFields(logrus.Fields{
+		"product_id":     query.ProductID,
+		"specifications": query.Specifications,
+	}).Info("Searching orders in order service")
+	return c.getOrderPage("/orders/search", query)
+}
 
-	var orders []models.Order
+// GetCustomerOrderSummary returns the totals of a customer's orders created
+// in the window.
+func (c *OrderServiceClient) GetCustomerOrderSummary(customerID string, window models.AnalyticsWindow) (*models.CustomerOrderSummary, error) {
+	c.logger.WithField("customer_id", customerID).Info("Fetching customer order summary from order service")
+
+	var response struct {
+		Summary *models.CustomerOrderSummary `json:"summary"`
+	}
+	path := "/customers/" + url.PathEscape(customerID) + "/orders/summary?" + window.Values().Encode()
+	if err := c.getAnalytics(path, &response); err != nil {
+		return nil, err
+	}
+	return response.Summary, nil
+}
+
+// GetOrderAnalytics returns the totals of all orders created in the window
+// with the top customers by revenue.
+func (c *OrderServiceClient) GetOrderAnalytics(window models.AnalyticsWindow, topCustomers int) (*models.OrderAnalytics, error) {
+	c.logger.Info("Fetching order analytics from order service")
+
+	var response struct {
+		Analytics *models.OrderAnalytics `json:"analytics"`
+	}
+	values := window.Values()
+	values.Set("top", strconv.Itoa(topCustomers))
+	if err := c.getAnalytics("/analytics/orders?"+values.Encode(), &response); err != nil {
+		return nil, err
+	}
+	return response.Analytics, nil
+}
+
+func (c *OrderServiceClient) getAnalytics(path string, response interface{}) error {
 	err := c.circuitBreaker.Execute(func() error {
-		req, err := http.NewRequest("GET", c.baseURL+"/orders", nil)
+		resp, err := c.httpClient.Get(c.baseURL + path)
+		if err != nil {
+			return fmt.Errorf("failed to send request to order service: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("order service returned error status: %d", resp.StatusCode)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+			return fmt.Errorf("failed to decode order service response: %w", err)
+		}
+		return nil
+	})
+
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{
+			"path": path,
+			"error": err.Error(),
+			"circuit_breaker_state": c.circuitBreaker.State().String(),
+		}).Error("Failed to get analytics from order service")
+	}
+	return err
+}
+
+func (c *OrderServiceClient) getOrderPage(path string, query models.OrderQuery) (*models.OrderPage, error) {
+	if c.grpc != nil {
+		// ListOrders takes the search filters as well
+		return c.listOrdersGRPC(query)
+	}
+
+	var page *models.OrderPage
+	err := c.circuitBreaker.Execute(func() error {
+		req, err := http.NewRequest("GET", c.baseURL+path+"?"+query.Values().Encode(), nil)
 		if err != nil {
 			return fmt.Errorf("failed to create request: %w", err)
 		}
@@ -164,9 +465,10 @@ func (c *OrderServiceClient) GetOrders() ([]models.Order, error) {
 		defer resp.Body.Close()
 
 		var response struct {
-			Success bool            `json:"success"`
-			Orders  []models.Order  `json:"orders"`
-			Count   int             `json:"count"`
+			Success    bool           `json:"success"`
+			Orders     []models.Order `json:"orders"`
+			Count      int            `json:"count"`
+			NextCursor string         `json:"next_cursor"`
 		}
 
 		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
@@ -177,7 +479,7 @@ func (c *OrderServiceClient) GetOrders() ([]models.Order, error) {
 			return fmt.Errorf("order service returned error status: %d", resp.StatusCode)
 		}
 
-		orders = response.Orders
+		page = &models.OrderPage{Orders: response.Orders, NextCursor: response.NextCursor}
 		c.logger.WithField("count", response.Count).Info("Retrieved orders from order service")
 		return nil
 	})
@@ -190,13 +492,17 @@ func (c *OrderServiceClient) GetOrders() ([]models.Order, error) {
 		return nil, err
 	}
 
-	return orders, nil
+	return page, nil
 }
 
 func (c *OrderServiceClient) GetOrder(orderID string) (*models.Order, error) {
 	c.logger.WithField("order_id", orderID).Info("Fetching order from order service")
+	if c.grpc != nil {
+		return c.getOrderGRPC(orderID)
+	}
 
 	var order *models.Order
+	notFound := false
 	err := c.circuitBreaker.Execute(func() error {
 		req, err := http.NewRequest("GET", c.baseURL+"/orders/"+orderID, nil)
 		if err != nil {
@@ -210,7 +516,8 @@ func (c *OrderServiceClient) GetOrder(orderID string) (*models.Order, error) {
 		defer resp.Body.Close()
 
 		if resp.StatusCode == http.StatusNotFound {
-			return fmt.Errorf("order not found in order service")
+			notFound = true
+			return nil
 		}
 
 		if resp.StatusCode != http.StatusOK {
@@ -236,9 +543,228 @@ func (c *OrderServiceClient) GetOrder(orderID string) (*models.Order, error) {
 		return nil, err
 	}
 
+	if notFound {
+		return nil, ErrOrderNotFound
+	}
+
 	return order, nil
 }
 
+// GetOrderHistory returns the audit trail of an order, oldest change first.
+func (c *OrderServiceClient) GetOrderHistory(orderID string) (*models.OrderHistoryResponse, error) {
+	c.logger.WithField("order_id", orderID).Info("Fetching order history from order service")
+
+	var history *models.OrderHistoryResponse
+	notFound := false
+	err := c.circuitBreaker.Execute(func() error {
+		req, err := http.NewRequest("GET", c.baseURL+"/orders/"+url.PathEscape(orderID)+"/history", nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send request to order service: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotFound {
+			notFound = true
+			return nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("order service returned error status: %d", resp.StatusCode)
+		}
+
+		var historyData models.OrderHistoryResponse
+		if err := json.NewDecoder(resp.Body).Decode(&historyData); err != nil {
+			return fmt.Errorf("failed to decode order service response: %w", err)
+		}
+		history = &historyData
+		return nil
+	})
+
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{
+			"order_id": orderID,
+			"error": err.Error(),
+			"circuit_breaker_state": c.circuitBreaker.State().String(),
+		}).Error("Failed to get order history from order service")
+		return nil, err
+	}
+
+	if notFound {
+		return nil, ErrOrderNotFound
+	}
+	return history, nil
+}
+
+// CancelOrder asks the Order Service to cancel an order on behalf of actor.
+// The cancellation is confirmed by SAP later, so the returned order is still
+// waiting for it.
+func (c *OrderServiceClient) CancelOrder(orderID string, request models.CancellationRequest, actor string) (*models.OrderResponse, error) {
+	c.logger.WithFields(logrus.Fields{
+		"order_id":    orderID,
+		"reason_code": request.ReasonCode,
+	}).Info("Requesting order cancellation from order service")
+
+	var orderResp *models.OrderResponse
+	notFound := false
+	conflict := ""
+	err := c.circuitBreaker.Execute(func() error {
+		jsonData, err := json.Marshal(request)
+		if err != nil {
+			return fmt.Errorf("failed to marshal cancellation request: %w", err)
+		}
+
+		req, err := http.NewRequest("POST", c.baseURL+"/orders/"+orderID+"/cancel", bytes.NewBuffer(jsonData))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		setLiveChange(req, actor)
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send request to order service: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotFound {
+			notFound = true
+			return nil
+		}
+
+		var respData models.OrderResponse
+		if err := json.NewDecoder(resp.Body).Decode(&respData); err != nil {
+			return fmt.Errorf("failed to decode order service response: %w", err)
+		}
+
+		if resp.StatusCode == http.StatusConflict {
+			conflict = respData.Message
+			return nil
+		}
+
+		if resp.StatusCode != http.StatusAccepted {
+			return fmt.Errorf("order service returned error status: %d", resp.StatusCode)
+		}
+
+		orderResp = &respData
+		return nil
+	})
+
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{
+			"order_id": orderID,
+			"error": err.Error(),
+			"circuit_breaker_state": c.circuitBreaker.State().String(),
+		}).Error("Failed to cancel order in order service")
+		return nil, err
+	}
+
+	if notFound {
+		return nil, ErrOrderNotFound
+	}
+	if conflict != "" {
+		return nil, fmt.Errorf("%w: %s", ErrOrderConflict, conflict)
+	}
+
+	return orderResp, nil
+}
+
+// UpdateOrder changes an order's delivery date or items on behalf of actor
+// if its version still matches ifMatch, and returns the updated order with
+// its new ETag. On a
+// version mismatch the ETag of the current version is returned with
+// ErrOrderVersionMismatch. Validation failures are returned as
+// validation.Errors.
+func (c *OrderServiceClient) UpdateOrder(orderID string, update models.OrderUpdate, ifMatch, actor string) (*models.OrderResponse, string, error) {
+	c.logger.WithFields(logrus.Fields{
+		"order_id": orderID,
+		"if_match": ifMatch,
+	}).Info("Updating order in order service")
+
+	var orderResp *models.OrderResponse
+	var etag string
+	notFound := false
+	versionMismatch := false
+	conflict := ""
+	var invalid validation.Errors
+	err := c.circuitBreaker.Execute(func() error {
+		jsonData, err := json.Marshal(update)
+		if err != nil {
+			return fmt.Errorf("failed to marshal order update: %w", err)
+		}
+
+		req, err := http.NewRequest("PUT", c.baseURL+"/orders/"+orderID, bytes.NewBuffer(jsonData))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", ifMatch)
+		setLiveChange(req, actor)
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send request to order service: %w", err)
+		}
+		defer resp.Body.Close()
+		etag = resp.Header.Get("ETag")
+
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			notFound = true
+			return nil
+		case http.StatusPreconditionFailed:
+			versionMismatch = true
+			return nil
+		}
+
+		var respData struct {
+			models.OrderResponse
+			Errors validation.Errors `json:"errors"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&respData); err != nil {
+			return fmt.Errorf("failed to decode order service response: %w", err)
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			orderResp = &respData.OrderResponse
+			return nil
+		case http.StatusConflict:
+			conflict = respData.Message
+			return nil
+		case http.StatusUnprocessableEntity:
+			invalid = respData.Errors
+			return nil
+		}
+		return fmt.Errorf("order service returned error status: %d", resp.StatusCode)
+	})
+
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{
+			"order_id": orderID,
+			"error": err.Error(),
+			"circuit_breaker_state": c.circuitBreaker.State().String(),
+		}).Error("Failed to update order in order service")
+		return nil, "", err
+	}
+
+	switch {
+	case notFound:
+		return nil, "", ErrOrderNotFound
+	case versionMismatch:
+		return nil, etag, ErrOrderVersionMismatch
+	case conflict != "":
+		return nil, "", fmt.Errorf("%w: %s", ErrOrderConflict, conflict)
+	case len(invalid) > 0:
+		return nil, "", invalid
+	}
+
+	return orderResp, etag, nil
+}
+
 func getHTTPTimeout(envVar, defaultValue string, logger *logrus.Logger) time.Duration {
 	value := os.Getenv(envVar)
 	if value == "" {
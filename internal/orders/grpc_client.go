@@ -0,0 +1,262 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	ordersv1 "github.com/jogardn/strangler-demo/api/orders/v1"
+	"github.com/jogardn/strangler-demo/api/orders/v1/ordersconv"
+	"github.com/jogardn/strangler-demo/internal/circuitbreaker"
+	"github.com/jogardn/strangler-demo/pkg/models"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// NewGRPCOrderServiceClient returns an Order Service client that creates,
+// gets and lists orders over the gRPC API at grpcAddr, e.g.
+// order-service:9090. The calls the gRPC API does not offer go to the REST
+// API at baseURL. Both share the order-service circuit breaker and the
+// ORDER_SERVICE_HTTP_TIMEOUT_SECONDS timeout.
+func NewGRPCOrderServiceClient(baseURL, grpcAddr string, logger *logrus.Logger, cbManager *circuitbreaker.Manager) (*OrderServiceClient, error) {
+	conn, err := grpc.NewClient(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create order service gRPC client: %w", err)
+	}
+
+	client := NewOrderServiceClient(baseURL, logger, cbManager)
+	client.grpcConn = conn
+	client.grpc = ordersv1.NewOrderServiceClient(conn)
+	return client, nil
+}
+
+// Close closes the gRPC connection, if any.
+func (c *OrderServiceClient) Close() error {
+	if c.grpcConn == nil {
+		return nil
+	}
+	return c.grpcConn.Close()
+}
+
+// grpcContext bounds a gRPC call like the HTTP client timeout bounds a
+// REST call, so a slow Order Service counts as a circuit breaker failure.
+func (c *OrderServiceClient) grpcContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), c.httpClient.Timeout)
+}
+
+// rejectedByOrderService reports whether a gRPC error is an answer about
+// the request, such as an invalid order, rather than a failure of the Order
+// Service. Like a 404, it does not count as a circuit breaker failure.
+func rejectedByOrderService(err error) bool {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.FailedPrecondition, codes.OutOfRange:
+		return true
+	}
+	return false
+}
+
+func (c *OrderServiceClient) createOrderGRPC(order *models.Order) (*models.OrderResponse, error) {
+	var orderResp *models.OrderResponse
+	var rejected error
+	err := c.circuitBreaker.Execute(func() error {
+		ctx, cancel := c.grpcContext()
+		defer cancel()
+
+		resp, err := c.grpc.CreateOrder(ctx, &ordersv1.CreateOrderRequest{
+			Order:          ordersconv.OrderToProto(order),
+			IdempotencyKey: order.IdempotencyKey,
+			Actor:          order.Actor,
+			Source:         models.AuditSourceProxy,
+		})
+		if rejectedByOrderService(err) {
+			rejected = err
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("order service gRPC call failed: %w", err)
+		}
+
+		orderResp = createOrderResponse(resp, "Order created successfully")
+		c.logger.WithFields(logrus.Fields{
+			"order_id": order.ID,
+			"replayed": resp.GetReplayed(),
+		}).Info("Received gRPC response from order service")
+		return nil
+	})
+
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{
+			"order_id":              order.ID,
+			"error":                 err.Error(),
+			"circuit_breaker_state": c.circuitBreaker.State().String(),
+		}).Error("Failed to create order in order service")
+		return nil, err
+	}
+
+	if rejected != nil {
+		c.logger.WithFields(logrus.Fields{
+			"order_id": order.ID,
+			"error":    rejected.Error(),
+		}).Warn("Order service rejected order")
+		return nil, fmt.Errorf("order service rejected order: %w", rejected)
+	}
+
+	return orderResp, nil
+}
+
+func (c *OrderServiceClient) createOrderHistoricalGRPC(order *models.Order, source string) (*models.OrderResponse, error) {
+	var orderResp *models.OrderResponse
+	var rejected error
+	err := c.circuitBreaker.Execute(func() error {
+		ctx, cancel := c.grpcContext()
+		defer cancel()
+
+		resp, err := c.grpc.CreateHistoricalOrder(ctx, &ordersv1.CreateHistoricalOrderRequest{
+			Order:          ordersconv.OrderToProto(order),
+			IdempotencyKey: order.IdempotencyKey,
+			Actor:          order.Actor,
+			Source:         source,
+		})
+		if rejectedByOrderService(err) {
+			rejected = err
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("order service gRPC call for historical order failed: %w", err)
+		}
+
+		orderResp = createOrderResponse(resp, "Historical order created successfully")
+		c.logger.WithFields(logrus.Fields{
+			"order_id": order.ID,
+			"replayed": resp.GetReplayed(),
+		}).Info("Historical order created in order service")
+		return nil
+	})
+
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{
+			"order_id":              order.ID,
+			"error":                 err.Error(),
+			"circuit_breaker_state": c.circuitBreaker.State().String(),
+		}).Error("Failed to create historical order in order service")
+		return nil, err
+	}
+
+	if rejected != nil {
+		c.logger.WithFields(logrus.Fields{
+			"order_id": order.ID,
+			"error":    rejected.Error(),
+		}).Warn("Order service rejected historical order")
+		return nil, fmt.Errorf("order service rejected historical order: %w", rejected)
+	}
+
+	return orderResp, nil
+}
+
+func (c *OrderServiceClient) getOrderGRPC(orderID string) (*models.Order, error) {
+	var order *models.Order
+	notFound := false
+	err := c.circuitBreaker.Execute(func() error {
+		ctx, cancel := c.grpcContext()
+		defer cancel()
+
+		resp, err := c.grpc.GetOrder(ctx, &ordersv1.GetOrderRequest{Id: orderID})
+		if status.Code(err) == codes.NotFound {
+			notFound = true
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("order service gRPC call failed: %w", err)
+		}
+
+		order = ordersconv.OrderFromProto(resp)
+		c.logger.WithField("order_id", orderID).Info("Retrieved order from order service")
+		return nil
+	})
+
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{
+			"order_id":              orderID,
+			"error":                 err.Error(),
+			"circuit_breaker_state": c.circuitBreaker.State().String(),
+		}).Error("Failed to get order from order service")
+		return nil, err
+	}
+
+	if notFound {
+		return nil, ErrOrderNotFound
+	}
+
+	return order, nil
+}
+
+// listOrdersGRPC reads one page from the ListOrders stream. A stream that
+// breaks off fails the whole page, like a truncated REST response.
+func (c *OrderServiceClient) listOrdersGRPC(query models.OrderQuery) (*models.OrderPage, error) {
+	var page *models.OrderPage
+	var rejected error
+	err := c.circuitBreaker.Execute(func() error {
+		ctx, cancel := c.grpcContext()
+		defer cancel()
+
+		stream, err := c.grpc.ListOrders(ctx, ordersconv.QueryToProto(query))
+		if err != nil {
+			return fmt.Errorf("order service gRPC call failed: %w", err)
+		}
+
+		result := &models.OrderPage{Orders: []models.Order{}}
+		for {
+			resp, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if rejectedByOrderService(err) {
+				rejected = err
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("order service gRPC stream failed: %w", err)
+			}
+			if order := resp.GetOrder(); order != nil {
+				result.Orders = append(result.Orders, *ordersconv.OrderFromProto(order))
+			} else {
+				result.NextCursor = resp.GetNextCursor()
+			}
+		}
+
+		page = result
+		c.logger.WithField("count", len(result.Orders)).Info("Retrieved orders from order service")
+		return nil
+	})
+
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{
+			"error":                 err.Error(),
+			"circuit_breaker_state": c.circuitBreaker.State().String(),
+		}).Error("Failed to get orders from order service")
+		return nil, err
+	}
+
+	if rejected != nil {
+		return nil, fmt.Errorf("order service rejected query: %w", rejected)
+	}
+
+	return page, nil
+}
+
+// createOrderResponse is the REST answer to a create that the gRPC answer
+// stands for.
+func createOrderResponse(resp *ordersv1.CreateOrderResponse, message string) *models.OrderResponse {
+	if resp.GetReplayed() {
+		message = "Order already exists"
+	}
+	return &models.OrderResponse{
+		Success: true,
+		Message: message,
+		Order:   ordersconv.OrderFromProto(resp.GetOrder()),
+	}
+}
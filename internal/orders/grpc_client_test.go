@@ -0,0 +1,273 @@
+package orders
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	ordersv1 "github.com/jogardn/strangler-demo/api/orders/v1"
+	"github.com/jogardn/strangler-demo/internal/circuitbreaker"
+	"github.com/jogardn/strangler-demo/pkg/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeGRPCOrderService answers like the Order Service's gRPC API and
+// records the requests it receives.
+type fakeGRPCOrderService struct {
+	ordersv1.UnimplementedOrderServiceServer
+	createErr error
+	getErr    error
+
+	mu         sync.Mutex
+	created    []*ordersv1.CreateOrderRequest
+	historical []*ordersv1.CreateHistoricalOrderRequest
+	queries    []*ordersv1.ListOrdersRequest
+	gets       int
+}
+
+func (f *fakeGRPCOrderService) CreateOrder(ctx context.Context, req *ordersv1.CreateOrderRequest) (*ordersv1.CreateOrderResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.created = append(f.created, req)
+	if f.createErr != nil {
+		return nil, f.createErr
+	}
+	return &ordersv1.CreateOrderResponse{Order: req.GetOrder(), Replayed: len(f.created) > 1}, nil
+}
+
+func (f *fakeGRPCOrderService) CreateHistoricalOrder(ctx context.Context, req *ordersv1.CreateHistoricalOrderRequest) (*ordersv1.CreateOrderResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.historical = append(f.historical, req)
+	if f.createErr != nil {
+		return nil, f.createErr
+	}
+	return &ordersv1.CreateOrderResponse{Order: req.GetOrder()}, nil
+}
+
+func (f *fakeGRPCOrderService) GetOrder(ctx context.Context, req *ordersv1.GetOrderRequest) (*ordersv1.Order, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.gets++
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	if req.GetId() != "order-1" {
+		return nil, status.Error(codes.NotFound, "order not found")
+	}
+	return &ordersv1.Order{Id: "order-1", Version: 2}, nil
+}
+
+func (f *fakeGRPCOrderService) ListOrders(req *ordersv1.ListOrdersRequest, stream ordersv1.OrderService_ListOrdersServer) error {
+	f.mu.Lock()
+	f.queries = append(f.queries, req)
+	f.mu.Unlock()
+	for _, id := range []string{"order-1", "order-2"} {
+		if err := stream.Send(&ordersv1.ListOrdersResponse{
+			Result: &ordersv1.ListOrdersResponse_Order{Order: &ordersv1.Order{Id: id}},
+		}); err != nil {
+			return err
+		}
+	}
+	return stream.Send(&ordersv1.ListOrdersResponse{
+		Result: &ordersv1.ListOrdersResponse_NextCursor{NextCursor: "next"},
+	})
+}
+
+func newGRPCTestClient(t *testing.T, fake *fakeGRPCOrderService) (*OrderServiceClient, *circuitbreaker.Manager) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := grpc.NewServer()
+	ordersv1.RegisterOrderServiceServer(server, fake)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	logger := newTestLogger()
+	cbManager := circuitbreaker.NewManager(logger)
+	cbManager.GetOrCreate("order-service", circuitbreaker.Config{MaxFailures: 1, Timeout: time.Minute, MaxRequests: 1})
+	client, err := NewGRPCOrderServiceClient("http://unused", listener.Addr().String(), logger, cbManager)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, cbManager
+}
+
+func TestGRPCOrderServiceClientCreatesOrders(t *testing.T) {
+	fake := &fakeGRPCOrderService{}
+	client, _ := newGRPCTestClient(t, fake)
+
+	order := &models.Order{
+		ID:             "order-1",
+		CustomerID:     "customer-1",
+		Items:          []models.OrderItem{{ProductID: "WIDGET-001", Quantity: 2, UnitPrice: 2599}},
+		TotalAmount:    5198,
+		Currency:       "EUR",
+		DeliveryDate:   time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+		IdempotencyKey: "key-1",
+		Actor:          "alice",
+	}
+	resp, err := client.CreateOrder(order)
+	if err != nil || !resp.Success || resp.Order.TotalAmount != 5198 || resp.Order.Currency != "EUR" || !resp.Order.DeliveryDate.Equal(order.DeliveryDate) {
+		t.Fatalf("Expected the created order, got %+v (%v)", resp, err)
+	}
+	if req := fake.created[0]; req.GetIdempotencyKey() != "key-1" || req.GetActor() != "alice" || req.GetOrder().GetItems()[0].GetUnitPriceCents() != 2599 {
+		t.Errorf("Expected the order, idempotency key and actor to be sent, got %+v", req)
+	}
+
+	if resp, err := client.CreateOrder(order); err != nil || resp.Message != "Order already exists" {
+		t.Errorf("Expected a replayed order, got %+v (%v)", resp, err)
+	}
+
+	if _, err := client.CreateOrderHistorical(order, models.AuditSourceMigration); err != nil {
+		t.Fatalf("Expected the historical order to be created, got %v", err)
+	}
+	if req := fake.historical[0]; req.GetSource() != models.AuditSourceMigration || req.GetOrder().GetId() != "order-1" {
+		t.Errorf("Expected the source to be sent, got %+v", req)
+	}
+}
+
+func TestGRPCOrderServiceClientGetsAndListsOrders(t *testing.T) {
+	fake := &fakeGRPCOrderService{}
+	client, cbManager := newGRPCTestClient(t, fake)
+
+	page, err := client.SearchOrders(models.OrderQuery{ProductID: "WIDGET-001", Limit: 2, Cursor: "c1"})
+	if err != nil || len(page.Orders) != 2 || page.NextCursor != "next" {
+		t.Fatalf("Expected a page of 2 with a cursor, got %+v (%v)", page, err)
+	}
+	if q := fake.queries[0]; q.GetProductId() != "WIDGET-001" || q.GetLimit() != 2 || q.GetCursor() != "c1" {
+		t.Errorf("Expected the query to be forwarded, got %+v", q)
+	}
+
+	order, err := client.GetOrder("order-1")
+	if err != nil || order.ID != "order-1" || order.Version != 2 {
+		t.Errorf("Expected the order, got %+v (%v)", order, err)
+	}
+
+	// A missing order is a normal answer
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetOrder("missing"); !errors.Is(err, ErrOrderNotFound) {
+			t.Errorf("Expected ErrOrderNotFound, got %v", err)
+		}
+	}
+	if state := cbManager.Get("order-service").State(); state != circuitbreaker.StateClosed {
+		t.Errorf("Expected breaker to stay closed, got %s", state)
+	}
+}
+
+func TestGRPCOrderServiceClientTripsBreaker(t *testing.T) {
+	fake := &fakeGRPCOrderService{getErr: status.Error(codes.Unavailable, "database unavailable")}
+	client, cbManager := newGRPCTestClient(t, fake)
+
+	if _, err := client.GetOrder("order-1"); err == nil {
+		t.Fatal("Expected the failure to be returned")
+	}
+	if state := cbManager.Get("order-service").State(); state != circuitbreaker.StateOpen {
+		t.Fatalf("Expected breaker to open, got %s", state)
+	}
+
+	if _, err := client.GetOrder("order-1"); !errors.Is(err, circuitbreaker.ErrCircuitBreakerOpen) {
+		t.Errorf("Expected the open breaker to reject the call, got %v", err)
+	}
+	if fake.gets != 1 {
+		t.Errorf("Expected the rejected call not to reach the Order Service, got %d calls", fake.gets)
+	}
+}
+
+func TestGRPCOrderServiceClientRejectedOrdersKeepBreakerClosed(t *testing.T) {
+	fake := &fakeGRPCOrderService{createErr: status.Error(codes.InvalidArgument, "order validation failed")}
+	client, cbManager := newGRPCTestClient(t, fake)
+
+	order := &models.Order{ID: "order-1"}
+	for i := 0; i < 2; i++ {
+		if _, err := client.CreateOrder(order); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Expected the rejection to be returned, got %v", err)
+		}
+		if _, err := client.CreateOrderHistorical(order, models.AuditSourceMigration); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Expected the rejection of the historical order to be returned, got %v", err)
+		}
+	}
+	if state := cbManager.Get("order-service").State(); state != circuitbreaker.StateClosed {
+		t.Errorf("Expected invalid orders to leave the breaker closed, got %s", state)
+	}
+	if len(fake.created) != 2 || len(fake.historical) != 2 {
+		t.Errorf("Expected every call to reach the Order Service, got %d and %d", len(fake.created), len(fake.historical))
+	}
+}
+
+// Both transports leave the breaker closed for answers about the order, and
+// open it once the Order Service says it is overloaded.
+func TestRejectedOrdersKeepBreakerClosedOnBothTransports(t *testing.T) {
+	transports := map[string]func(t *testing.T) (client *OrderServiceClient, cbManager *circuitbreaker.Manager, overload func()){
+		"rest": func(t *testing.T) (*OrderServiceClient, *circuitbreaker.Manager, func()) {
+			var mu sync.Mutex
+			code := http.StatusUnprocessableEntity
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				status := code
+				mu.Unlock()
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(status)
+				json.NewEncoder(w).Encode(models.OrderResponse{Message: http.StatusText(status)})
+			}))
+			t.Cleanup(server.Close)
+
+			logger := newTestLogger()
+			cbManager := circuitbreaker.NewManager(logger)
+			cbManager.GetOrCreate("order-service", circuitbreaker.Config{MaxFailures: 1, Timeout: time.Minute, MaxRequests: 1})
+			return NewOrderServiceClient(server.URL, logger, cbManager), cbManager, func() {
+				mu.Lock()
+				code = http.StatusTooManyRequests
+				mu.Unlock()
+			}
+		},
+		"grpc": func(t *testing.T) (*OrderServiceClient, *circuitbreaker.Manager, func()) {
+			fake := &fakeGRPCOrderService{createErr: status.Error(codes.InvalidArgument, "order validation failed")}
+			client, cbManager := newGRPCTestClient(t, fake)
+			return client, cbManager, func() {
+				fake.mu.Lock()
+				fake.createErr = status.Error(codes.ResourceExhausted, "too many requests")
+				fake.mu.Unlock()
+			}
+		},
+	}
+
+	for name, setup := range transports {
+		t.Run(name, func(t *testing.T) {
+			client, cbManager, overload := setup(t)
+			order := &models.Order{ID: "order-1"}
+
+			for i := 0; i < 2; i++ {
+				if _, err := client.CreateOrder(order); err == nil {
+					t.Error("Expected the rejection to be returned")
+				}
+				if _, err := client.CreateOrderHistorical(order, models.AuditSourceMigration); err == nil {
+					t.Error("Expected the rejection of the historical order to be returned")
+				}
+			}
+			if state := cbManager.Get("order-service").State(); state != circuitbreaker.StateClosed {
+				t.Fatalf("Expected rejected orders to leave the breaker closed, got %s", state)
+			}
+
+			overload()
+			if _, err := client.CreateOrder(order); err == nil {
+				t.Error("Expected the failure to be returned")
+			}
+			if state := cbManager.Get("order-service").State(); state != circuitbreaker.StateOpen {
+				t.Errorf("Expected an overloaded Order Service to open the breaker, got %s", state)
+			}
+		})
+	}
+}
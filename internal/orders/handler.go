@@ -2,14 +2,19 @@ package orders
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/jogardn/strangler-demo/internal/circuitbreaker"
 	"github.com/jogardn/strangler-demo/internal/sap"
 	"github.com/jogardn/strangler-demo/pkg/models"
+	"github.com/jogardn/strangler-demo/pkg/validation"
 	"github.com/sirupsen/logrus"
 )
 
@@ -22,6 +27,8 @@ type Handler struct {
 	orderServiceClient *OrderServiceClient
 	logger             *logrus.Logger
 	wsHub              WebSocketHub
+	phases             *PhaseManager
+	idempotency        *IdempotencyStore
 }
 
 func NewHandler(sapClient *sap.Client, orderServiceClient *OrderServiceClient, logger *logrus.Logger) *Handler {
@@ -36,53 +43,121 @@ func (h *Handler) SetWebSocketHub(hub WebSocketHub) {
 	h.wsHub = hub
 }
 
+func (h *Handler) SetPhaseManager(phases *PhaseManager) {
+	h.phases = phases
+}
+
+func (h *Handler) SetIdempotencyStore(store *IdempotencyStore) {
+	h.idempotency = store
+}
+
 func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
-	var order models.Order
-	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
-		h.logger.WithError(err).Error("Failed to decode order request")
+	h.serveIdempotent(w, r, h.createOrder)
+}
+
+// CreateOrderBatch creates up to models.MaxBatchSize orders and reports the
+// outcome of each; invalid or failed orders do not fail the rest. With an
+// Idempotency-Key, the batch response is replayed like a single order's,
+// and order IDs are derived from the key and the order's position.
+func (h *Handler) CreateOrderBatch(w http.ResponseWriter, r *http.Request) {
+	h.serveIdempotent(w, r, h.createOrderBatch)
+}
+
+// serveIdempotent answers a request with handle, replaying the stored
+// response of an earlier request with the same Idempotency-Key.
+func (h *Handler) serveIdempotent(w http.ResponseWriter, r *http.Request, handle func(body []byte, idempotencyKey, actor string) (int, interface{})) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to read order request")
 		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	if order.ID == "" {
-		order.ID = uuid.New().String()
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if key != "" && h.idempotency != nil {
+		stored, err := h.idempotency.Begin(key, RequestFingerprint(body))
+		switch {
+		case errors.Is(err, ErrIdempotencyInFlight):
+			h.respondWithError(w, http.StatusConflict, err.Error())
+			return
+		case errors.Is(err, ErrIdempotencyKeyReused):
+			h.respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		case stored != nil:
+			h.logger.WithField("idempotency_key", key).Info("Replaying stored response for idempotency key")
+			w.Header().Set(IdempotentReplayHeader, "true")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(stored.StatusCode)
+			w.Write(stored.Body)
+			return
+		}
 	}
-	if order.CreatedAt.IsZero() {
-		order.CreatedAt = time.Now()
+
+	code, payload := handle(body, key, r.Header.Get(ActorHeader))
+	response, _ := json.Marshal(payload)
+
+	if key != "" && h.idempotency != nil {
+		// Server errors are not stored so the client can retry with the same
+		// key. Neither is a batch in which some order hit one
+		retryable, _ := payload.(interface{ Retryable() bool })
+		if code >= http.StatusInternalServerError || (retryable != nil && retryable.Retryable()) {
+			h.idempotency.Abort(key)
+		} else {
+			h.idempotency.Complete(key, code, response)
+		}
 	}
-	if order.Status == "" {
-		order.Status = "pending"
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
+
+func (h *Handler) createOrder(body []byte, idempotencyKey, actor string) (int, interface{}) {
+	var order models.Order
+	if err := json.Unmarshal(body, &order); err != nil {
+		h.logger.WithError(err).Error("Failed to decode order request")
+		return http.StatusBadRequest, errorPayload("Invalid request body")
 	}
 
-	h.logger.WithFields(logrus.Fields{
-		"order_id":     order.ID,
-		"customer_id":  order.CustomerID,
-		"total_amount": order.TotalAmount,
-		"items_count":  len(order.Items),
-	}).Info("Processing order request - Phase 3: Order Service only")
-
-	// Phase 3: Only write to the new order service
-	// SAP will receive orders via Kafka events
-	if h.orderServiceClient == nil {
-		h.logger.Error("Order service client not configured")
-		h.respondWithError(w, http.StatusInternalServerError, "Order service not available")
-		return
+	prepareOrder(&order, idempotencyKey)
+	order.Actor = actor
+	if errs := validation.ValidateOrder(&order); len(errs) > 0 {
+		h.logger.WithFields(logrus.Fields{
+			"order_id": order.ID,
+			"errors":   errs.Error(),
+		}).Warn("Order failed validation")
+		return http.StatusUnprocessableEntity, validationErrorPayload(errs)
 	}
 
-	orderServiceResp, err := h.orderServiceClient.CreateOrder(&order)
+	strategy := h.phases.Current()
+
+	h.logger.WithFields(logrus.Fields{
+		"order_id":        order.ID,
+		"customer_id":     order.CustomerID,
+		"total_amount":    order.TotalAmount,
+		"items_count":     len(order.Items),
+		"phase":           strategy.Phase(),
+		"idempotency_key": idempotencyKey,
+	}).Info("Processing order request")
+
+	orderResp, err := strategy.CreateOrder(&order)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to create order in order service")
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to process order")
-		return
+		h.logger.WithError(err).WithField("phase", strategy.Phase()).Error("Failed to create order")
+		return http.StatusInternalServerError, errorPayload("Failed to process order")
 	}
 
-	if !orderServiceResp.Success {
-		h.logger.WithField("message", orderServiceResp.Message).Error("Order service returned error")
-		h.respondWithError(w, http.StatusBadRequest, orderServiceResp.Message)
-		return
+	if !orderResp.Success {
+		h.logger.WithFields(logrus.Fields{
+			"message": orderResp.Message,
+			"phase":   strategy.Phase(),
+		}).Error("Backend returned error")
+		return http.StatusBadRequest, errorPayload(orderResp.Message)
 	}
 
-	h.logger.WithField("order_id", order.ID).Info("Order successfully processed by order service - event published to Kafka")
+	h.logger.WithFields(logrus.Fields{
+		"order_id": order.ID,
+		"phase":    strategy.Phase(),
+	}).Info("Order successfully processed")
 
 	// Broadcast order creation via WebSocket
 	if h.wsHub != nil {
@@ -90,18 +165,118 @@ func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 			"type":            "order_created",
 			"order":           order,
 			"source":          "proxy",
+			"phase":           strategy.Phase(),
 			"processing_time": time.Since(time.Now()).Milliseconds(), // This would be calculated properly in real implementation
 		}
 		h.wsHub.Broadcast("order_created", orderEvent, "proxy")
 	}
 
-	// Return order service response
-	h.respondWithJSON(w, http.StatusCreated, orderServiceResp)
+	if orderResp.SyncState == models.SyncStatePending {
+		return http.StatusAccepted, orderResp
+	}
+	return http.StatusCreated, orderResp
+}
+
+func (h *Handler) createOrderBatch(body []byte, idempotencyKey, actor string) (int, interface{}) {
+	var req models.BatchOrderRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		h.logger.WithError(err).Error("Failed to decode order batch request")
+		return http.StatusBadRequest, errorPayload("Invalid request body")
+	}
+	if len(req.Orders) == 0 {
+		return http.StatusBadRequest, errorPayload("Batch must contain at least one order")
+	}
+	if len(req.Orders) > models.MaxBatchSize {
+		return http.StatusBadRequest, errorPayload(fmt.Sprintf("Batch must not contain more than %d orders", models.MaxBatchSize))
+	}
+
+	results := make([]models.BatchOrderResult, len(req.Orders))
+	var orders []*models.Order
+	var positions []int
+	for i := range req.Orders {
+		order := &req.Orders[i]
+		key := ""
+		if idempotencyKey != "" {
+			key = fmt.Sprintf("%s/%d", idempotencyKey, i)
+		}
+		prepareOrder(order, key)
+		order.Actor = actor
+
+		results[i] = models.BatchOrderResult{Index: i, OrderID: order.ID}
+		if errs := validation.ValidateOrder(order); len(errs) > 0 {
+			results[i].Status = http.StatusUnprocessableEntity
+			results[i].Message = "Order validation failed"
+			results[i].Errors = errs
+			continue
+		}
+		orders = append(orders, order)
+		positions = append(positions, i)
+	}
+
+	strategy := h.phases.Current()
+	if len(orders) > 0 {
+		for j, result := range createOrders(strategy, orders) {
+			result.Index = positions[j]
+			results[positions[j]] = result
+		}
+	}
+
+	response := models.NewBatchOrderResponse(results)
+	h.logger.WithFields(logrus.Fields{
+		"total":           response.Total,
+		"created":         response.Created,
+		"existed":         response.Existed,
+		"failed":          response.Failed,
+		"phase":           strategy.Phase(),
+		"idempotency_key": idempotencyKey,
+	}).Info("Order batch processed")
+
+	if h.wsHub != nil {
+		h.wsHub.Broadcast("orders_batch_created", map[string]interface{}{
+			"total":   response.Total,
+			"created": response.Created,
+			"existed": response.Existed,
+			"failed":  response.Failed,
+			"phase":   strategy.Phase(),
+		}, "proxy")
+	}
+
+	return http.StatusOK, response
+}
+
+// prepareOrder fills in the fields a client may leave out. Without an ID,
+// the order gets one derived from its idempotency key, so a retry carries
+// the same ID, or a random one.
+func prepareOrder(order *models.Order, idempotencyKey string) {
+	order.IdempotencyKey = idempotencyKey
+	if order.ID == "" {
+		if idempotencyKey != "" {
+			order.ID = OrderIDForIdempotencyKey(idempotencyKey)
+		} else {
+			order.ID = uuid.New().String()
+		}
+	}
+	if order.CreatedAt.IsZero() {
+		order.CreatedAt = time.Now()
+	}
+	if order.Status == "" {
+		order.Status = "pending"
+	}
+	order.Currency = models.CurrencyOrDefault(order.Currency)
 }
 
+// CompareOrders compares the orders of both systems. The listing filters
+// (customer_id, status and the date ranges) narrow the comparison; every
+// matching order is compared.
 func (h *Handler) CompareOrders(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info("Comparing orders between systems")
 
+	query, err := models.ParseOrderQuery(r.URL.Query())
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Get orders from both systems
 	var orderServiceOrders []models.Order
 	var sapOrders []models.Order
@@ -109,11 +284,11 @@ func (h *Handler) CompareOrders(w http.ResponseWriter, r *http.Request) {
 
 	// Fetch from order service
 	if h.orderServiceClient != nil {
-		orderServiceOrders, orderServiceErr = h.orderServiceClient.GetOrders()
+		orderServiceOrders, orderServiceErr = models.CollectOrders(h.orderServiceClient.GetOrders, query)
 	}
 
 	// Fetch from SAP
-	sapOrders, sapErr = h.sapClient.GetOrders()
+	sapOrders, sapErr = models.CollectOrders(h.sapClient.GetOrders, query)
 
 	// Create comparison result
 	comparison := map[string]interface{}{
@@ -179,7 +354,7 @@ func (h *Handler) CompareOrder(w http.ResponseWriter, r *http.Request) {
 
 	// Add comparison analysis
 	if orderServiceOrder != nil && sapOrder != nil {
-		comparison["analysis"] = h.compareOrders(orderServiceOrder, sapOrder)
+		comparison["analysis"] = compareOrders(orderServiceOrder, sapOrder)
 	} else {
 		comparison["analysis"] = map[string]interface{}{
 			"status": "incomplete",
@@ -196,37 +371,401 @@ func (h *Handler) CompareOrder(w http.ResponseWriter, r *http.Request) {
 	h.respondWithJSON(w, http.StatusOK, comparison)
 }
 
+// Data sources reported in the X-Data-Source header of read responses
+const (
+	dataSourceOrderService = "order-service"
+	dataSourceSAP          = "sap"
+)
+
+// GetOrders returns one page of orders. Both systems understand the same
+// query and cursors, so a listing can continue on SAP when the Order Service
+// fails mid-way.
 func (h *Handler) GetOrders(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info("Fetching orders from order service")
-	
+
+	query, err := models.ParseOrderQuery(r.URL.Query())
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.serveOrders(w, query, (*OrderServiceClient).GetOrders)
+}
+
+// SearchOrders finds orders by item product and specifications, e.g.
+// ?specifications.color=blue, through the Order Service's search.
+func (h *Handler) SearchOrders(w http.ResponseWriter, r *http.Request) {
+	query, err := models.ParseOrderSearch(r.URL.Query())
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"product_id":     query.ProductID,
+		"specifications": query.Specifications,
+	}).Info("Searching orders")
+	h.serveOrders(w, query, (*OrderServiceClient).SearchOrders)
+}
+
+// CustomerOrderSummary returns the Order Service's totals of a customer's
+// orders, optionally limited to a from/to window.
+func (h *Handler) CustomerOrderSummary(w http.ResponseWriter, r *http.Request) {
+	customerID := mux.Vars(r)["id"]
+	window, err := models.ParseAnalyticsWindow(r.URL.Query(), 0, time.Now())
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if h.orderServiceClient == nil {
-		h.logger.Error("Order service client not configured")
-		h.respondWithError(w, http.StatusInternalServerError, "Order service not available")
+		h.respondWithError(w, http.StatusServiceUnavailable, "Order service is not configured")
 		return
 	}
-	
-	orders, err := h.orderServiceClient.GetOrders()
+
+	summary, err := h.orderServiceClient.GetCustomerOrderSummary(customerID, window)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to fetch orders from order service")
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch orders")
+		h.respondWithError(w, http.StatusServiceUnavailable, "Failed to fetch customer order summary")
 		return
 	}
-	
-	h.logger.WithField("count", len(orders)).Info("Successfully fetched orders")
-	
+
+	w.Header().Set("X-Data-Source", dataSourceOrderService)
+	h.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"summary": summary,
+	})
+}
+
+// OrderAnalytics returns the Order Service's order analytics. The window is
+// resolved here, so the response shows the window that was used.
+func (h *Handler) OrderAnalytics(w http.ResponseWriter, r *http.Request) {
+	window, err := models.ParseAnalyticsWindow(r.URL.Query(), models.DefaultAnalyticsDays, time.Now())
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	top, err := models.ParseTopCustomers(r.URL.Query())
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if h.orderServiceClient == nil {
+		h.respondWithError(w, http.StatusServiceUnavailable, "Order service is not configured")
+		return
+	}
+
+	analytics, err := h.orderServiceClient.GetOrderAnalytics(window, top)
+	if err != nil {
+		h.respondWithError(w, http.StatusServiceUnavailable, "Failed to fetch order analytics")
+		return
+	}
+
+	w.Header().Set("X-Data-Source", dataSourceOrderService)
+	h.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"analytics": analytics,
+	})
+}
+
+// serveOrders answers with a page of orders fetched from the Order Service,
+// or from SAP's listing, which applies the same filters, while the Order
+// Service is unavailable.
+func (h *Handler) serveOrders(w http.ResponseWriter, query models.OrderQuery, fetch func(*OrderServiceClient, models.OrderQuery) (*models.OrderPage, error)) {
+	dataSource := dataSourceOrderService
+	degraded := false
+
+	var page *models.OrderPage
+	var err error
+	if h.orderServiceClient != nil {
+		page, err = fetch(h.orderServiceClient, query)
+	} else {
+		err = fmt.Errorf("order service client not configured")
+	}
+
+	if err != nil {
+		// SAP holds a copy of every order, so reads can be served from it
+		// while the Order Service is down or its circuit breaker is open
+		h.logger.WithFields(logrus.Fields{
+			"error":                err.Error(),
+			"circuit_breaker_open": errors.Is(err, circuitbreaker.ErrCircuitBreakerOpen),
+		}).Warn("Order service read failed - falling back to SAP")
+
+		dataSource = dataSourceSAP
+		degraded = true
+		page, err = h.sapClient.GetOrders(query)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to fetch orders from SAP fallback")
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch orders")
+			return
+		}
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"count":       len(page.Orders),
+		"data_source": dataSource,
+		"degraded":    degraded,
+	}).Info("Successfully fetched orders")
+
 	// Add cache control headers to prevent caching
 	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 	w.Header().Set("Pragma", "no-cache")
 	w.Header().Set("Expires", "0")
-	
+	w.Header().Set("X-Data-Source", dataSource)
+
 	h.respondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-		"orders": orders,
-		"count": len(orders),
-		"timestamp": time.Now().Format(time.RFC3339),
+		"success":     true,
+		"orders":      page.Orders,
+		"count":       len(page.Orders),
+		"next_cursor": page.NextCursor,
+		"has_more":    page.NextCursor != "",
+		"data_source": dataSource,
+		"degraded":    degraded,
+		"timestamp":   time.Now().Format(time.RFC3339),
 	})
 }
 
+// Sync states reported by GetOrder
+const (
+	syncStatePending  = "pending"
+	syncStateSynced   = "synced"
+	syncStateDiverged = "diverged"
+	syncStateUnknown  = "unknown"
+)
+
+// GetOrder returns the Order Service record enriched with the SAP view of the
+// same order and a sync state. If the Order Service does not have the order
+// or cannot be reached, the SAP record is returned instead.
+func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	orderID := mux.Vars(r)["id"]
+
+	var osOrder *models.Order
+	var osErr error
+	if h.orderServiceClient != nil {
+		osOrder, osErr = h.orderServiceClient.GetOrder(orderID)
+	} else {
+		osErr = fmt.Errorf("order service client not configured")
+	}
+
+	sapRecord, sapErr := h.sapClient.GetOrderRecord(orderID)
+
+	osNotFound := errors.Is(osErr, ErrOrderNotFound)
+	sapNotFound := errors.Is(sapErr, sap.ErrOrderNotFound)
+
+	if osOrder == nil && sapRecord == nil {
+		if (osNotFound || h.orderServiceClient == nil) && sapNotFound {
+			h.respondWithError(w, http.StatusNotFound, "Order not found")
+			return
+		}
+		h.logger.WithFields(logrus.Fields{
+			"order_id":            orderID,
+			"order_service_error": getErrorString(osErr),
+			"sap_error":           getErrorString(sapErr),
+		}).Error("Failed to fetch order from both systems")
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch order")
+		return
+	}
+
+	sapView := map[string]interface{}{
+		"found": sapRecord != nil,
+		"error": getErrorString(sapErr),
+	}
+	if sapRecord != nil {
+		sapView["status"] = sapRecord.Status
+		sapView["document_id"] = sapRecord.DocumentID
+		sapView["received_at"] = sapRecord.ReceivedAt
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"sap":     sapView,
+	}
+
+	dataSource := dataSourceOrderService
+	degraded := false
+	syncState := syncStateUnknown
+
+	switch {
+	case osOrder != nil && sapRecord != nil:
+		response["order"] = osOrder
+		analysis := compareOrders(osOrder, &sapRecord.Order)
+		if analysis["perfect_match"].(bool) {
+			syncState = syncStateSynced
+		} else {
+			syncState = syncStateDiverged
+			response["differences"] = analysis["differences"]
+		}
+	case osOrder != nil:
+		response["order"] = osOrder
+		if sapNotFound {
+			// SAP has not consumed the order event yet
+			syncState = syncStatePending
+		}
+	default:
+		// SAP holds a copy of every order, so it serves the read while the
+		// Order Service is down or does not have the order
+		response["order"] = &sapRecord.Order
+		dataSource = dataSourceSAP
+		degraded = !osNotFound
+		if osNotFound {
+			syncState = syncStatePending
+		}
+		if degraded {
+			h.logger.WithFields(logrus.Fields{
+				"order_id":             orderID,
+				"error":                getErrorString(osErr),
+				"circuit_breaker_open": errors.Is(osErr, circuitbreaker.ErrCircuitBreakerOpen),
+			}).Warn("Order service read failed - falling back to SAP")
+		}
+	}
+
+	response["sync_state"] = syncState
+	response["data_source"] = dataSource
+	response["degraded"] = degraded
+
+	h.logger.WithFields(logrus.Fields{
+		"order_id":    orderID,
+		"sync_state":  syncState,
+		"data_source": dataSource,
+		"degraded":    degraded,
+	}).Info("Order lookup completed")
+
+	w.Header().Set("X-Data-Source", dataSource)
+	if osOrder != nil && osOrder.Version > 0 {
+		// Only the Order Service's version can be used in If-Match
+		w.Header().Set("ETag", models.ETag(osOrder.Version))
+	}
+	h.respondWithJSON(w, http.StatusOK, response)
+}
+
+// UpdateOrder forwards an order update to the Order Service, which publishes
+// it to SAP. The client sends the ETag it read in If-Match.
+func (h *Handler) UpdateOrder(w http.ResponseWriter, r *http.Request) {
+	orderID := mux.Vars(r)["id"]
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		h.respondWithError(w, http.StatusPreconditionRequired, "If-Match header with the order's ETag is required")
+		return
+	}
+
+	var update models.OrderUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if update.IsEmpty() {
+		h.respondWithError(w, http.StatusBadRequest, "Update must change delivery_date or items")
+		return
+	}
+
+	if h.orderServiceClient == nil {
+		h.respondWithError(w, http.StatusServiceUnavailable, "Order service is not configured")
+		return
+	}
+
+	resp, etag, err := h.orderServiceClient.UpdateOrder(orderID, update, ifMatch, r.Header.Get(ActorHeader))
+	var errs validation.Errors
+	switch {
+	case errors.Is(err, ErrOrderNotFound):
+		h.respondWithError(w, http.StatusNotFound, "Order not found")
+		return
+	case errors.Is(err, ErrOrderVersionMismatch):
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		h.respondWithError(w, http.StatusPreconditionFailed, "Order has changed since it was read; fetch it again and retry")
+		return
+	case errors.Is(err, ErrOrderConflict):
+		h.respondWithError(w, http.StatusConflict, strings.TrimPrefix(err.Error(), ErrOrderConflict.Error()+": "))
+		return
+	case errors.As(err, &errs):
+		h.respondWithJSON(w, http.StatusUnprocessableEntity, validationErrorPayload(errs))
+		return
+	case err != nil:
+		h.respondWithError(w, http.StatusServiceUnavailable, "Failed to update order")
+		return
+	}
+
+	if h.wsHub != nil && resp.Order != nil {
+		h.wsHub.Broadcast("order_updated", map[string]interface{}{
+			"order_id": orderID,
+			"version":  resp.Order.Version,
+		}, "proxy")
+	}
+
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	h.respondWithJSON(w, http.StatusOK, resp)
+}
+
+// OrderHistory returns an order's audit trail from the Order Service. SAP
+// keeps no history, so there is no fallback.
+func (h *Handler) OrderHistory(w http.ResponseWriter, r *http.Request) {
+	orderID := mux.Vars(r)["id"]
+
+	if h.orderServiceClient == nil {
+		h.respondWithError(w, http.StatusServiceUnavailable, "Order service is not configured")
+		return
+	}
+
+	history, err := h.orderServiceClient.GetOrderHistory(orderID)
+	switch {
+	case errors.Is(err, ErrOrderNotFound):
+		h.respondWithError(w, http.StatusNotFound, "Order not found")
+		return
+	case err != nil:
+		h.respondWithError(w, http.StatusServiceUnavailable, "Failed to get order history")
+		return
+	}
+
+	w.Header().Set("X-Data-Source", dataSourceOrderService)
+	h.respondWithJSON(w, http.StatusOK, history)
+}
+
+// CancelOrder forwards a cancellation request to the Order Service, which
+// asks SAP to confirm it. The order is cancelled once SAP has answered.
+func (h *Handler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	orderID := mux.Vars(r)["id"]
+
+	var req models.CancellationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !models.IsValidCancellationReason(req.ReasonCode) {
+		payload := errorPayload(fmt.Sprintf("Unknown cancellation reason %q", req.ReasonCode))
+		payload["valid_reasons"] = models.CancellationReasons()
+		h.respondWithJSON(w, http.StatusBadRequest, payload)
+		return
+	}
+
+	if h.orderServiceClient == nil {
+		h.respondWithError(w, http.StatusServiceUnavailable, "Order service is not configured")
+		return
+	}
+
+	resp, err := h.orderServiceClient.CancelOrder(orderID, req, r.Header.Get(ActorHeader))
+	switch {
+	case errors.Is(err, ErrOrderNotFound):
+		h.respondWithError(w, http.StatusNotFound, "Order not found")
+		return
+	case errors.Is(err, ErrOrderConflict):
+		h.respondWithError(w, http.StatusConflict, strings.TrimPrefix(err.Error(), ErrOrderConflict.Error()+": "))
+		return
+	case err != nil:
+		h.respondWithError(w, http.StatusServiceUnavailable, "Failed to cancel order")
+		return
+	}
+
+	if h.wsHub != nil {
+		h.wsHub.Broadcast("order_cancellation_requested", map[string]interface{}{
+			"order_id":    orderID,
+			"reason_code": req.ReasonCode,
+		}, "proxy")
+	}
+
+	h.respondWithJSON(w, http.StatusAccepted, resp)
+}
+
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	h.respondWithJSON(w, http.StatusOK, map[string]string{
 		"status": "healthy",
@@ -242,10 +781,20 @@ func (h *Handler) respondWithJSON(w http.ResponseWriter, code int, payload inter
 }
 
 func (h *Handler) respondWithError(w http.ResponseWriter, code int, message string) {
-	h.respondWithJSON(w, code, map[string]interface{}{
+	h.respondWithJSON(w, code, errorPayload(message))
+}
+
+func errorPayload(message string) map[string]interface{} {
+	return map[string]interface{}{
 		"success": false,
 		"message": message,
-	})
+	}
+}
+
+func validationErrorPayload(errs validation.Errors) map[string]interface{} {
+	payload := errorPayload("Order validation failed")
+	payload["errors"] = errs
+	return payload
 }
 
 func getErrorString(err error) string {
@@ -301,11 +850,12 @@ func (h *Handler) analyzeOrderSets(orderServiceOrders, sapOrders []models.Order)
 	return analysis
 }
 
-func (h *Handler) compareOrders(order1, order2 *models.Order) map[string]interface{} {
+func compareOrders(order1, order2 *models.Order) map[string]interface{} {
 	analysis := map[string]interface{}{
 		"id_match": order1.ID == order2.ID,
 		"customer_id_match": order1.CustomerID == order2.CustomerID,
-		"total_amount_match": order1.TotalAmount == order2.TotalAmount,
+		"total_amount_match": order1.TotalAmount == order2.TotalAmount &&
+			models.CurrencyOrDefault(order1.Currency) == models.CurrencyOrDefault(order2.Currency),
 		"status_match": order1.Status == order2.Status,
 		"items_count_match": len(order1.Items) == len(order2.Items),
 	}
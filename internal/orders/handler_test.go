@@ -0,0 +1,444 @@
+package orders
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jogardn/strangler-demo/internal/circuitbreaker"
+	"github.com/jogardn/strangler-demo/pkg/models"
+)
+
+func newCancelTestHandler(t *testing.T, status int, message string) (*Handler, *circuitbreaker.Manager) {
+	t.Helper()
+
+	osServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/orders/order-1/cancel" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(models.OrderResponse{
+			Success: status == http.StatusAccepted,
+			Message: message,
+			Order:   &models.Order{ID: "order-1"},
+		})
+	}))
+	t.Cleanup(osServer.Close)
+
+	logger := newTestLogger()
+	cbManager := circuitbreaker.NewManager(logger)
+	cbManager.GetOrCreate("order-service", circuitbreaker.Config{MaxFailures: 1, Timeout: time.Minute, MaxRequests: 1})
+	return NewHandler(nil, NewOrderServiceClient(osServer.URL, logger, cbManager), logger), cbManager
+}
+
+func cancelOrder(handler *Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/orders/order-1/cancel", bytes.NewBufferString(body))
+	req = mux.SetURLVars(req, map[string]string{"id": "order-1"})
+	rec := httptest.NewRecorder()
+	handler.CancelOrder(rec, req)
+	return rec
+}
+
+func TestCancelOrderAccepted(t *testing.T) {
+	handler, _ := newCancelTestHandler(t, http.StatusAccepted, "Cancellation requested")
+	hub := &recordingHub{}
+	handler.SetWebSocketHub(hub)
+
+	rec := cancelOrder(handler, `{"reason_code":"customer_request"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(hub.messages) != 1 || hub.messages[0] != "order_cancellation_requested" {
+		t.Errorf("Expected a cancellation broadcast, got %v", hub.messages)
+	}
+}
+
+func TestCancelOrderRejectsUnknownReason(t *testing.T) {
+	handler, _ := newCancelTestHandler(t, http.StatusAccepted, "")
+
+	rec := cancelOrder(handler, `{"reason_code":"changed_mind"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", rec.Code)
+	}
+}
+
+func TestCancelOrderConflictDoesNotTripBreaker(t *testing.T) {
+	handler, cbManager := newCancelTestHandler(t, http.StatusConflict, "invalid status transition from shipped to cancelled")
+
+	for i := 0; i < 2; i++ {
+		rec := cancelOrder(handler, `{"reason_code":"customer_request"}`)
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("Expected 409, got %d: %s", rec.Code, rec.Body.String())
+		}
+
+		var body map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		if body["message"] != "invalid status transition from shipped to cancelled" {
+			t.Errorf("Expected the Order Service message, got %v", body["message"])
+		}
+	}
+
+	if state := cbManager.Get("order-service").State(); state != circuitbreaker.StateClosed {
+		t.Errorf("Expected breaker to stay closed, got %s", state)
+	}
+}
+
+func TestGetOrdersForwardsQueryAndCursor(t *testing.T) {
+	var received url.Values
+	osServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.URL.Query()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":     true,
+			"orders":      []models.Order{{ID: "order-1"}},
+			"count":       1,
+			"next_cursor": "next-page",
+		})
+	}))
+	defer osServer.Close()
+
+	logger := newTestLogger()
+	cbManager := circuitbreaker.NewManager(logger)
+	cbManager.GetOrCreate("order-service", circuitbreaker.Config{MaxFailures: 10, Timeout: time.Minute, MaxRequests: 1})
+	handler := NewHandler(nil, NewOrderServiceClient(osServer.URL, logger, cbManager), logger)
+
+	rec := httptest.NewRecorder()
+	handler.GetOrders(rec, httptest.NewRequest("GET", "/orders?customer_id=customer-1&sort=-delivery_date&limit=10", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if received.Get("customer_id") != "customer-1" || received.Get("sort") != "-delivery_date" || received.Get("limit") != "10" {
+		t.Errorf("Expected the query to be forwarded, got %v", received)
+	}
+
+	var body map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if body["next_cursor"] != "next-page" || body["has_more"] != true {
+		t.Errorf("Expected the next cursor to be returned, got %v", body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.GetOrders(rec, httptest.NewRequest("GET", "/orders?limit=-1", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid limit, got %d", rec.Code)
+	}
+}
+
+func TestUpdateOrderForwardsIfMatch(t *testing.T) {
+	var ifMatch string
+	osServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/orders/order-1" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		ifMatch = r.Header.Get("If-Match")
+		if ifMatch != `"2"` {
+			w.Header().Set("ETag", `"2"`)
+			w.WriteHeader(http.StatusPreconditionFailed)
+			json.NewEncoder(w).Encode(models.OrderResponse{Message: "version mismatch"})
+			return
+		}
+		w.Header().Set("ETag", `"3"`)
+		json.NewEncoder(w).Encode(models.OrderResponse{Success: true, Order: &models.Order{ID: "order-1", Version: 3}})
+	}))
+	defer osServer.Close()
+
+	logger := newTestLogger()
+	cbManager := circuitbreaker.NewManager(logger)
+	cbManager.GetOrCreate("order-service", circuitbreaker.Config{MaxFailures: 1, Timeout: time.Minute, MaxRequests: 1})
+	handler := NewHandler(nil, NewOrderServiceClient(osServer.URL, logger, cbManager), logger)
+	hub := &recordingHub{}
+	handler.SetWebSocketHub(hub)
+
+	update := func(ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/orders/order-1", bytes.NewBufferString(`{"delivery_date":"2030-01-01T00:00:00Z"}`))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		req = mux.SetURLVars(req, map[string]string{"id": "order-1"})
+		rec := httptest.NewRecorder()
+		handler.UpdateOrder(rec, req)
+		return rec
+	}
+
+	if rec := update(""); rec.Code != http.StatusPreconditionRequired {
+		t.Errorf("Expected 428 without If-Match, got %d", rec.Code)
+	}
+
+	rec := update(`"1"`)
+	if rec.Code != http.StatusPreconditionFailed || rec.Header().Get("ETag") != `"2"` {
+		t.Errorf("Expected 412 with the current ETag, got %d %q", rec.Code, rec.Header().Get("ETag"))
+	}
+	if state := cbManager.Get("order-service").State(); state != circuitbreaker.StateClosed {
+		t.Errorf("Expected breaker to stay closed, got %s", state)
+	}
+
+	rec = update(`"2"`)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"3"` {
+		t.Fatalf("Expected 200 with the new ETag, got %d %q: %s", rec.Code, rec.Header().Get("ETag"), rec.Body.String())
+	}
+	if len(hub.messages) != 1 || hub.messages[0] != "order_updated" {
+		t.Errorf("Expected an update broadcast, got %v", hub.messages)
+	}
+}
+
+func TestOrderHistoryAndCancelForwardActor(t *testing.T) {
+	var actors, sources []string
+	osServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actors = append(actors, r.Header.Get("X-Actor"))
+		sources = append(sources, r.Header.Get(ChangeSourceHeader))
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/orders/order-1/cancel":
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(models.OrderResponse{Success: true, Order: &models.Order{ID: "order-1"}})
+		case r.Method == "GET" && r.URL.Path == "/orders/order-1/history":
+			json.NewEncoder(w).Encode(models.OrderHistoryResponse{Success: true, OrderID: "order-1", History: []models.AuditEntry{
+				{ID: 1, OrderID: "order-1", Action: models.AuditActionCreated, Actor: "alice", Source: models.AuditSourceProxy},
+			}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer osServer.Close()
+
+	logger := newTestLogger()
+	cbManager := circuitbreaker.NewManager(logger)
+	cbManager.GetOrCreate("order-service", circuitbreaker.Config{MaxFailures: 1, Timeout: time.Minute, MaxRequests: 1})
+	handler := NewHandler(nil, NewOrderServiceClient(osServer.URL, logger, cbManager), logger)
+
+	req := httptest.NewRequest("POST", "/orders/order-1/cancel", bytes.NewBufferString(`{"reason_code":"customer_request"}`))
+	req.Header.Set("X-Actor", "alice")
+	req = mux.SetURLVars(req, map[string]string{"id": "order-1"})
+	rec := httptest.NewRecorder()
+	handler.CancelOrder(rec, req)
+	if rec.Code != http.StatusAccepted || len(actors) != 1 || actors[0] != "alice" {
+		t.Fatalf("Expected the cancel to be forwarded with its actor, got %d %v", rec.Code, actors)
+	}
+	if sources[0] != models.AuditSourceProxy {
+		t.Errorf("Expected the cancel to be marked as sent by the proxy, got %q", sources[0])
+	}
+
+	history := func(orderID string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/orders/"+orderID+"/history", nil), map[string]string{"id": orderID})
+		rec := httptest.NewRecorder()
+		handler.OrderHistory(rec, req)
+		return rec
+	}
+
+	rec = history("order-1")
+	var body models.OrderHistoryResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusOK || len(body.History) != 1 || body.History[0].Actor != "alice" {
+		t.Errorf("Expected the order's history, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := history("missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing order, got %d", rec.Code)
+	}
+	if state := cbManager.Get("order-service").State(); state != circuitbreaker.StateClosed {
+		t.Errorf("Expected breaker to stay closed, got %s", state)
+	}
+}
+
+func TestCreateOrderBatchSendsOneOrderServiceRequest(t *testing.T) {
+	var batches []models.BatchOrderRequest
+	osServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/orders/batch" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var batch models.BatchOrderRequest
+		json.NewDecoder(r.Body).Decode(&batch)
+		batches = append(batches, batch)
+
+		results := make([]models.BatchOrderResult, len(batch.Orders))
+		for i, order := range batch.Orders {
+			results[i] = models.BatchOrderResult{Index: i, OrderID: order.ID, Status: http.StatusCreated, Success: true}
+		}
+		json.NewEncoder(w).Encode(models.NewBatchOrderResponse(results))
+	}))
+	defer osServer.Close()
+
+	logger := newTestLogger()
+	cbManager := circuitbreaker.NewManager(logger)
+	cbManager.GetOrCreate("order-service", circuitbreaker.Config{MaxFailures: 10, Timeout: time.Minute, MaxRequests: 1})
+	osClient := NewOrderServiceClient(osServer.URL, logger, cbManager)
+	handler := NewHandler(nil, osClient, logger)
+	phases := NewPhaseManager(nil, osClient, NewCanaryRouter(100, logger), &RuleEngine{}, NewShadowStore(10), nil, logger)
+	if err := phases.SetPhase(PhaseEventDriven, "test"); err != nil {
+		t.Fatal(err)
+	}
+	handler.SetPhaseManager(phases)
+
+	order := `{"customer_id":"customer-1","items":[{"product_id":"P-1","quantity":2,"unit_price":5}],"total_amount":10,"delivery_date":"2030-06-20T00:00:00Z"}`
+	body := `{"orders":[` + order + `,{"customer_id":"customer-1"},` + order + `]}`
+	req := httptest.NewRequest("POST", "/orders/batch", bytes.NewBufferString(body))
+	req.Header.Set(IdempotencyKeyHeader, "batch-key")
+	rec := httptest.NewRecorder()
+	handler.CreateOrderBatch(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if len(batches) != 1 || len(batches[0].Orders) != 2 {
+		t.Fatalf("Expected one batch of the 2 valid orders, got %+v", batches)
+	}
+	if batches[0].Orders[1].ID != OrderIDForIdempotencyKey("batch-key/2") {
+		t.Errorf("Expected the ID to be derived from the key and position, got %s", batches[0].Orders[1].ID)
+	}
+
+	var response models.BatchOrderResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	statuses := []int{http.StatusCreated, http.StatusUnprocessableEntity, http.StatusCreated}
+	for i, result := range response.Results {
+		if result.Index != i || result.Status != statuses[i] {
+			t.Errorf("Expected result %d to have status %d, got %+v", i, statuses[i], result)
+		}
+	}
+	if response.Created != 2 || response.Failed != 1 {
+		t.Errorf("Expected 2 created and 1 failed, got %+v", response)
+	}
+}
+
+func TestCreateOrderBatchIsRetriedWithTheSameKeyAfterServerFailures(t *testing.T) {
+	var mutex sync.Mutex
+	down := true
+	requests := 0
+	osServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		requests++
+		if down {
+			down = false
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var batch models.BatchOrderRequest
+		json.NewDecoder(r.Body).Decode(&batch)
+		results := make([]models.BatchOrderResult, len(batch.Orders))
+		for i, order := range batch.Orders {
+			results[i] = models.BatchOrderResult{Index: i, OrderID: order.ID, Status: http.StatusCreated, Success: true}
+		}
+		json.NewEncoder(w).Encode(models.NewBatchOrderResponse(results))
+	}))
+	defer osServer.Close()
+
+	logger := newTestLogger()
+	cbManager := circuitbreaker.NewManager(logger)
+	cbManager.GetOrCreate("order-service", circuitbreaker.Config{MaxFailures: 1, Timeout: 100 * time.Millisecond, MaxRequests: 1})
+	osClient := NewOrderServiceClient(osServer.URL, logger, cbManager)
+	handler := NewHandler(nil, osClient, logger)
+	handler.SetIdempotencyStore(NewIdempotencyStore(time.Hour))
+	phases := NewPhaseManager(nil, osClient, NewCanaryRouter(100, logger), &RuleEngine{}, NewShadowStore(10), nil, logger)
+	if err := phases.SetPhase(PhaseEventDriven, "test"); err != nil {
+		t.Fatal(err)
+	}
+	handler.SetPhaseManager(phases)
+
+	order := `{"customer_id":"customer-1","items":[{"product_id":"P-1","quantity":2,"unit_price":5}],"total_amount":10,"delivery_date":"2030-06-20T00:00:00Z"}`
+	send := func() (*httptest.ResponseRecorder, models.BatchOrderResponse) {
+		req := httptest.NewRequest("POST", "/orders/batch", bytes.NewBufferString(`{"orders":[`+order+`]}`))
+		req.Header.Set(IdempotencyKeyHeader, "batch-key")
+		rec := httptest.NewRecorder()
+		handler.CreateOrderBatch(rec, req)
+		var response models.BatchOrderResponse
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		return rec, response
+	}
+
+	// The failure opens the breaker, which then rejects the retry
+	for attempt := 0; attempt < 2; attempt++ {
+		rec, response := send()
+		if rec.Header().Get(IdempotentReplayHeader) != "" || response.Results[0].Status != http.StatusInternalServerError {
+			t.Fatalf("Attempt %d: expected the failure to be reported, not replayed, got %s", attempt, rec.Body.String())
+		}
+	}
+	if state := cbManager.Get("order-service").State(); state != circuitbreaker.StateOpen || requests != 1 {
+		t.Fatalf("Expected the open breaker to stop the retry, got %s after %d requests", state, requests)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	rec, response := send()
+	if rec.Header().Get(IdempotentReplayHeader) != "" || response.Created != 1 {
+		t.Errorf("Expected the retry with the same key to create the order, got %s", rec.Body.String())
+	}
+	if rec, _ := send(); rec.Header().Get(IdempotentReplayHeader) != "true" {
+		t.Errorf("Expected the successful batch to be replayed, got %s", rec.Body.String())
+	}
+}
+
+func TestSearchOrdersForwardsItemFilters(t *testing.T) {
+	var path string
+	var received url.Values
+	osServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		received = r.URL.Query()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"orders":  []models.Order{{ID: "order-1"}},
+			"count":   1,
+		})
+	}))
+	defer osServer.Close()
+
+	logger := newTestLogger()
+	cbManager := circuitbreaker.NewManager(logger)
+	cbManager.GetOrCreate("order-service", circuitbreaker.Config{MaxFailures: 10, Timeout: time.Minute, MaxRequests: 1})
+	handler := NewHandler(nil, NewOrderServiceClient(osServer.URL, logger, cbManager), logger)
+
+	rec := httptest.NewRecorder()
+	handler.SearchOrders(rec, httptest.NewRequest("GET", "/orders/search?product_id=WIDGET-001&specifications.color=blue", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if path != "/orders/search" || received.Get("product_id") != "WIDGET-001" || received.Get("specifications.color") != "blue" {
+		t.Errorf("Expected the search to be forwarded, got %s %v", path, received)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.SearchOrders(rec, httptest.NewRequest("GET", "/orders/search?status=pending", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without item filters, got %d", rec.Code)
+	}
+}
+
+func TestOrderAnalyticsForwardsWindow(t *testing.T) {
+	var received url.Values
+	osServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/analytics/orders" {
+			t.Errorf("Unexpected request %s", r.URL.Path)
+		}
+		received = r.URL.Query()
+		analytics := models.NewOrderAnalytics(models.AnalyticsWindow{})
+		analytics.Add(models.StatusPending, "USD", 2, 5000)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "analytics": analytics})
+	}))
+	defer osServer.Close()
+
+	logger := newTestLogger()
+	cbManager := circuitbreaker.NewManager(logger)
+	cbManager.GetOrCreate("order-service", circuitbreaker.Config{MaxFailures: 10, Timeout: time.Minute, MaxRequests: 1})
+	handler := NewHandler(nil, NewOrderServiceClient(osServer.URL, logger, cbManager), logger)
+
+	rec := httptest.NewRecorder()
+	handler.OrderAnalytics(rec, httptest.NewRequest("GET", "/analytics/orders?to=2025-07-01T00:00:00Z&top=5", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	// The default window ends at to
+	if received.Get("from") != "2025-06-01T00:00:00Z" || received.Get("to") != "2025-07-01T00:00:00Z" || received.Get("top") != "5" {
+		t.Errorf("Expected the resolved window to be forwarded, got %v", received)
+	}
+	if !strings.Contains(rec.Body.String(), `"average_order_value":25.00`) {
+		t.Errorf("Expected the Order Service analytics, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.OrderAnalytics(rec, httptest.NewRequest("GET", "/analytics/orders?top=1000", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid top, got %d", rec.Code)
+	}
+}
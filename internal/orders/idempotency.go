@@ -0,0 +1,136 @@
+package orders
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyKeyHeader is the request header clients set to make order
+// creation safe to retry.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayHeader marks a response served from the idempotency store.
+const IdempotentReplayHeader = "Idempotent-Replayed"
+
+var (
+	// ErrIdempotencyInFlight is returned while the first request with a key
+	// is still being processed.
+	ErrIdempotencyInFlight = errors.New("a request with this idempotency key is still in progress")
+	// ErrIdempotencyKeyReused is returned when a key is sent again with a
+	// different request body.
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+)
+
+// orderIDNamespace scopes order IDs derived from idempotency keys.
+var orderIDNamespace = uuid.MustParse("6f1c1b7e-3f7a-4a8e-9a55-0d3c2f2b9e41")
+
+// OrderIDForIdempotencyKey derives a stable order ID from an idempotency key,
+// so a retry that reaches a backend after the proxy forgot the key still
+// carries the same order ID.
+func OrderIDForIdempotencyKey(key string) string {
+	return uuid.NewSHA1(orderIDNamespace, []byte(key)).String()
+}
+
+// StoredResponse is a response kept for replay.
+type StoredResponse struct {
+	StatusCode int
+	Body       []byte
+}
+
+type idempotencyEntry struct {
+	fingerprint string
+	response    *StoredResponse
+	expiresAt   time.Time
+}
+
+// IdempotencyStore maps idempotency keys to the response of the first
+// request that used them. Entries expire after the TTL.
+type IdempotencyStore struct {
+	mutex     sync.Mutex
+	ttl       time.Duration
+	entries   map[string]*idempotencyEntry
+	lastSweep time.Time
+}
+
+func NewIdempotencyStore(ttl time.Duration) *IdempotencyStore {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &IdempotencyStore{
+		ttl:       ttl,
+		entries:   make(map[string]*idempotencyEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// RequestFingerprint hashes a request body so a reused key can be detected.
+func RequestFingerprint(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// Begin claims the key for a new request. It returns the stored response if
+// the key was already completed with the same body, or an error if the key
+// is in flight or was used with a different body. A nil response and nil
+// error mean the caller must process the request and then call Complete or
+// Abort.
+func (s *IdempotencyStore) Begin(key, fingerprint string) (*StoredResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	if entry, exists := s.entries[key]; exists && entry.expiresAt.After(now) {
+		if entry.fingerprint != fingerprint {
+			return nil, ErrIdempotencyKeyReused
+		}
+		if entry.response == nil {
+			return nil, ErrIdempotencyInFlight
+		}
+		return entry.response, nil
+	}
+
+	s.entries[key] = &idempotencyEntry{
+		fingerprint: fingerprint,
+		expiresAt:   now.Add(s.ttl),
+	}
+	return nil, nil
+}
+
+// Complete stores the response for replay.
+func (s *IdempotencyStore) Complete(key string, statusCode int, body []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if entry, exists := s.entries[key]; exists {
+		entry.response = &StoredResponse{StatusCode: statusCode, Body: body}
+		entry.expiresAt = time.Now().Add(s.ttl)
+	}
+}
+
+// Abort releases the key so the request can be retried, e.g. after a
+// backend failure.
+func (s *IdempotencyStore) Abort(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.entries, key)
+}
+
+// sweep drops expired entries at most once a minute. Callers hold the lock.
+func (s *IdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	for key, entry := range s.entries {
+		if !entry.expiresAt.After(now) {
+			delete(s.entries, key)
+		}
+	}
+	s.lastSweep = now
+}
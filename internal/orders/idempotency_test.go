@@ -0,0 +1,109 @@
+package orders
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jogardn/strangler-demo/internal/circuitbreaker"
+	"github.com/jogardn/strangler-demo/internal/sap"
+)
+
+func TestIdempotencyStoreReplay(t *testing.T) {
+	store := NewIdempotencyStore(time.Hour)
+	fingerprint := RequestFingerprint([]byte(`{"customer_id":"c1"}`))
+
+	stored, err := store.Begin("key-1", fingerprint)
+	if stored != nil || err != nil {
+		t.Fatalf("Expected first request to proceed, got %v %v", stored, err)
+	}
+
+	if _, err := store.Begin("key-1", fingerprint); !errors.Is(err, ErrIdempotencyInFlight) {
+		t.Errorf("Expected in-flight error, got %v", err)
+	}
+
+	store.Complete("key-1", http.StatusCreated, []byte(`{"success":true}`))
+
+	stored, err = store.Begin("key-1", fingerprint)
+	if err != nil {
+		t.Fatalf("Expected replay, got error %v", err)
+	}
+	if stored == nil || stored.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected stored 201 response, got %v", stored)
+	}
+
+	if _, err := store.Begin("key-1", RequestFingerprint([]byte(`{}`))); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("Expected key reuse error, got %v", err)
+	}
+}
+
+func TestIdempotencyStoreAbortAllowsRetry(t *testing.T) {
+	store := NewIdempotencyStore(time.Hour)
+
+	store.Begin("key-1", "a")
+	store.Abort("key-1")
+
+	if stored, err := store.Begin("key-1", "a"); stored != nil || err != nil {
+		t.Errorf("Expected retry after abort to proceed, got %v %v", stored, err)
+	}
+}
+
+func TestIdempotencyStoreExpiry(t *testing.T) {
+	store := NewIdempotencyStore(time.Millisecond)
+
+	store.Begin("key-1", "a")
+	store.Complete("key-1", http.StatusCreated, nil)
+	time.Sleep(5 * time.Millisecond)
+
+	if stored, err := store.Begin("key-1", "b"); stored != nil || err != nil {
+		t.Errorf("Expected expired key to be reusable, got %v %v", stored, err)
+	}
+}
+
+func TestOrderIDForIdempotencyKeyIsStable(t *testing.T) {
+	if OrderIDForIdempotencyKey("key-1") != OrderIDForIdempotencyKey("key-1") {
+		t.Error("Expected the same key to give the same order ID")
+	}
+	if OrderIDForIdempotencyKey("key-1") == OrderIDForIdempotencyKey("key-2") {
+		t.Error("Expected different keys to give different order IDs")
+	}
+}
+
+func TestCreateOrderReplaysIdempotentRequest(t *testing.T) {
+	logger := newTestLogger()
+	sapBackend := &fakeBackend{status: http.StatusCreated}
+	sapServer := httptest.NewServer(sapBackend)
+	defer sapServer.Close()
+
+	cbManager := circuitbreaker.NewManager(logger)
+	cbManager.GetOrCreate("sap", circuitbreaker.Config{MaxFailures: 10, Timeout: time.Minute, MaxRequests: 1})
+	sapClient := sap.NewClient(sapServer.URL, logger, cbManager)
+
+	handler := NewHandler(sapClient, nil, logger)
+	handler.SetPhaseManager(NewPhaseManager(sapClient, nil, NewCanaryRouter(0, logger), &RuleEngine{}, NewShadowStore(10), nil, logger))
+	handler.SetIdempotencyStore(NewIdempotencyStore(time.Hour))
+
+	body := []byte(`{"customer_id":"customer-1","items":[{"product_id":"P-1","quantity":2,"unit_price":5}],"total_amount":10,"delivery_date":"2025-06-20T00:00:00Z"}`)
+	var responses []*httptest.ResponseRecorder
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/orders", bytes.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, "retry-key")
+		rec := httptest.NewRecorder()
+		handler.CreateOrder(rec, req)
+		responses = append(responses, rec)
+	}
+
+	if sapBackend.received("POST") != 1 {
+		t.Errorf("Expected one backend write, got %d", sapBackend.received("POST"))
+	}
+	if responses[1].Header().Get(IdempotentReplayHeader) != "true" {
+		t.Error("Expected second response to be marked as replayed")
+	}
+	if responses[0].Code != responses[1].Code || responses[0].Body.String() != responses[1].Body.String() {
+		t.Errorf("Expected identical responses, got %d %q and %d %q",
+			responses[0].Code, responses[0].Body.String(), responses[1].Code, responses[1].Body.String())
+	}
+}
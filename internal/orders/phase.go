@@ -0,0 +1,281 @@
+package orders
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jogardn/strangler-demo/internal/sap"
+	"github.com/jogardn/strangler-demo/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Phase is a step of the strangler migration.
+type Phase string
+
+const (
+	// PhaseSAPOnly sends every order to SAP (Phase 1)
+	PhaseSAPOnly Phase = "sap_only"
+	// PhaseShadow answers from SAP and mirrors writes to the Order Service for comparison
+	PhaseShadow Phase = "shadow"
+	// PhaseDualWrite writes every order to SAP and the Order Service (Phase 2)
+	PhaseDualWrite Phase = "dual_write"
+	// PhaseEventDriven writes to the Order Service, which feeds SAP through Kafka (Phase 3)
+	PhaseEventDriven Phase = "event_driven"
+)
+
+// PhaseStrategy creates orders the way a migration phase requires.
+type PhaseStrategy interface {
+	Phase() Phase
+	CreateOrder(order *models.Order) (*models.OrderResponse, error)
+}
+
+// BatchPhaseStrategy is implemented by strategies that create a batch of
+// orders with fewer calls than one per order. The results are in the order
+// of orders.
+type BatchPhaseStrategy interface {
+	CreateOrders(orders []*models.Order) []models.BatchOrderResult
+}
+
+// createOrders creates orders with the strategy, as a batch if it supports
+// batches and one by one otherwise.
+func createOrders(strategy PhaseStrategy, orders []*models.Order) []models.BatchOrderResult {
+	if batch, ok := strategy.(BatchPhaseStrategy); ok {
+		return batch.CreateOrders(orders)
+	}
+
+	results := make([]models.BatchOrderResult, len(orders))
+	for i, order := range orders {
+		resp, err := strategy.CreateOrder(order)
+		results[i] = batchResult(order, resp, err)
+	}
+	return results
+}
+
+// batchResult reports the creation of one order with the status POST
+// /orders answers with.
+func batchResult(order *models.Order, resp *models.OrderResponse, err error) models.BatchOrderResult {
+	result := models.BatchOrderResult{OrderID: order.ID}
+	switch {
+	case err != nil:
+		result.Status = http.StatusInternalServerError
+		result.Message = "Failed to process order"
+	case !resp.Success:
+		result.Status = http.StatusBadRequest
+		result.Message = resp.Message
+	case resp.SyncState == models.SyncStatePending:
+		result.Status = http.StatusAccepted
+		result.Success = true
+		result.Message = resp.Message
+		result.Order = order
+	default:
+		result.Status = http.StatusCreated
+		result.Success = true
+		result.Message = resp.Message
+		result.Order = order
+	}
+	return result
+}
+
+type sapOnlyStrategy struct {
+	sapClient *sap.Client
+}
+
+func (s *sapOnlyStrategy) Phase() Phase {
+	return PhaseSAPOnly
+}
+
+func (s *sapOnlyStrategy) CreateOrder(order *models.Order) (*models.OrderResponse, error) {
+	return s.sapClient.CreateOrder(order)
+}
+
+type dualWriteStrategy struct {
+	sagas *SagaCoordinator
+}
+
+func (s *dualWriteStrategy) Phase() Phase {
+	return PhaseDualWrite
+}
+
+// CreateOrder writes to both systems as a saga so a failed Order Service
+// write is retried or compensated instead of leaving the systems apart.
+func (s *dualWriteStrategy) CreateOrder(order *models.Order) (*models.OrderResponse, error) {
+	return s.sagas.Execute(order)
+}
+
+type eventDrivenStrategy struct {
+	sapClient          *sap.Client
+	orderServiceClient *OrderServiceClient
+	router             *CanaryRouter
+	rules              *RuleEngine
+	logger             *logrus.Logger
+}
+
+func (s *eventDrivenStrategy) Phase() Phase {
+	return PhaseEventDriven
+}
+
+// CreateOrder sends the order to the Order Service, which publishes it to
+// Kafka for SAP. Routing rules are checked first; orders no rule matches are
+// split by the canary router, which keeps a share of them on SAP directly.
+func (s *eventDrivenStrategy) CreateOrder(order *models.Order) (*models.OrderResponse, error) {
+	if s.route(order) == BackendSAP {
+		return s.sapClient.CreateOrder(order)
+	}
+	return s.orderServiceClient.CreateOrder(order)
+}
+
+// CreateOrders routes each order like CreateOrder and sends the orders for
+// the Order Service in one batch. If the batch fails, each of its orders
+// fails.
+func (s *eventDrivenStrategy) CreateOrders(orders []*models.Order) []models.BatchOrderResult {
+	results := make([]models.BatchOrderResult, len(orders))
+	var batch []*models.Order
+	var positions []int
+	for i, order := range orders {
+		if s.route(order) == BackendSAP {
+			resp, err := s.sapClient.CreateOrder(order)
+			results[i] = batchResult(order, resp, err)
+			continue
+		}
+		batch = append(batch, order)
+		positions = append(positions, i)
+	}
+	if len(batch) == 0 {
+		return results
+	}
+
+	resp, err := s.orderServiceClient.CreateOrders(batch)
+	for j, order := range batch {
+		if err != nil {
+			results[positions[j]] = batchResult(order, nil, err)
+			continue
+		}
+		results[positions[j]] = resp.Results[j]
+	}
+	return results
+}
+
+// route picks the backend of an order and logs the decision.
+func (s *eventDrivenStrategy) route(order *models.Order) Backend {
+	var backend Backend
+	matchedRule := "canary"
+	if rule := s.rules.Match(order); rule != nil {
+		backend = rule.Target
+		matchedRule = rule.Name
+	} else {
+		backend = s.router.Route()
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"order_id":     order.ID,
+		"customer_id":  order.CustomerID,
+		"total_amount": order.TotalAmount,
+		"backend":      backend,
+		"rule":         matchedRule,
+	}).Info("Routing decision")
+
+	return backend
+}
+
+// PhaseManager holds the available phase strategies and the active one.
+type PhaseManager struct {
+	mutex      sync.RWMutex
+	strategies map[Phase]PhaseStrategy
+	current    PhaseStrategy
+	lastChange time.Time
+	wsHub      WebSocketHub
+	logger     *logrus.Logger
+}
+
+// NewPhaseManager builds the strategies the configured clients allow. Without
+// an Order Service client only the SAP-only phase is available, and the
+// dual-write phase also needs a saga coordinator.
+func NewPhaseManager(sapClient *sap.Client, orderServiceClient *OrderServiceClient, router *CanaryRouter, rules *RuleEngine, shadowStore *ShadowStore, sagas *SagaCoordinator, logger *logrus.Logger) *PhaseManager {
+	m := &PhaseManager{
+		strategies: make(map[Phase]PhaseStrategy),
+		lastChange: time.Now(),
+		logger:     logger,
+	}
+
+	m.strategies[PhaseSAPOnly] = &sapOnlyStrategy{sapClient: sapClient}
+	if orderServiceClient != nil {
+		m.strategies[PhaseShadow] = newShadowStrategy(sapClient, orderServiceClient, shadowStore, logger)
+		if sagas != nil {
+			m.strategies[PhaseDualWrite] = &dualWriteStrategy{sagas: sagas}
+		}
+		m.strategies[PhaseEventDriven] = &eventDrivenStrategy{
+			sapClient:          sapClient,
+			orderServiceClient: orderServiceClient,
+			router:             router,
+			rules:              rules,
+			logger:             logger,
+		}
+	}
+
+	m.current = m.strategies[PhaseSAPOnly]
+	return m
+}
+
+func (m *PhaseManager) SetWebSocketHub(hub WebSocketHub) {
+	m.wsHub = hub
+}
+
+func (m *PhaseManager) Current() PhaseStrategy {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.current
+}
+
+// SetPhase switches the active strategy and broadcasts the change.
+func (m *PhaseManager) SetPhase(phase Phase, reason string) error {
+	strategy, exists := m.strategies[phase]
+	if !exists {
+		return fmt.Errorf("phase %q is not available", phase)
+	}
+
+	m.mutex.Lock()
+	previous := m.current.Phase()
+	m.current = strategy
+	m.lastChange = time.Now()
+	m.mutex.Unlock()
+
+	m.logger.WithFields(logrus.Fields{
+		"from_phase": previous,
+		"to_phase":   phase,
+		"reason":     reason,
+	}).Info("Migration phase changed")
+
+	if m.wsHub != nil {
+		m.wsHub.Broadcast("phase_changed", map[string]interface{}{
+			"from_phase": previous,
+			"to_phase":   phase,
+			"reason":     reason,
+		}, "proxy")
+	}
+
+	return nil
+}
+
+// AvailablePhases lists the phases that can be selected, in migration order.
+func (m *PhaseManager) AvailablePhases() []Phase {
+	var phases []Phase
+	for _, phase := range []Phase{PhaseSAPOnly, PhaseShadow, PhaseDualWrite, PhaseEventDriven} {
+		if _, exists := m.strategies[phase]; exists {
+			phases = append(phases, phase)
+		}
+	}
+	return phases
+}
+
+func (m *PhaseManager) Status() map[string]interface{} {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return map[string]interface{}{
+		"current_phase":    m.current.Phase(),
+		"available_phases": m.AvailablePhases(),
+		"last_change":      m.lastChange.Format(time.RFC3339),
+	}
+}
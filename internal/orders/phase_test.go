@@ -0,0 +1,57 @@
+package orders
+
+import (
+	"testing"
+)
+
+type recordingHub struct {
+	messages []string
+}
+
+func (h *recordingHub) Broadcast(messageType string, data interface{}, source string) {
+	h.messages = append(h.messages, messageType)
+}
+
+func TestPhaseManagerWithoutOrderService(t *testing.T) {
+	logger := newTestLogger()
+	manager := NewPhaseManager(nil, nil, NewCanaryRouter(0, logger), &RuleEngine{}, NewShadowStore(10), &SagaCoordinator{}, logger)
+
+	if manager.Current().Phase() != PhaseSAPOnly {
+		t.Fatalf("Expected default phase %s, got %s", PhaseSAPOnly, manager.Current().Phase())
+	}
+
+	for _, phase := range []Phase{PhaseShadow, PhaseDualWrite, PhaseEventDriven, Phase("unknown")} {
+		if err := manager.SetPhase(phase, "test"); err == nil {
+			t.Errorf("Expected error switching to %s without an order service", phase)
+		}
+	}
+
+	if phases := manager.AvailablePhases(); len(phases) != 1 || phases[0] != PhaseSAPOnly {
+		t.Errorf("Expected only sap_only to be available, got %v", phases)
+	}
+}
+
+func TestPhaseManagerSwitchBroadcasts(t *testing.T) {
+	logger := newTestLogger()
+	manager := NewPhaseManager(nil, &OrderServiceClient{}, NewCanaryRouter(100, logger), &RuleEngine{}, NewShadowStore(10), &SagaCoordinator{}, logger)
+	hub := &recordingHub{}
+	manager.SetWebSocketHub(hub)
+
+	for _, phase := range []Phase{PhaseShadow, PhaseDualWrite, PhaseEventDriven, PhaseSAPOnly} {
+		if err := manager.SetPhase(phase, "test"); err != nil {
+			t.Fatalf("Unexpected error switching to %s: %v", phase, err)
+		}
+		if manager.Current().Phase() != phase {
+			t.Errorf("Expected current phase %s, got %s", phase, manager.Current().Phase())
+		}
+	}
+
+	if len(hub.messages) != 4 {
+		t.Fatalf("Expected 4 broadcasts, got %d", len(hub.messages))
+	}
+	for _, messageType := range hub.messages {
+		if messageType != "phase_changed" {
+			t.Errorf("Expected phase_changed broadcast, got %s", messageType)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package orders
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Backend identifies the system that handles an order write.
+type Backend string
+
+const (
+	BackendSAP          Backend = "sap"
+	BackendOrderService Backend = "order_service"
+)
+
+// CanaryRouter splits new orders between SAP and the Order Service.
+// The weight is the percentage of orders sent to the Order Service.
+type CanaryRouter struct {
+	mutex      sync.RWMutex
+	weight     int
+	routed     map[Backend]int64
+	lastChange time.Time
+	logger     *logrus.Logger
+}
+
+func NewCanaryRouter(weight int, logger *logrus.Logger) *CanaryRouter {
+	if weight < 0 || weight > 100 {
+		logger.WithField("weight", weight).Warn("Invalid canary weight, using 0")
+		weight = 0
+	}
+
+	return &CanaryRouter{
+		weight:     weight,
+		routed:     make(map[Backend]int64),
+		lastChange: time.Now(),
+		logger:     logger,
+	}
+}
+
+// Route picks the backend for the next order based on the current weight.
+func (r *CanaryRouter) Route() Backend {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	backend := BackendSAP
+	if r.weight >= 100 || (r.weight > 0 && rand.Intn(100) < r.weight) {
+		backend = BackendOrderService
+	}
+	r.routed[backend]++
+
+	return backend
+}
+
+func (r *CanaryRouter) Weight() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.weight
+}
+
+// SetWeight changes the share of traffic sent to the Order Service.
+func (r *CanaryRouter) SetWeight(weight int) error {
+	if weight < 0 || weight > 100 {
+		return fmt.Errorf("canary weight must be between 0 and 100, got %d", weight)
+	}
+
+	r.mutex.Lock()
+	previous := r.weight
+	r.weight = weight
+	r.lastChange = time.Now()
+	r.mutex.Unlock()
+
+	r.logger.WithFields(logrus.Fields{
+		"previous_weight": previous,
+		"weight":          weight,
+	}).Info("Canary weight updated")
+
+	return nil
+}
+
+func (r *CanaryRouter) Metrics() map[string]interface{} {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return map[string]interface{}{
+		"order_service_weight":    r.weight,
+		"sap_weight":              100 - r.weight,
+		"routed_to_order_service": r.routed[BackendOrderService],
+		"routed_to_sap":           r.routed[BackendSAP],
+		"last_change":             r.lastChange.Format(time.RFC3339),
+	}
+}
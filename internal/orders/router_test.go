@@ -0,0 +1,73 @@
+package orders
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	return logger
+}
+
+func TestCanaryRouterExtremes(t *testing.T) {
+	router := NewCanaryRouter(0, newTestLogger())
+	for i := 0; i < 100; i++ {
+		if backend := router.Route(); backend != BackendSAP {
+			t.Fatalf("Expected all orders on SAP with weight 0, got %s", backend)
+		}
+	}
+
+	if err := router.SetWeight(100); err != nil {
+		t.Fatalf("Unexpected error setting weight: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if backend := router.Route(); backend != BackendOrderService {
+			t.Fatalf("Expected all orders on Order Service with weight 100, got %s", backend)
+		}
+	}
+
+	metrics := router.Metrics()
+	if metrics["routed_to_sap"].(int64) != 100 || metrics["routed_to_order_service"].(int64) != 100 {
+		t.Errorf("Unexpected routing counters: %v", metrics)
+	}
+}
+
+func TestCanaryRouterDistribution(t *testing.T) {
+	router := NewCanaryRouter(10, newTestLogger())
+
+	const total = 10000
+	toOrderService := 0
+	for i := 0; i < total; i++ {
+		if router.Route() == BackendOrderService {
+			toOrderService++
+		}
+	}
+
+	// 10% +/- 2% leaves plenty of room for randomness over 10k samples
+	if toOrderService < 800 || toOrderService > 1200 {
+		t.Errorf("Expected roughly 10%% of orders on Order Service, got %d/%d", toOrderService, total)
+	}
+}
+
+func TestCanaryRouterInvalidWeight(t *testing.T) {
+	router := NewCanaryRouter(150, newTestLogger())
+	if router.Weight() != 0 {
+		t.Errorf("Expected invalid initial weight to fall back to 0, got %d", router.Weight())
+	}
+
+	for _, weight := range []int{-1, 101} {
+		if err := router.SetWeight(weight); err == nil {
+			t.Errorf("Expected error for weight %d", weight)
+		}
+	}
+
+	if err := router.SetWeight(25); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if router.Weight() != 25 {
+		t.Errorf("Expected weight 25, got %d", router.Weight())
+	}
+}
@@ -0,0 +1,192 @@
+package orders
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jogardn/strangler-demo/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+// RoutingRule sends orders matching all of its predicates to Target.
+// Unset predicates match every order.
+type RoutingRule struct {
+	Name            string        `json:"name"`
+	Target          Backend       `json:"target"`
+	CustomerIDs     []string      `json:"customer_ids,omitempty"`
+	MinTotalAmount  *models.Money `json:"min_total_amount,omitempty"`
+	MaxTotalAmount  *models.Money `json:"max_total_amount,omitempty"`
+	ProductIDPrefix string        `json:"product_id_prefix,omitempty"`
+}
+
+type routingRulesFile struct {
+	Rules []RoutingRule `json:"rules"`
+}
+
+func (r *RoutingRule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule name is required")
+	}
+	if r.Target != BackendSAP && r.Target != BackendOrderService {
+		return fmt.Errorf("rule %q has invalid target %q", r.Name, r.Target)
+	}
+	if len(r.CustomerIDs) == 0 && r.MinTotalAmount == nil && r.MaxTotalAmount == nil && r.ProductIDPrefix == "" {
+		return fmt.Errorf("rule %q has no predicates", r.Name)
+	}
+	if r.MinTotalAmount != nil && r.MaxTotalAmount != nil && *r.MinTotalAmount > *r.MaxTotalAmount {
+		return fmt.Errorf("rule %q has min_total_amount above max_total_amount", r.Name)
+	}
+	return nil
+}
+
+// Matches reports whether the order satisfies every predicate of the rule.
+func (r *RoutingRule) Matches(order *models.Order) bool {
+	if len(r.CustomerIDs) > 0 {
+		found := false
+		for _, customerID := range r.CustomerIDs {
+			if customerID == order.CustomerID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if r.MinTotalAmount != nil && order.TotalAmount < *r.MinTotalAmount {
+		return false
+	}
+	if r.MaxTotalAmount != nil && order.TotalAmount > *r.MaxTotalAmount {
+		return false
+	}
+
+	if r.ProductIDPrefix != "" {
+		found := false
+		for _, item := range order.Items {
+			if strings.HasPrefix(item.ProductID, r.ProductIDPrefix) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RuleEngine evaluates routing rules loaded from a JSON file. Rules are
+// checked in file order and the first match wins.
+type RuleEngine struct {
+	mutex      sync.RWMutex
+	path       string
+	rules      []RoutingRule
+	matches    map[string]int64
+	lastLoaded time.Time
+	logger     *logrus.Logger
+}
+
+// NewRuleEngine loads the rules at path. An empty path gives an engine
+// without rules.
+func NewRuleEngine(path string, logger *logrus.Logger) (*RuleEngine, error) {
+	engine := &RuleEngine{
+		path:    path,
+		rules:   []RoutingRule{},
+		matches: make(map[string]int64),
+		logger:  logger,
+	}
+
+	if path == "" {
+		return engine, nil
+	}
+
+	if err := engine.Reload(); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+// Reload re-reads the rules file. The previous rules stay active if the file
+// cannot be read or contains an invalid rule.
+func (e *RuleEngine) Reload() error {
+	if e.path == "" {
+		return fmt.Errorf("no routing rules file configured")
+	}
+
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		return fmt.Errorf("failed to read routing rules: %w", err)
+	}
+
+	var file routingRulesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse routing rules: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for i := range file.Rules {
+		if err := file.Rules[i].validate(); err != nil {
+			return err
+		}
+		if names[file.Rules[i].Name] {
+			return fmt.Errorf("duplicate rule name %q", file.Rules[i].Name)
+		}
+		names[file.Rules[i].Name] = true
+	}
+
+	e.mutex.Lock()
+	e.rules = file.Rules
+	e.matches = make(map[string]int64)
+	e.lastLoaded = time.Now()
+	e.mutex.Unlock()
+
+	e.logger.WithFields(logrus.Fields{
+		"path":  e.path,
+		"rules": len(file.Rules),
+	}).Info("Routing rules loaded")
+
+	return nil
+}
+
+// Match returns the first rule matching the order, or nil.
+func (e *RuleEngine) Match(order *models.Order) *RoutingRule {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for i := range e.rules {
+		if e.rules[i].Matches(order) {
+			e.matches[e.rules[i].Name]++
+			rule := e.rules[i]
+			return &rule
+		}
+	}
+	return nil
+}
+
+func (e *RuleEngine) Status() map[string]interface{} {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	lastLoaded := "never"
+	if !e.lastLoaded.IsZero() {
+		lastLoaded = e.lastLoaded.Format(time.RFC3339)
+	}
+
+	matches := make(map[string]int64, len(e.matches))
+	for name, count := range e.matches {
+		matches[name] = count
+	}
+
+	return map[string]interface{}{
+		"path":        e.path,
+		"rules":       e.rules,
+		"matches":     matches,
+		"last_loaded": lastLoaded,
+	}
+}
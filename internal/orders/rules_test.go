@@ -0,0 +1,110 @@
+package orders
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jogardn/strangler-demo/pkg/models"
+)
+
+func writeRulesFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write rules file: %v", err)
+	}
+	return path
+}
+
+func TestRuleEngineMatching(t *testing.T) {
+	path := writeRulesFile(t, `{"rules": [
+		{"name": "large-orders", "target": "sap", "min_total_amount": 10000},
+		{"name": "pilot", "target": "order_service", "customer_ids": ["CUST-1", "CUST-2"]},
+		{"name": "configurable", "target": "sap", "product_id_prefix": "CFG-", "max_total_amount": 500}
+	]}`)
+
+	engine, err := NewRuleEngine(path, newTestLogger())
+	if err != nil {
+		t.Fatalf("Failed to load rules: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		order    models.Order
+		wantRule string
+	}{
+		{"large order from pilot customer", models.Order{CustomerID: "CUST-1", TotalAmount: 2500000}, "large-orders"},
+		{"small order from pilot customer", models.Order{CustomerID: "CUST-2", TotalAmount: 10000}, "pilot"},
+		{"configurable product", models.Order{CustomerID: "CUST-9", TotalAmount: 10000, Items: []models.OrderItem{{ProductID: "STD-1"}, {ProductID: "CFG-7"}}}, "configurable"},
+		{"configurable product above max", models.Order{CustomerID: "CUST-9", TotalAmount: 90000, Items: []models.OrderItem{{ProductID: "CFG-7"}}}, ""},
+		{"no rule", models.Order{CustomerID: "CUST-9", TotalAmount: 10000}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := engine.Match(&tt.order)
+			if tt.wantRule == "" {
+				if rule != nil {
+					t.Errorf("Expected no match, got %s", rule.Name)
+				}
+				return
+			}
+			if rule == nil || rule.Name != tt.wantRule {
+				t.Errorf("Expected rule %s, got %v", tt.wantRule, rule)
+			}
+		})
+	}
+
+	matches := engine.Status()["matches"].(map[string]int64)
+	if matches["large-orders"] != 1 || matches["pilot"] != 1 || matches["configurable"] != 1 {
+		t.Errorf("Unexpected match counters: %v", matches)
+	}
+}
+
+func TestRuleEngineReload(t *testing.T) {
+	path := writeRulesFile(t, `{"rules": [{"name": "pilot", "target": "order_service", "customer_ids": ["CUST-1"]}]}`)
+
+	engine, err := NewRuleEngine(path, newTestLogger())
+	if err != nil {
+		t.Fatalf("Failed to load rules: %v", err)
+	}
+
+	order := &models.Order{CustomerID: "CUST-1"}
+	if rule := engine.Match(order); rule == nil || rule.Target != BackendOrderService {
+		t.Fatalf("Expected pilot rule to route to order service, got %v", rule)
+	}
+
+	// An invalid file keeps the previous rules active
+	os.WriteFile(path, []byte(`{"rules": [{"name": "broken", "target": "mainframe", "customer_ids": ["CUST-1"]}]}`), 0644)
+	if err := engine.Reload(); err == nil {
+		t.Fatal("Expected reload of invalid rules to fail")
+	}
+	if rule := engine.Match(order); rule == nil || rule.Name != "pilot" {
+		t.Fatalf("Expected pilot rule to remain active, got %v", rule)
+	}
+
+	os.WriteFile(path, []byte(`{"rules": [{"name": "rollback", "target": "sap", "customer_ids": ["CUST-1"]}]}`), 0644)
+	if err := engine.Reload(); err != nil {
+		t.Fatalf("Unexpected reload error: %v", err)
+	}
+	if rule := engine.Match(order); rule == nil || rule.Target != BackendSAP {
+		t.Fatalf("Expected rollback rule to route to SAP, got %v", rule)
+	}
+}
+
+func TestRuleValidation(t *testing.T) {
+	invalid := []string{
+		`{"rules": [{"target": "sap", "customer_ids": ["CUST-1"]}]}`,
+		`{"rules": [{"name": "no-predicates", "target": "sap"}]}`,
+		`{"rules": [{"name": "bad-range", "target": "sap", "min_total_amount": 10, "max_total_amount": 5}]}`,
+		`{"rules": [{"name": "dup", "target": "sap", "customer_ids": ["A"]}, {"name": "dup", "target": "sap", "customer_ids": ["B"]}]}`,
+		`not json`,
+	}
+
+	for _, content := range invalid {
+		if _, err := NewRuleEngine(writeRulesFile(t, content), newTestLogger()); err == nil {
+			t.Errorf("Expected error loading %s", content)
+		}
+	}
+}
@@ -0,0 +1,535 @@
+package orders
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jogardn/strangler-demo/internal/sap"
+	"github.com/jogardn/strangler-demo/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+// SagaStatus is the state of a dual-write saga.
+type SagaStatus string
+
+const (
+	SagaStarted     SagaStatus = "started"
+	SagaCompleted   SagaStatus = "completed"
+	SagaRetrying    SagaStatus = "retrying"
+	SagaCompensated SagaStatus = "compensated"
+	SagaFailed      SagaStatus = "failed"
+)
+
+// SagaStep records one call made to a participant.
+type SagaStep struct {
+	Participant Backend   `json:"participant"`
+	Action      string    `json:"action"`
+	Succeeded   bool      `json:"succeeded"`
+	Error       string    `json:"error,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// Saga tracks a dual write of one order across SAP and the Order Service.
+type Saga struct {
+	ID    string       `json:"id"`
+	Order models.Order `json:"order"`
+	// IdempotencyKey and Actor are not part of the order's JSON, so they
+	// are journaled here for the copy made after a restart
+	IdempotencyKey string     `json:"idempotency_key,omitempty"`
+	Actor          string     `json:"actor,omitempty"`
+	Status         SagaStatus `json:"status"`
+	Steps          []SagaStep `json:"steps"`
+	Attempts       int        `json:"attempts"`
+	NextRetry      time.Time  `json:"next_retry,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (s *Saga) terminal() bool {
+	return s.Status == SagaCompleted || s.Status == SagaCompensated || s.Status == SagaFailed
+}
+
+// expired reports whether the saga finished cleanly more than retention
+// ago. Failed sagas need manual follow-up, so they never expire.
+func (s *Saga) expired(now time.Time, retention time.Duration) bool {
+	if s.Status != SagaCompleted && s.Status != SagaCompensated {
+		return false
+	}
+	return !s.UpdatedAt.After(now.Add(-retention))
+}
+
+// SagaJournal is an append-only file of saga snapshots, one JSON document
+// per line. The last snapshot of a saga is its current state.
+type SagaJournal struct {
+	mutex sync.Mutex
+	path  string
+	file  *os.File
+	// retention is how long completed and compensated sagas are kept
+	retention time.Duration
+}
+
+// OpenSagaJournal loads the journal at path and compacts it to the latest
+// snapshot of each saga. Sagas that completed or were compensated more than
+// retention ago are dropped.
+func OpenSagaJournal(path string, retention time.Duration) (*SagaJournal, map[string]*Saga, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	sagas, err := readSagaJournal(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	snapshots := make([]*Saga, 0, len(sagas))
+	for id, saga := range sagas {
+		if saga.expired(now, retention) {
+			delete(sagas, id)
+			continue
+		}
+		snapshots = append(snapshots, saga)
+	}
+
+	// Rewrite the journal so it does not grow without bound across restarts
+	journal := &SagaJournal{path: path, retention: retention}
+	if err := journal.compact(snapshots); err != nil {
+		return nil, nil, err
+	}
+	return journal, sagas, nil
+}
+
+// compact replaces the journal with one snapshot per saga and reopens it
+// for appending.
+func (j *SagaJournal) compact(sagas []*Saga) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	tmpPath := j.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to compact journal: %w", err)
+	}
+	encoder := json.NewEncoder(tmp)
+	for _, saga := range sagas {
+		if err := encoder.Encode(saga); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to compact journal: %w", err)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compact journal: %w", err)
+	}
+	tmp.Close()
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		return fmt.Errorf("failed to compact journal: %w", err)
+	}
+
+	file, err := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	if j.file != nil {
+		j.file.Close()
+	}
+	j.file = file
+	return nil
+}
+
+func readSagaJournal(path string) (map[string]*Saga, error) {
+	sagas := make(map[string]*Saga)
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return sagas, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var saga Saga
+		if err := json.Unmarshal(scanner.Bytes(), &saga); err != nil {
+			// A torn final line from a crash mid-write is skipped
+			continue
+		}
+		saga.Order.IdempotencyKey = saga.IdempotencyKey
+		saga.Order.Actor = saga.Actor
+		sagas[saga.ID] = &saga
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	return sagas, nil
+}
+
+// Append writes a snapshot and syncs it to disk before returning.
+func (j *SagaJournal) Append(saga *Saga) error {
+	data, err := json.Marshal(saga)
+	if err != nil {
+		return err
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+func (j *SagaJournal) Close() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.file.Close()
+}
+
+// SagaCoordinator runs dual writes as sagas. SAP is written first because it
+// is the system of record in the dual-write phase. A failed Order Service
+// write is retried in the background; once retries are exhausted the SAP
+// order is voided to compensate.
+type SagaCoordinator struct {
+	mutex              sync.RWMutex
+	sapClient          *sap.Client
+	orderServiceClient *OrderServiceClient
+	journal            *SagaJournal
+	sagas              map[string]*Saga
+	interrupted        []*Saga
+	maxRetries         int
+	retryDelay         time.Duration
+	logger             *logrus.Logger
+}
+
+func NewSagaCoordinator(sapClient *sap.Client, orderServiceClient *OrderServiceClient, journal *SagaJournal, sagas map[string]*Saga, maxRetries int, retryDelay time.Duration, logger *logrus.Logger) *SagaCoordinator {
+	if sagas == nil {
+		sagas = make(map[string]*Saga)
+	}
+
+	// Sagas still started when loaded were cut off by a restart mid-write
+	var interrupted []*Saga
+	for _, saga := range sagas {
+		if saga.Status == SagaStarted {
+			interrupted = append(interrupted, saga)
+		}
+	}
+
+	return &SagaCoordinator{
+		interrupted:        interrupted,
+		sapClient:          sapClient,
+		orderServiceClient: orderServiceClient,
+		journal:            journal,
+		sagas:              sagas,
+		maxRetries:         maxRetries,
+		retryDelay:         retryDelay,
+		logger:             logger,
+	}
+}
+
+// Execute writes the order to SAP and then to the Order Service. An Order
+// Service failure leaves the saga in the retrying state, and the SAP
+// response is returned marked as pending, since the order is voided if the
+// retries run out.
+func (c *SagaCoordinator) Execute(order *models.Order) (*models.OrderResponse, error) {
+	now := time.Now()
+	saga := &Saga{
+		ID:             order.ID,
+		Order:          *order,
+		IdempotencyKey: order.IdempotencyKey,
+		Actor:          order.Actor,
+		Status:         SagaStarted,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	c.save(saga)
+
+	sapResp, err := c.sapClient.CreateOrder(order)
+	if err == nil && !sapResp.Success {
+		err = fmt.Errorf("SAP rejected order: %s", sapResp.Message)
+	}
+	c.recordStep(saga, BackendSAP, "create", err)
+	if err != nil {
+		c.finish(saga, SagaFailed)
+		if sapResp != nil {
+			return sapResp, nil
+		}
+		return nil, err
+	}
+
+	if err := c.createInOrderService(saga); err != nil {
+		c.scheduleRetry(saga)
+		pending := *sapResp
+		pending.SyncState = models.SyncStatePending
+		pending.Message = fmt.Sprintf("Order accepted by SAP; the Order Service write is pending (saga %s)", saga.ID)
+		return &pending, nil
+	}
+
+	c.finish(saga, SagaCompleted)
+	return sapResp, nil
+}
+
+// createInOrderService copies the order to the Order Service. The historical
+// endpoint is used so the copy does not publish a Kafka event back into SAP.
+func (c *SagaCoordinator) createInOrderService(saga *Saga) error {
+	_, err := c.orderServiceClient.CreateOrderHistorical(&saga.Order, models.AuditSourceDualWrite)
+	c.recordStep(saga, BackendOrderService, "create", err)
+	return err
+}
+
+// Run retries pending sagas until the context is cancelled. Sagas left in
+// the started state by a crash are resumed first. Finished sagas are pruned
+// once they are older than the journal's retention.
+func (c *SagaCoordinator) Run(ctx context.Context, interval time.Duration) {
+	c.resumeInterrupted()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.resumeInterrupted()
+			c.retryDue()
+			c.prune(time.Now())
+		}
+	}
+}
+
+// resumeInterrupted continues the sagas cut off by a restart. A saga whose
+// order SAP never received has failed; when SAP cannot be asked, the saga
+// is checked again on the next tick.
+func (c *SagaCoordinator) resumeInterrupted() {
+	var unverified []*Saga
+	for _, saga := range c.interrupted {
+		// Only continue if SAP actually received the order before the crash
+		if _, err := c.sapClient.GetOrder(saga.ID); err != nil {
+			c.recordStep(saga, BackendSAP, "verify", err)
+			if errors.Is(err, sap.ErrOrderNotFound) {
+				c.finish(saga, SagaFailed)
+			} else {
+				unverified = append(unverified, saga)
+			}
+			continue
+		}
+		c.recordStep(saga, BackendSAP, "verify", nil)
+		c.scheduleRetry(saga)
+	}
+	c.interrupted = unverified
+}
+
+func (c *SagaCoordinator) retryDue() {
+	now := time.Now()
+	for _, saga := range c.sagasWithStatus(SagaRetrying) {
+		c.mutex.RLock()
+		due := !saga.NextRetry.After(now)
+		c.mutex.RUnlock()
+		if !due {
+			continue
+		}
+
+		// A previous attempt may have reached the Order Service even though
+		// the call failed, so check before creating again
+		if _, err := c.orderServiceClient.GetOrder(saga.ID); err == nil {
+			c.recordStep(saga, BackendOrderService, "verify", nil)
+			c.finish(saga, SagaCompleted)
+			continue
+		}
+
+		if err := c.createInOrderService(saga); err == nil {
+			c.finish(saga, SagaCompleted)
+			continue
+		}
+
+		if saga.Attempts >= c.maxRetries {
+			c.compensate(saga)
+			continue
+		}
+		c.scheduleRetry(saga)
+	}
+}
+
+// compensate voids the SAP order so both systems agree the order does not
+// exist.
+func (c *SagaCoordinator) compensate(saga *Saga) {
+	c.logger.WithFields(logrus.Fields{
+		"saga_id":  saga.ID,
+		"attempts": saga.Attempts,
+	}).Warn("Order service retries exhausted - voiding SAP order")
+
+	err := c.sapClient.VoidOrder(saga.ID)
+	c.recordStep(saga, BackendSAP, "void", err)
+	if err != nil {
+		c.finish(saga, SagaFailed)
+		return
+	}
+	c.finish(saga, SagaCompensated)
+}
+
+func (c *SagaCoordinator) scheduleRetry(saga *Saga) {
+	c.mutex.Lock()
+	saga.Attempts++
+	// Linear backoff keeps retries spread out without a large upper bound
+	saga.NextRetry = time.Now().Add(time.Duration(saga.Attempts) * c.retryDelay)
+	saga.Status = SagaRetrying
+	saga.UpdatedAt = time.Now()
+	attempts, nextRetry := saga.Attempts, saga.NextRetry
+	c.mutex.Unlock()
+	c.save(saga)
+
+	c.logger.WithFields(logrus.Fields{
+		"saga_id":    saga.ID,
+		"attempts":   attempts,
+		"next_retry": nextRetry.Format(time.RFC3339),
+	}).Warn("Dual write incomplete - retry scheduled")
+}
+
+func (c *SagaCoordinator) recordStep(saga *Saga, participant Backend, action string, err error) {
+	step := SagaStep{
+		Participant: participant,
+		Action:      action,
+		Succeeded:   err == nil,
+		Timestamp:   time.Now(),
+	}
+	if err != nil {
+		step.Error = err.Error()
+	}
+
+	c.mutex.Lock()
+	saga.Steps = append(saga.Steps, step)
+	saga.UpdatedAt = step.Timestamp
+	c.mutex.Unlock()
+	c.save(saga)
+}
+
+func (c *SagaCoordinator) finish(saga *Saga, status SagaStatus) {
+	c.mutex.Lock()
+	saga.Status = status
+	saga.NextRetry = time.Time{}
+	saga.UpdatedAt = time.Now()
+	c.mutex.Unlock()
+	c.save(saga)
+
+	c.logger.WithFields(logrus.Fields{
+		"saga_id": saga.ID,
+		"status":  status,
+		"steps":   len(saga.Steps),
+	}).Info("Dual write saga finished")
+}
+
+// save stores the saga and appends its snapshot to the journal. The append
+// happens under the lock, so snapshots reach the journal in the order they
+// were taken and none lands in a file that prune is replacing.
+func (c *SagaCoordinator) save(saga *Saga) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.sagas[saga.ID] = saga
+	if err := c.journal.Append(saga); err != nil {
+		c.logger.WithError(err).WithField("saga_id", saga.ID).Error("Failed to write saga journal")
+	}
+}
+
+// prune drops the sagas that completed or were compensated more than the
+// retention ago, from memory and from the journal, so neither grows with
+// every dual write.
+func (c *SagaCoordinator) prune(now time.Time) {
+	// save appends under the same lock, so no snapshot is appended to the
+	// old file while it is replaced, and none taken before the compaction
+	// can bring a pruned saga back after it
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	snapshots := make([]*Saga, 0, len(c.sagas))
+	pruned := 0
+	for id, saga := range c.sagas {
+		if saga.expired(now, c.journal.retention) {
+			delete(c.sagas, id)
+			pruned++
+			continue
+		}
+		snapshot := *saga
+		snapshot.Steps = append([]SagaStep(nil), saga.Steps...)
+		snapshots = append(snapshots, &snapshot)
+	}
+	if pruned == 0 {
+		return
+	}
+
+	if err := c.journal.compact(snapshots); err != nil {
+		c.logger.WithError(err).Error("Failed to compact saga journal")
+		return
+	}
+	c.logger.WithField("pruned", pruned).Info("Pruned finished sagas")
+}
+
+func (c *SagaCoordinator) sagasWithStatus(status SagaStatus) []*Saga {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	var sagas []*Saga
+	for _, saga := range c.sagas {
+		if saga.Status == status {
+			sagas = append(sagas, saga)
+		}
+	}
+	return sagas
+}
+
+// Get returns a copy of the saga with the given ID.
+func (c *SagaCoordinator) Get(id string) (*Saga, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	saga, exists := c.sagas[id]
+	if !exists {
+		return nil, false
+	}
+	snapshot := *saga
+	snapshot.Steps = append([]SagaStep(nil), saga.Steps...)
+	return &snapshot, true
+}
+
+// Report returns counts per status and the sagas, newest first. Completed
+// sagas are left out unless includeCompleted is set.
+func (c *SagaCoordinator) Report(includeCompleted bool) map[string]interface{} {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	counts := make(map[SagaStatus]int)
+	sagas := []Saga{}
+	for _, saga := range c.sagas {
+		counts[saga.Status]++
+		if saga.Status == SagaCompleted && !includeCompleted {
+			continue
+		}
+		snapshot := *saga
+		snapshot.Steps = append([]SagaStep(nil), saga.Steps...)
+		sagas = append(sagas, snapshot)
+	}
+
+	sort.Slice(sagas, func(i, j int) bool {
+		return sagas[i].CreatedAt.After(sagas[j].CreatedAt)
+	})
+
+	return map[string]interface{}{
+		"counts":      counts,
+		"sagas":       sagas,
+		"max_retries": c.maxRetries,
+		"journal":     c.journal.path,
+	}
+}
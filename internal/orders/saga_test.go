@@ -0,0 +1,330 @@
+package orders
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jogardn/strangler-demo/internal/circuitbreaker"
+	"github.com/jogardn/strangler-demo/internal/sap"
+	"github.com/jogardn/strangler-demo/pkg/models"
+)
+
+// fakeBackend answers order requests and records the methods it received.
+// Lookups answer lookup, or 404 Not Found when it is unset.
+type fakeBackend struct {
+	mutex    sync.Mutex
+	status   int
+	lookup   int
+	requests []string
+	sources  []string
+}
+
+func (b *fakeBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mutex.Lock()
+	b.requests = append(b.requests, r.Method)
+	if source := r.Header.Get(ChangeSourceHeader); source != "" {
+		b.sources = append(b.sources, source)
+	}
+	status, lookup := b.status, b.lookup
+	b.mutex.Unlock()
+
+	switch r.Method {
+	case "GET":
+		if lookup == 0 {
+			lookup = http.StatusNotFound
+		}
+		w.WriteHeader(lookup)
+		return
+	case "DELETE":
+		status = http.StatusOK
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.OrderResponse{
+		Success: status < 300,
+		Order:   &models.Order{ID: "order-1"},
+	})
+}
+
+func (b *fakeBackend) received(method string) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	count := 0
+	for _, m := range b.requests {
+		if m == method {
+			count++
+		}
+	}
+	return count
+}
+
+func newTestCoordinator(t *testing.T, sapBackend, osBackend *fakeBackend, maxRetries int) (*SagaCoordinator, string) {
+	t.Helper()
+
+	logger := newTestLogger()
+	sapServer := httptest.NewServer(sapBackend)
+	t.Cleanup(sapServer.Close)
+	osServer := httptest.NewServer(osBackend)
+	t.Cleanup(osServer.Close)
+
+	cbManager := circuitbreaker.NewManager(logger)
+	cbConfig := circuitbreaker.Config{MaxFailures: 10, Timeout: time.Minute, MaxRequests: 1}
+	cbManager.GetOrCreate("sap", cbConfig)
+	cbManager.GetOrCreate("order-service", cbConfig)
+	path := filepath.Join(t.TempDir(), "sagas.jsonl")
+	journal, sagas, err := OpenSagaJournal(path, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	t.Cleanup(func() { journal.Close() })
+
+	coordinator := NewSagaCoordinator(
+		sap.NewClient(sapServer.URL, logger, cbManager),
+		NewOrderServiceClient(osServer.URL, logger, cbManager),
+		journal,
+		sagas,
+		maxRetries,
+		0,
+		logger,
+	)
+	return coordinator, path
+}
+
+func TestSagaCompletesWhenBothWritesSucceed(t *testing.T) {
+	sapBackend := &fakeBackend{status: http.StatusCreated}
+	osBackend := &fakeBackend{status: http.StatusCreated}
+	coordinator, _ := newTestCoordinator(t, sapBackend, osBackend, 3)
+
+	resp, err := coordinator.Execute(&models.Order{ID: "order-1"})
+	if err != nil || !resp.Success {
+		t.Fatalf("Expected successful response, got %v %v", resp, err)
+	}
+
+	saga, exists := coordinator.Get("order-1")
+	if !exists {
+		t.Fatal("Expected saga to be recorded")
+	}
+	if saga.Status != SagaCompleted {
+		t.Errorf("Expected status %s, got %s", SagaCompleted, saga.Status)
+	}
+	if len(saga.Steps) != 2 {
+		t.Errorf("Expected 2 steps, got %d", len(saga.Steps))
+	}
+	if len(osBackend.sources) != 1 || osBackend.sources[0] != models.AuditSourceDualWrite {
+		t.Errorf("Expected the Order Service write to be audited as %s, got %v", models.AuditSourceDualWrite, osBackend.sources)
+	}
+}
+
+func TestSagaFailsWithoutOrderServiceWriteWhenSAPRejects(t *testing.T) {
+	sapBackend := &fakeBackend{status: http.StatusBadRequest}
+	osBackend := &fakeBackend{status: http.StatusCreated}
+	coordinator, _ := newTestCoordinator(t, sapBackend, osBackend, 3)
+
+	coordinator.Execute(&models.Order{ID: "order-1"})
+
+	saga, _ := coordinator.Get("order-1")
+	if saga.Status != SagaFailed {
+		t.Errorf("Expected status %s, got %s", SagaFailed, saga.Status)
+	}
+	if osBackend.received("POST") != 0 {
+		t.Error("Expected no order service write after SAP rejected the order")
+	}
+}
+
+func TestSagaRetriesThenCompensates(t *testing.T) {
+	sapBackend := &fakeBackend{status: http.StatusCreated}
+	osBackend := &fakeBackend{status: http.StatusInternalServerError}
+	coordinator, _ := newTestCoordinator(t, sapBackend, osBackend, 2)
+
+	order := &models.Order{ID: "order-1"}
+	resp, err := coordinator.Execute(order)
+	if err != nil || !resp.Success {
+		t.Fatalf("Expected the SAP response to be returned, got %v %v", resp, err)
+	}
+	// The order may still be voided, so it is not reported as created
+	if resp.SyncState != models.SyncStatePending {
+		t.Errorf("Expected sync state %q, got %q", models.SyncStatePending, resp.SyncState)
+	}
+	if result := batchResult(order, resp, nil); result.Status != http.StatusAccepted {
+		t.Errorf("Expected a pending order to be answered with %d, got %d", http.StatusAccepted, result.Status)
+	}
+
+	saga, _ := coordinator.Get("order-1")
+	if saga.Status != SagaRetrying {
+		t.Fatalf("Expected status %s, got %s", SagaRetrying, saga.Status)
+	}
+
+	// Both retries fail, then the SAP write is compensated
+	coordinator.retryDue()
+	coordinator.retryDue()
+	saga, _ = coordinator.Get("order-1")
+	if saga.Status != SagaCompensated {
+		t.Fatalf("Expected status %s after retries, got %s", SagaCompensated, saga.Status)
+	}
+	if sapBackend.received("DELETE") != 1 {
+		t.Errorf("Expected SAP order to be voided once, got %d", sapBackend.received("DELETE"))
+	}
+}
+
+func TestSagaResumeWaitsForSAPBeforeFailing(t *testing.T) {
+	sapBackend := &fakeBackend{status: http.StatusCreated, lookup: http.StatusServiceUnavailable}
+	osBackend := &fakeBackend{status: http.StatusCreated}
+	coordinator, _ := newTestCoordinator(t, sapBackend, osBackend, 3)
+
+	saga := &Saga{ID: "order-1", Order: models.Order{ID: "order-1"}, Status: SagaStarted}
+	coordinator.save(saga)
+	coordinator.interrupted = []*Saga{saga}
+
+	// SAP cannot say whether it has the order, so the saga waits
+	coordinator.resumeInterrupted()
+	saga, _ = coordinator.Get("order-1")
+	if saga.Status != SagaStarted || len(coordinator.interrupted) != 1 {
+		t.Fatalf("Expected the saga to stay %s while SAP is unavailable, got %s", SagaStarted, saga.Status)
+	}
+
+	sapBackend.mutex.Lock()
+	sapBackend.lookup = http.StatusNotFound
+	sapBackend.mutex.Unlock()
+
+	coordinator.resumeInterrupted()
+	saga, _ = coordinator.Get("order-1")
+	if saga.Status != SagaFailed || len(coordinator.interrupted) != 0 {
+		t.Errorf("Expected the saga to fail once SAP reports the order missing, got %s", saga.Status)
+	}
+	if osBackend.received("POST") != 0 {
+		t.Error("Expected no order service write for an order SAP never received")
+	}
+}
+
+func TestSagaRetrySucceeds(t *testing.T) {
+	sapBackend := &fakeBackend{status: http.StatusCreated}
+	osBackend := &fakeBackend{status: http.StatusInternalServerError}
+	coordinator, _ := newTestCoordinator(t, sapBackend, osBackend, 3)
+
+	coordinator.Execute(&models.Order{ID: "order-1"})
+
+	osBackend.mutex.Lock()
+	osBackend.status = http.StatusCreated
+	osBackend.mutex.Unlock()
+
+	coordinator.retryDue()
+	saga, _ := coordinator.Get("order-1")
+	if saga.Status != SagaCompleted {
+		t.Errorf("Expected status %s, got %s", SagaCompleted, saga.Status)
+	}
+	if sapBackend.received("DELETE") != 0 {
+		t.Error("Expected no compensation after a successful retry")
+	}
+}
+
+func TestSagaJournalReload(t *testing.T) {
+	sapBackend := &fakeBackend{status: http.StatusCreated}
+	osBackend := &fakeBackend{status: http.StatusInternalServerError}
+	coordinator, path := newTestCoordinator(t, sapBackend, osBackend, 3)
+
+	coordinator.Execute(&models.Order{ID: "order-1", CustomerID: "customer-1", IdempotencyKey: "key-1", Actor: "alice"})
+
+	// Simulate a crash that tore the last line
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	file.WriteString(`{"id":"order-2","sta`)
+	file.Close()
+
+	_, sagas, err := OpenSagaJournal(path, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to reopen journal: %v", err)
+	}
+	if len(sagas) != 1 {
+		t.Fatalf("Expected 1 saga after reload, got %d", len(sagas))
+	}
+
+	saga := sagas["order-1"]
+	if saga.Status != SagaRetrying {
+		t.Errorf("Expected status %s, got %s", SagaRetrying, saga.Status)
+	}
+	if saga.Order.CustomerID != "customer-1" {
+		t.Errorf("Expected order to be journaled, got customer %q", saga.Order.CustomerID)
+	}
+	if saga.Order.IdempotencyKey != "key-1" || saga.Order.Actor != "alice" {
+		t.Errorf("Expected the idempotency key and actor to be journaled, got %q and %q", saga.Order.IdempotencyKey, saga.Order.Actor)
+	}
+	if saga.NextRetry.IsZero() || saga.NextRetry.After(time.Now().Add(time.Minute)) {
+		t.Errorf("Unexpected next retry %v", saga.NextRetry)
+	}
+}
+
+func TestSagaPruneDropsFinishedSagas(t *testing.T) {
+	sapBackend := &fakeBackend{status: http.StatusCreated}
+	osBackend := &fakeBackend{status: http.StatusCreated}
+	coordinator, path := newTestCoordinator(t, sapBackend, osBackend, 3)
+
+	coordinator.Execute(&models.Order{ID: "order-1"})
+	osBackend.mutex.Lock()
+	osBackend.status = http.StatusInternalServerError
+	osBackend.mutex.Unlock()
+	coordinator.Execute(&models.Order{ID: "order-2"})
+
+	// Within the retention the completed saga is kept for the report
+	coordinator.prune(time.Now())
+	if _, ok := coordinator.Get("order-1"); !ok {
+		t.Fatal("Expected a recently completed saga to be kept")
+	}
+
+	coordinator.prune(time.Now().Add(2 * time.Hour))
+	if _, ok := coordinator.Get("order-1"); ok {
+		t.Error("Expected the completed saga to be pruned")
+	}
+	if _, ok := coordinator.Get("order-2"); !ok {
+		t.Error("Expected the retrying saga to be kept")
+	}
+
+	_, sagas, err := OpenSagaJournal(path, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to reopen journal: %v", err)
+	}
+	if len(sagas) != 1 || sagas["order-2"] == nil {
+		t.Errorf("Expected the journal to hold only the retrying saga, got %v", sagas)
+	}
+	data, _ := os.ReadFile(path)
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Errorf("Expected the journal to be compacted to 1 line, got %d", lines)
+	}
+}
+
+func TestOpenSagaJournalDropsExpiredSagas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sagas.jsonl")
+	old := time.Now().Add(-48 * time.Hour)
+	var lines []byte
+	for _, saga := range []Saga{
+		{ID: "completed", Status: SagaCompleted, UpdatedAt: old},
+		{ID: "compensated", Status: SagaCompensated, UpdatedAt: old},
+		{ID: "failed", Status: SagaFailed, UpdatedAt: old},
+		{ID: "recent", Status: SagaCompleted, UpdatedAt: time.Now()},
+	} {
+		data, _ := json.Marshal(saga)
+		lines = append(append(lines, data...), '\n')
+	}
+	if err := os.WriteFile(path, lines, 0644); err != nil {
+		t.Fatalf("Failed to write journal: %v", err)
+	}
+
+	journal, sagas, err := OpenSagaJournal(path, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	journal.Close()
+	if len(sagas) != 2 || sagas["failed"] == nil || sagas["recent"] == nil {
+		t.Errorf("Expected the failed and recent sagas to be kept, got %v", sagas)
+	}
+}
@@ -0,0 +1,222 @@
+package orders
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jogardn/strangler-demo/internal/sap"
+	"github.com/jogardn/strangler-demo/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+// maxShadowInFlight bounds the number of concurrent mirrored writes so a slow
+// Order Service cannot pile up goroutines behind live traffic.
+const maxShadowInFlight = 20
+
+// ShadowResult is the outcome of one mirrored order write.
+type ShadowResult struct {
+	OrderID               string                 `json:"order_id"`
+	Timestamp             time.Time              `json:"timestamp"`
+	Match                 bool                   `json:"match"`
+	Differences           string                 `json:"differences,omitempty"`
+	Analysis              map[string]interface{} `json:"analysis,omitempty"`
+	Error                 string                 `json:"error,omitempty"`
+	SAPLatencyMs          int64                  `json:"sap_latency_ms"`
+	OrderServiceLatencyMs int64                  `json:"order_service_latency_ms"`
+	LatencyDeltaMs        int64                  `json:"latency_delta_ms"`
+}
+
+// ShadowStore keeps shadow comparison counters and the most recent
+// mismatches and errors in a fixed-size ring.
+type ShadowStore struct {
+	mutex      sync.RWMutex
+	capacity   int
+	recent     []ShadowResult
+	next       int
+	total      int64
+	matched    int64
+	mismatched int64
+	errors     int64
+	dropped    int64
+	latencySum int64
+	latencyMax int64
+}
+
+func NewShadowStore(capacity int) *ShadowStore {
+	if capacity <= 0 {
+		capacity = 100
+	}
+	return &ShadowStore{
+		capacity: capacity,
+		recent:   make([]ShadowResult, 0, capacity),
+	}
+}
+
+func (s *ShadowStore) Record(result ShadowResult) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.total++
+	switch {
+	case result.Error != "":
+		s.errors++
+	case result.Match:
+		s.matched++
+	default:
+		s.mismatched++
+	}
+
+	if result.Error == "" {
+		s.latencySum += result.LatencyDeltaMs
+		if abs64(result.LatencyDeltaMs) > abs64(s.latencyMax) {
+			s.latencyMax = result.LatencyDeltaMs
+		}
+	}
+
+	// Matches only count towards the totals
+	if result.Match && result.Error == "" {
+		return
+	}
+
+	if len(s.recent) < s.capacity {
+		s.recent = append(s.recent, result)
+	} else {
+		s.recent[s.next] = result
+	}
+	s.next = (s.next + 1) % s.capacity
+}
+
+func (s *ShadowStore) recordDropped() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.dropped++
+}
+
+// Report returns the counters and the retained results, newest first.
+func (s *ShadowStore) Report() map[string]interface{} {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	results := make([]ShadowResult, 0, len(s.recent))
+	for i := 1; i <= len(s.recent); i++ {
+		results = append(results, s.recent[(s.next-i+s.capacity)%s.capacity])
+	}
+
+	compared := s.matched + s.mismatched
+	var avgDelta float64
+	var matchRate float64
+	if compared > 0 {
+		avgDelta = float64(s.latencySum) / float64(compared)
+		matchRate = float64(s.matched) / float64(compared) * 100
+	}
+
+	return map[string]interface{}{
+		"total":                s.total,
+		"matched":              s.matched,
+		"mismatched":           s.mismatched,
+		"errors":               s.errors,
+		"dropped":              s.dropped,
+		"match_rate":           matchRate,
+		"avg_latency_delta_ms": avgDelta,
+		"max_latency_delta_ms": s.latencyMax,
+		"capacity":             s.capacity,
+		"results":              results,
+	}
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+type shadowStrategy struct {
+	sapClient          *sap.Client
+	orderServiceClient *OrderServiceClient
+	store              *ShadowStore
+	inFlight           chan struct{}
+	logger             *logrus.Logger
+}
+
+func newShadowStrategy(sapClient *sap.Client, orderServiceClient *OrderServiceClient, store *ShadowStore, logger *logrus.Logger) *shadowStrategy {
+	return &shadowStrategy{
+		sapClient:          sapClient,
+		orderServiceClient: orderServiceClient,
+		store:              store,
+		inFlight:           make(chan struct{}, maxShadowInFlight),
+		logger:             logger,
+	}
+}
+
+func (s *shadowStrategy) Phase() Phase {
+	return PhaseShadow
+}
+
+// CreateOrder answers from SAP and mirrors the write to the Order Service in
+// the background. The mirror is a dry run of the Order Service's live create
+// path, so the Order Service stores nothing and publishes no event while SAP
+// is still the system of record.
+func (s *shadowStrategy) CreateOrder(order *models.Order) (*models.OrderResponse, error) {
+	start := time.Now()
+	sapResp, err := s.sapClient.CreateOrder(order)
+	sapLatency := time.Since(start)
+	if err != nil || !sapResp.Success {
+		return sapResp, err
+	}
+
+	select {
+	case s.inFlight <- struct{}{}:
+	default:
+		s.store.recordDropped()
+		s.logger.WithField("order_id", order.ID).Warn("Shadow write skipped - too many in flight")
+		return sapResp, nil
+	}
+
+	mirrored := *order
+	go func() {
+		defer func() { <-s.inFlight }()
+		s.mirror(&mirrored, sapResp, sapLatency)
+	}()
+
+	return sapResp, nil
+}
+
+func (s *shadowStrategy) mirror(order *models.Order, sapResp *models.OrderResponse, sapLatency time.Duration) {
+	start := time.Now()
+	osResp, err := s.orderServiceClient.CreateOrderDryRun(order)
+	osLatency := time.Since(start)
+
+	result := ShadowResult{
+		OrderID:               order.ID,
+		Timestamp:             time.Now(),
+		SAPLatencyMs:          sapLatency.Milliseconds(),
+		OrderServiceLatencyMs: osLatency.Milliseconds(),
+		LatencyDeltaMs:        osLatency.Milliseconds() - sapLatency.Milliseconds(),
+	}
+
+	switch {
+	case err != nil:
+		result.Error = err.Error()
+	case !osResp.Success:
+		result.Error = osResp.Message
+	case osResp.Order == nil || sapResp.Order == nil:
+		result.Error = "response did not include the order"
+	default:
+		result.Analysis = compareOrders(osResp.Order, sapResp.Order)
+		result.Match = result.Analysis["perfect_match"].(bool)
+		if differences, ok := result.Analysis["differences"].(string); ok {
+			result.Differences = differences
+		}
+	}
+
+	s.store.Record(result)
+
+	s.logger.WithFields(logrus.Fields{
+		"order_id":         order.ID,
+		"match":            result.Match,
+		"differences":      result.Differences,
+		"error":            result.Error,
+		"latency_delta_ms": result.LatencyDeltaMs,
+	}).Info("Shadow write compared")
+}
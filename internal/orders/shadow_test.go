@@ -0,0 +1,86 @@
+package orders
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jogardn/strangler-demo/internal/circuitbreaker"
+	"github.com/jogardn/strangler-demo/pkg/models"
+)
+
+func TestShadowStoreCounters(t *testing.T) {
+	store := NewShadowStore(10)
+
+	store.Record(ShadowResult{OrderID: "match", Match: true, LatencyDeltaMs: -40})
+	store.Record(ShadowResult{OrderID: "mismatch", Match: false, Differences: "total_amount", LatencyDeltaMs: 20})
+	store.Record(ShadowResult{OrderID: "error", Error: "order service unavailable", LatencyDeltaMs: 500})
+	store.recordDropped()
+
+	report := store.Report()
+	if report["total"].(int64) != 3 || report["matched"].(int64) != 1 ||
+		report["mismatched"].(int64) != 1 || report["errors"].(int64) != 1 || report["dropped"].(int64) != 1 {
+		t.Fatalf("Unexpected counters: %v", report)
+	}
+
+	if report["avg_latency_delta_ms"].(float64) != -10 {
+		t.Errorf("Expected average latency delta of -10ms, got %v", report["avg_latency_delta_ms"])
+	}
+	if report["max_latency_delta_ms"].(int64) != -40 {
+		t.Errorf("Expected max latency delta of -40ms, got %v", report["max_latency_delta_ms"])
+	}
+
+	// Only the mismatch and the error are retained
+	results := report["results"].([]ShadowResult)
+	if len(results) != 2 || results[0].OrderID != "error" || results[1].OrderID != "mismatch" {
+		t.Errorf("Unexpected retained results: %+v", results)
+	}
+}
+
+func TestShadowStoreIsBounded(t *testing.T) {
+	store := NewShadowStore(3)
+	for i := 0; i < 10; i++ {
+		store.Record(ShadowResult{OrderID: fmt.Sprintf("order-%d", i)})
+	}
+
+	results := store.Report()["results"].([]ShadowResult)
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 retained results, got %d", len(results))
+	}
+
+	for i, want := range []string{"order-9", "order-8", "order-7"} {
+		if results[i].OrderID != want {
+			t.Errorf("Expected result %d to be %s, got %s", i, want, results[i].OrderID)
+		}
+	}
+}
+
+func TestShadowMirrorIsADryRun(t *testing.T) {
+	var requests []string
+	osServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		var order models.Order
+		json.NewDecoder(r.Body).Decode(&order)
+		json.NewEncoder(w).Encode(models.OrderResponse{Success: true, Order: &order})
+	}))
+	defer osServer.Close()
+
+	logger := newTestLogger()
+	cbManager := circuitbreaker.NewManager(logger)
+	cbManager.GetOrCreate("order-service", circuitbreaker.Config{MaxFailures: 1, Timeout: time.Minute, MaxRequests: 1})
+	store := NewShadowStore(10)
+	strategy := newShadowStrategy(nil, NewOrderServiceClient(osServer.URL, logger, cbManager), store, logger)
+
+	order := &models.Order{ID: "order-1", CustomerID: "customer-1", TotalAmount: 1000}
+	strategy.mirror(order, &models.OrderResponse{Success: true, Order: order}, time.Millisecond)
+
+	if len(requests) != 1 || requests[0] != "POST /orders?dry_run=true" {
+		t.Errorf("Expected a single dry run of the live create, got %v", requests)
+	}
+	if report := store.Report(); report["matched"].(int64) != 1 {
+		t.Errorf("Expected the dry run to match SAP's answer, got %v", report)
+	}
+}
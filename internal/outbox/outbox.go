@@ -0,0 +1,397 @@
+// Package outbox implements a transactional outbox. Events are written to the
+// order_outbox table in the same transaction as the change they describe,
+// and a relay publishes them to Kafka afterwards. An event is therefore
+// published if and only if its transaction committed, at least once.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Enqueue adds an event to the outbox inside the caller's transaction. The
+// key is the Kafka message key; events with the same key are published in
+// the order they were enqueued.
+func Enqueue(tx *sql.Tx, topic, key string, event interface{}) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", topic, err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO order_outbox (topic, message_key, payload) VALUES ($1, $2, $3)`,
+		topic, key, string(payload))
+	return err
+}
+
+// Entry is one event for EnqueueAll.
+type Entry struct {
+	Topic string
+	Key   string
+	Event interface{}
+}
+
+// maxEntriesPerInsert keeps each insert of EnqueueAll well below Postgres'
+// limit of 65535 parameters.
+const maxEntriesPerInsert = 1000
+
+// EnqueueAll adds events to the outbox inside the caller's transaction with
+// multi-row inserts. They are enqueued in order, as if by one Enqueue each.
+func EnqueueAll(tx *sql.Tx, entries []Entry) error {
+	for start := 0; start < len(entries); start += maxEntriesPerInsert {
+		end := start + maxEntriesPerInsert
+		if end > len(entries) {
+			end = len(entries)
+		}
+
+		var values strings.Builder
+		args := make([]interface{}, 0, 3*(end-start))
+		for i, entry := range entries[start:end] {
+			payload, err := json.Marshal(entry.Event)
+			if err != nil {
+				return fmt.Errorf("failed to marshal %s event: %w", entry.Topic, err)
+			}
+			if i > 0 {
+				values.WriteString(", ")
+			}
+			fmt.Fprintf(&values, "($%d, $%d, $%d)", 3*i+1, 3*i+2, 3*i+3)
+			args = append(args, entry.Topic, entry.Key, string(payload))
+		}
+
+		// Rows of one insert take their ids in VALUES order
+		if _, err := tx.Exec(`INSERT INTO order_outbox (topic, message_key, payload) VALUES `+values.String(), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Publisher sends an already encoded event.
+type Publisher interface {
+	PublishPayload(topic, key string, payload []byte) error
+}
+
+type Config struct {
+	// PollInterval is how often the relay looks for pending events when it
+	// is not notified
+	PollInterval time.Duration
+	BatchSize    int
+	// MaxBackoff caps the delay between attempts of a failing event
+	MaxBackoff time.Duration
+	// Retention is how long sent events are kept for inspection
+	Retention time.Duration
+}
+
+// Backoff returns the delay before the next attempt of an event that has
+// failed attempts times: one second, doubled per failure, capped at max.
+func Backoff(attempts int, max time.Duration) time.Duration {
+	delay := time.Second
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
+
+// Relay publishes pending outbox events. Several relays can share a table:
+// rows are claimed with SKIP LOCKED.
+type Relay struct {
+	db        *sql.DB
+	publisher Publisher
+	config    Config
+	logger    *logrus.Logger
+	wake      chan struct{}
+
+	mutex       sync.Mutex
+	published   int64
+	failed      int64
+	lastRunAt   time.Time
+	lastCleanup time.Time
+}
+
+func NewRelay(db *sql.DB, publisher Publisher, config Config, logger *logrus.Logger) *Relay {
+	if config.PollInterval <= 0 {
+		config.PollInterval = time.Second
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 5 * time.Minute
+	}
+	if config.Retention <= 0 {
+		config.Retention = 24 * time.Hour
+	}
+
+	return &Relay{
+		db:        db,
+		publisher: publisher,
+		config:    config,
+		logger:    logger,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Notify wakes the relay after a transaction with outbox events committed,
+// so events do not wait for the next poll.
+func (r *Relay) Notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run relays pending events until the context is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going while full batches come back, there is more waiting
+		for {
+			published, err := r.RelayPending()
+			if err != nil {
+				r.logger.WithError(err).Error("Outbox relay pass failed")
+				break
+			}
+			if published < r.config.BatchSize {
+				break
+			}
+		}
+		r.cleanup()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+type pendingRow struct {
+	id       int64
+	topic    string
+	key      string
+	payload  []byte
+	attempts int
+}
+
+// RelayPending makes one pass over the due events and returns how many it
+// published. Only the oldest pending event of each key is due, so a failing
+// event holds back the later events of the same order instead of letting
+// them overtake it.
+func (r *Relay) RelayPending() (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT o.id, o.topic, o.message_key, o.payload, o.attempts
+		FROM order_outbox o
+		WHERE o.sent_at IS NULL AND o.next_attempt_at <= NOW()
+			AND NOT EXISTS (
+				SELECT 1 FROM order_outbox p
+				WHERE p.message_key = o.message_key AND p.sent_at IS NULL AND p.id < o.id
+			)
+		ORDER BY o.id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, r.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var pending []pendingRow
+	for rows.Next() {
+		var row pendingRow
+		if err := rows.Scan(&row.id, &row.topic, &row.key, &row.payload, &row.attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending = append(pending, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	published, failed := 0, 0
+	for _, row := range pending {
+		if err := r.publisher.PublishPayload(row.topic, row.key, row.payload); err != nil {
+			failed++
+			delay := Backoff(row.attempts+1, r.config.MaxBackoff)
+			r.logger.WithFields(logrus.Fields{
+				"outbox_id": row.id,
+				"topic":     row.topic,
+				"order_id":  row.key,
+				"attempts":  row.attempts + 1,
+				"retry_in":  delay.String(),
+				"error":     err.Error(),
+			}).Warn("Failed to publish outbox event")
+
+			_, err := tx.Exec(`
+				UPDATE order_outbox
+				SET attempts = attempts + 1, last_error = $1, next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+				WHERE id = $3
+			`, err.Error(), delay.Milliseconds(), row.id)
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		published++
+		if _, err := tx.Exec(`UPDATE order_outbox SET sent_at = NOW() WHERE id = $1`, row.id); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	r.mutex.Lock()
+	r.published += int64(published)
+	r.failed += int64(failed)
+	r.lastRunAt = time.Now()
+	r.mutex.Unlock()
+
+	return published, nil
+}
+
+// cleanup drops sent events past the retention at most once a minute.
+func (r *Relay) cleanup() {
+	r.mutex.Lock()
+	if time.Since(r.lastCleanup) < time.Minute {
+		r.mutex.Unlock()
+		return
+	}
+	r.lastCleanup = time.Now()
+	r.mutex.Unlock()
+
+	result, err := r.db.Exec(`DELETE FROM order_outbox WHERE sent_at < NOW() - $1 * INTERVAL '1 second'`,
+		int64(r.config.Retention.Seconds()))
+	if err != nil {
+		r.logger.WithError(err).Warn("Failed to clean up sent outbox events")
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted > 0 {
+		r.logger.WithField("deleted", deleted).Info("Cleaned up sent outbox events")
+	}
+}
+
+// FailingEvent is a pending event that has failed at least once.
+type FailingEvent struct {
+	ID            int64     `json:"id"`
+	Topic         string    `json:"topic"`
+	OrderID       string    `json:"order_id"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error"`
+	CreatedAt     time.Time `json:"created_at"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+}
+
+// Backlog describes the events still waiting to be published.
+type Backlog struct {
+	Pending          int            `json:"pending"`
+	Failing          int            `json:"failing"`
+	PendingByTopic   map[string]int `json:"pending_by_topic"`
+	OldestPendingAt  *time.Time     `json:"oldest_pending_at,omitempty"`
+	OldestPendingAge float64        `json:"oldest_pending_age_seconds"`
+	FailingEvents    []FailingEvent `json:"failing_events"`
+	Relay            RelayStats     `json:"relay"`
+}
+
+// RelayStats counts the relay's work since the service started.
+type RelayStats struct {
+	Published int64      `json:"published"`
+	Failed    int64      `json:"failed_attempts"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+}
+
+// maxFailingEvents limits the failing events listed in a backlog report.
+const maxFailingEvents = 20
+
+// Backlog reports the pending events, including the failing ones with the
+// most attempts.
+func (r *Relay) Backlog() (*Backlog, error) {
+	backlog := &Backlog{
+		PendingByTopic: make(map[string]int),
+		FailingEvents:  []FailingEvent{},
+	}
+
+	rows, err := r.db.Query(`
+		SELECT topic, COUNT(*), COUNT(*) FILTER (WHERE attempts > 0), MIN(created_at)
+		FROM order_outbox WHERE sent_at IS NULL GROUP BY topic
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var topic string
+		var pending, failing int
+		var oldest time.Time
+		if err := rows.Scan(&topic, &pending, &failing, &oldest); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		backlog.PendingByTopic[topic] = pending
+		backlog.Pending += pending
+		backlog.Failing += failing
+		if backlog.OldestPendingAt == nil || oldest.Before(*backlog.OldestPendingAt) {
+			oldest := oldest
+			backlog.OldestPendingAt = &oldest
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if backlog.OldestPendingAt != nil {
+		backlog.OldestPendingAge = time.Since(*backlog.OldestPendingAt).Seconds()
+	}
+
+	rows, err = r.db.Query(`
+		SELECT id, topic, message_key, attempts, COALESCE(last_error, ''), created_at, next_attempt_at
+		FROM order_outbox WHERE sent_at IS NULL AND attempts > 0
+		ORDER BY attempts DESC, id
+		LIMIT $1
+	`, maxFailingEvents)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var event FailingEvent
+		err := rows.Scan(&event.ID, &event.Topic, &event.OrderID, &event.Attempts,
+			&event.LastError, &event.CreatedAt, &event.NextAttemptAt)
+		if err != nil {
+			return nil, err
+		}
+		backlog.FailingEvents = append(backlog.FailingEvents, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	backlog.Relay = RelayStats{Published: r.published, Failed: r.failed}
+	if !r.lastRunAt.IsZero() {
+		lastRunAt := r.lastRunAt
+		backlog.Relay.LastRunAt = &lastRunAt
+	}
+	r.mutex.Unlock()
+
+	return backlog, nil
+}
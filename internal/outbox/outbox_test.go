@@ -0,0 +1,192 @@
+package outbox
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jogardn/strangler-demo/internal/migrations"
+	_ "github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{20, time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts, time.Minute); got != tt.expected {
+			t.Errorf("Backoff(%d) = %v, expected %v", tt.attempts, got, tt.expected)
+		}
+	}
+}
+
+// fakePublisher records published events and fails the keys in failing.
+type fakePublisher struct {
+	mutex     sync.Mutex
+	failing   map[string]bool
+	published []string
+}
+
+func (p *fakePublisher) PublishPayload(topic, key string, payload []byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.failing[key] {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, topic+"/"+key)
+	return nil
+}
+
+// testDB returns a connection to a fresh, migrated schema.
+// The relay needs Postgres, so the test is skipped unless
+// ORDER_SERVICE_TEST_DSN is set to a key=value DSN.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("ORDER_SERVICE_TEST_DSN")
+	if dsn == "" {
+		t.Skip("ORDER_SERVICE_TEST_DSN not set")
+	}
+
+	schema := fmt.Sprintf("outbox_test_%d", time.Now().UnixNano())
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+		admin.Close()
+	})
+
+	db, err := sql.Open("postgres", dsn+" search_path="+schema)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	migrator, err := migrations.New(db, logger)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	return db
+}
+
+func enqueue(t *testing.T, db *sql.DB, topic, key string) {
+	t.Helper()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Enqueue(tx, topic, key, map[string]string{"order_id": key}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRelayHoldsBackLaterEventsOfAFailingOrder(t *testing.T) {
+	db := testDB(t)
+	publisher := &fakePublisher{failing: map[string]bool{"order-1": true}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	relay := NewRelay(db, publisher, Config{MaxBackoff: time.Millisecond}, logger)
+
+	enqueue(t, db, "order.created", "order-1")
+	enqueue(t, db, "order.status_changed", "order-1")
+	enqueue(t, db, "order.created", "order-2")
+
+	published, err := relay.RelayPending()
+	if err != nil {
+		t.Fatalf("Relay pass failed: %v", err)
+	}
+	if published != 1 || len(publisher.published) != 1 || publisher.published[0] != "order.created/order-2" {
+		t.Fatalf("Expected only order-2 to be published, got %v", publisher.published)
+	}
+
+	backlog, err := relay.Backlog()
+	if err != nil {
+		t.Fatalf("Failed to read backlog: %v", err)
+	}
+	if backlog.Pending != 2 || backlog.Failing != 1 || len(backlog.FailingEvents) != 1 {
+		t.Errorf("Expected 2 pending events with 1 failing, got %+v", backlog)
+	}
+	if backlog.FailingEvents[0].LastError != "broker unavailable" {
+		t.Errorf("Expected the publish error to be recorded, got %q", backlog.FailingEvents[0].LastError)
+	}
+
+	// Once the broker recovers, order-1's events go out in order
+	publisher.mutex.Lock()
+	publisher.failing = nil
+	publisher.mutex.Unlock()
+	time.Sleep(10 * time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		if _, err := relay.RelayPending(); err != nil {
+			t.Fatalf("Relay pass failed: %v", err)
+		}
+	}
+	expected := []string{"order.created/order-2", "order.created/order-1", "order.status_changed/order-1"}
+	if fmt.Sprint(publisher.published) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, publisher.published)
+	}
+
+	backlog, _ = relay.Backlog()
+	if backlog.Pending != 0 {
+		t.Errorf("Expected an empty backlog, got %d pending", backlog.Pending)
+	}
+}
+
+func TestEnqueueAllKeepsOrder(t *testing.T) {
+	db := testDB(t)
+	publisher := &fakePublisher{}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	relay := NewRelay(db, publisher, Config{BatchSize: 10, MaxBackoff: time.Millisecond}, logger)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = EnqueueAll(tx, []Entry{
+		{Topic: "order.created", Key: "order-1", Event: map[string]string{"order_id": "order-1"}},
+		{Topic: "order.created", Key: "order-2", Event: map[string]string{"order_id": "order-2"}},
+		{Topic: "order.status_changed", Key: "order-1", Event: map[string]string{"order_id": "order-1"}},
+	})
+	if err != nil {
+		t.Fatalf("EnqueueAll failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := relay.RelayPending(); err != nil {
+		t.Fatalf("Relay pass failed: %v", err)
+	}
+	expected := []string{"order.created/order-1", "order.created/order-2", "order.status_changed/order-1"}
+	if fmt.Sprint(publisher.published) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, publisher.published)
+	}
+}
@@ -0,0 +1,194 @@
+// Package passthrough forwards requests the proxy does not handle itself to
+// the legacy SAP system, or to the Order Service for routes that have
+// already been migrated.
+package passthrough
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/jogardn/strangler-demo/internal/circuitbreaker"
+	"github.com/sirupsen/logrus"
+)
+
+// ServedByHeader names the backend that answered a request. The proxy's
+// access log reads it.
+const ServedByHeader = "X-Served-By"
+
+const (
+	BackendSAP          = "sap"
+	BackendOrderService = "order_service"
+)
+
+type backend struct {
+	name           string
+	proxy          *httputil.ReverseProxy
+	circuitBreaker *circuitbreaker.CircuitBreaker
+}
+
+// Proxy is an http.Handler that forwards every request to SAP unless its
+// path matches one of the Order Service prefixes.
+type Proxy struct {
+	sap                  *backend
+	orderService         *backend
+	orderServicePrefixes []string
+	logger               *logrus.Logger
+}
+
+// New builds a passthrough proxy. orderServiceURL may be empty, in which case
+// prefixes must be empty too. The circuit breakers are looked up in cbManager
+// under the same names the API clients use.
+func New(sapURL, orderServiceURL string, orderServicePrefixes []string, cbManager *circuitbreaker.Manager, logger *logrus.Logger) (*Proxy, error) {
+	sapBackend, err := newBackend(BackendSAP, sapURL, cbManager.Get("sap"), logger)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Proxy{
+		sap:    sapBackend,
+		logger: logger,
+	}
+
+	for _, prefix := range orderServicePrefixes {
+		prefix = strings.TrimRight(strings.TrimSpace(prefix), "/")
+		if prefix == "" {
+			continue
+		}
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("passthrough prefix %q must start with /", prefix)
+		}
+		p.orderServicePrefixes = append(p.orderServicePrefixes, prefix)
+	}
+
+	if len(p.orderServicePrefixes) > 0 {
+		if orderServiceURL == "" {
+			return nil, fmt.Errorf("order service passthrough routes need an order service URL")
+		}
+		p.orderService, err = newBackend(BackendOrderService, orderServiceURL, cbManager.Get("order-service"), logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+func newBackend(name, rawURL string, cb *circuitbreaker.CircuitBreaker, logger *logrus.Logger) (*backend, error) {
+	if cb == nil {
+		return nil, fmt.Errorf("no circuit breaker configured for %s", name)
+	}
+
+	target, err := url.Parse(rawURL)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		return nil, fmt.Errorf("invalid %s URL %q", name, rawURL)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		logger.WithFields(logrus.Fields{
+			"backend": name,
+			"path":    r.URL.Path,
+			"error":   err.Error(),
+		}).Error("Passthrough request failed")
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("Failed to reach %s", name))
+	}
+
+	return &backend{name: name, proxy: proxy, circuitBreaker: cb}, nil
+}
+
+// Routes returns the configured Order Service prefixes.
+func (p *Proxy) Routes() map[string]interface{} {
+	return map[string]interface{}{
+		"default_backend":        BackendSAP,
+		"order_service_prefixes": p.orderServicePrefixes,
+	}
+}
+
+func (p *Proxy) backendFor(path string) *backend {
+	for _, prefix := range p.orderServicePrefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return p.orderService
+		}
+	}
+	return p.sap
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := p.backendFor(r.URL.Path)
+	w.Header().Set(ServedByHeader, target.name)
+
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	err := target.circuitBreaker.Execute(func() error {
+		target.proxy.ServeHTTP(recorder, r)
+		if recorder.status >= http.StatusInternalServerError {
+			return fmt.Errorf("%s returned error status: %d", target.name, recorder.status)
+		}
+		return nil
+	})
+
+	if errors.Is(err, circuitbreaker.ErrCircuitBreakerOpen) {
+		p.logger.WithFields(logrus.Fields{
+			"backend": target.name,
+			"path":    r.URL.Path,
+		}).Warn("Passthrough rejected - circuit breaker open")
+		writeError(w, http.StatusServiceUnavailable, fmt.Sprintf("%s is unavailable (circuit breaker open)", target.name))
+	}
+}
+
+// ExceptOwnedRoutes forwards requests to next unless a route of router owns
+// their path. gorilla/mux hands a request whose path matches a route but
+// whose method does not to a catch-all route, so e.g. DELETE /orders/{id}
+// would reach SAP around the phase strategy and the saga. Those requests
+// are answered with 405 instead. Call it before registering the catch-all,
+// as the routes are read once.
+func ExceptOwnedRoutes(router *mux.Router, next http.Handler) http.Handler {
+	var owned []*mux.Route
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		owned = append(owned, route)
+		return nil
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, route := range owned {
+			var match mux.RouteMatch
+			if !route.Match(r, &match) && errors.Is(match.MatchErr, mux.ErrMethodMismatch) {
+				writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("%s is not allowed on %s", r.Method, r.URL.Path))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusRecorder captures the status code written by the reverse proxy so
+// 5xx answers count as circuit breaker failures.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// flush streamed responses.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+	})
+}
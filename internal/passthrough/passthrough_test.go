@@ -0,0 +1,148 @@
+package passthrough
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jogardn/strangler-demo/internal/circuitbreaker"
+	"github.com/sirupsen/logrus"
+)
+
+func newTestManager() (*circuitbreaker.Manager, *logrus.Logger) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	cbManager := circuitbreaker.NewManager(logger)
+	config := circuitbreaker.Config{MaxFailures: 2, Timeout: time.Minute, MaxRequests: 1}
+	cbManager.GetOrCreate("sap", config)
+	cbManager.GetOrCreate("order-service", config)
+	return cbManager, logger
+}
+
+func newBackendServer(name string, status int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend", name)
+		w.WriteHeader(status)
+	}))
+}
+
+func TestRoutesByPrefix(t *testing.T) {
+	sapServer := newBackendServer("sap", http.StatusOK)
+	defer sapServer.Close()
+	osServer := newBackendServer("order-service", http.StatusOK)
+	defer osServer.Close()
+
+	cbManager, logger := newTestManager()
+	proxy, err := New(sapServer.URL, osServer.URL, []string{"/inventory/"}, cbManager, logger)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		backend  string
+		servedBy string
+	}{
+		{"/materials/42", "sap", BackendSAP},
+		{"/inventory", "order-service", BackendOrderService},
+		{"/inventory/widgets", "order-service", BackendOrderService},
+		{"/inventory-report", "sap", BackendSAP},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+
+		if got := rec.Header().Get("X-Backend"); got != tt.backend {
+			t.Errorf("%s: expected backend %s, got %s", tt.path, tt.backend, got)
+		}
+		if got := rec.Header().Get(ServedByHeader); got != tt.servedBy {
+			t.Errorf("%s: expected %s header %s, got %s", tt.path, ServedByHeader, tt.servedBy, got)
+		}
+	}
+}
+
+func TestServerErrorsOpenCircuitBreaker(t *testing.T) {
+	sapServer := newBackendServer("sap", http.StatusInternalServerError)
+	defer sapServer.Close()
+
+	cbManager, logger := newTestManager()
+	proxy, err := New(sapServer.URL, "", nil, cbManager, logger)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest("GET", "/legacy", nil))
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("Expected upstream status 500, got %d", rec.Code)
+		}
+	}
+
+	if state := cbManager.Get("sap").State(); state != circuitbreaker.StateOpen {
+		t.Fatalf("Expected circuit breaker to be open, got %s", state.String())
+	}
+
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest("GET", "/legacy", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 while circuit breaker is open, got %d", rec.Code)
+	}
+}
+
+func TestOrderServicePrefixesNeedURL(t *testing.T) {
+	cbManager, logger := newTestManager()
+	if _, err := New("http://sap:8082", "", []string{"/inventory"}, cbManager, logger); err == nil {
+		t.Error("Expected an error for order service prefixes without a URL")
+	}
+	if _, err := New("http://sap:8082", "http://order-service:8081", []string{"inventory"}, cbManager, logger); err == nil {
+		t.Error("Expected an error for a prefix without a leading slash")
+	}
+}
+
+func TestOwnedRoutesAreNotForwarded(t *testing.T) {
+	var forwarded []string
+	sapServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = append(forwarded, r.Method+" "+r.URL.Path)
+	}))
+	defer sapServer.Close()
+
+	cbManager, logger := newTestManager()
+	proxy, err := New(sapServer.URL, "", nil, cbManager, logger)
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	router := mux.NewRouter()
+	handled := func(w http.ResponseWriter, r *http.Request) {}
+	router.HandleFunc("/orders/{id}", handled).Methods("GET", "PUT", "OPTIONS")
+	router.HandleFunc("/admin/phase", handled).Methods("GET", "POST", "OPTIONS")
+	unowned := ExceptOwnedRoutes(router, proxy)
+	router.PathPrefix("/").Handler(unowned)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{"DELETE", "/orders/order-1", http.StatusMethodNotAllowed},
+		{"PATCH", "/admin/phase", http.StatusMethodNotAllowed},
+		{"GET", "/orders/order-1", http.StatusOK},
+		{"DELETE", "/materials/42", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.status, rec.Code)
+		}
+	}
+
+	if len(forwarded) != 1 || forwarded[0] != "DELETE /materials/42" {
+		t.Errorf("Expected only the legacy route to reach SAP, got %v", forwarded)
+	}
+}
@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jogardn/strangler-demo/pkg/models"
+)
+
+// fakeRoundTrip is the latency the fake database adds to every query, about
+// a round trip to Postgres on the docker-compose network.
+const fakeRoundTrip = 100 * time.Microsecond
+
+// fakeOrdersDriver serves the read path queries from seededOrders in-memory
+// orders with three items each, so the benchmarks below run without
+// ORDER_SERVICE_TEST_DSN. It answers the aggregated orderColumns query, and
+// the orders and items queries of listOrdersWithItemQueries. It measures
+// round trips and decoding on the client; the cost of json_agg in Postgres
+// is left to the benchmarks on a real database.
+type fakeOrdersDriver struct {
+	orders []*models.Order
+	byID   map[string]*models.Order
+}
+
+var (
+	fakeDriverOnce sync.Once
+	fakeLimit      = regexp.MustCompile(`LIMIT (\d+)`)
+)
+
+func fakeOrdersDB(tb testing.TB) *Postgres {
+	tb.Helper()
+
+	fakeDriverOnce.Do(func() {
+		created := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+		fake := &fakeOrdersDriver{byID: make(map[string]*models.Order)}
+		// Newest first, as both read paths sort
+		for i := seededOrders - 1; i >= 0; i-- {
+			order := &models.Order{
+				ID:           fmt.Sprintf("order-%05d", i),
+				CustomerID:   fmt.Sprintf("customer-%d", i%50),
+				Currency:     models.DefaultCurrency,
+				DeliveryDate: created.AddDate(0, 0, 14),
+				Status:       models.StatusPending,
+				CreatedAt:    created.Add(time.Duration(i) * time.Minute),
+				Version:      1,
+			}
+			for j := 0; j < 3; j++ {
+				order.Items = append(order.Items, models.OrderItem{
+					ProductID:      fmt.Sprintf("P-%d", j),
+					Quantity:       j + 1,
+					UnitPrice:      999,
+					Specifications: map[string]string{"color": "red"},
+				})
+				order.TotalAmount += models.Money(999).Times(j + 1)
+			}
+			fake.orders = append(fake.orders, order)
+			fake.byID[order.ID] = order
+		}
+		sql.Register("fake-orders", fake)
+	})
+
+	db, err := sql.Open("fake-orders", "")
+	if err != nil {
+		tb.Fatalf("Failed to open fake database: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return NewPostgres(db)
+}
+
+func (d *fakeOrdersDriver) Open(string) (driver.Conn, error) {
+	return &fakeOrdersConn{driver: d}, nil
+}
+
+type fakeOrdersConn struct {
+	driver *fakeOrdersDriver
+}
+
+func (c *fakeOrdersConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeOrdersStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeOrdersConn) Close() error { return nil }
+
+func (c *fakeOrdersConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("fake database is read-only")
+}
+
+type fakeOrdersStmt struct {
+	conn  *fakeOrdersConn
+	query string
+}
+
+func (s *fakeOrdersStmt) Close() error  { return nil }
+func (s *fakeOrdersStmt) NumInput() int { return -1 }
+
+func (s *fakeOrdersStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("fake database is read-only")
+}
+
+func (s *fakeOrdersStmt) Query(args []driver.Value) (driver.Rows, error) {
+	// Spin rather than sleep: timer slack stretches short sleeps to a
+	// millisecond or more
+	for deadline := time.Now().Add(fakeRoundTrip); time.Now().Before(deadline); {
+	}
+	orders := s.conn.driver.orders
+
+	if strings.Contains(s.query, "FROM order_items WHERE order_id") {
+		id, _ := args[0].(string)
+		rows := &fakeOrdersRows{columns: []string{"product_id", "quantity", "unit_price", "specifications"}}
+		if order, ok := s.conn.driver.byID[id]; ok {
+			for _, item := range order.Items {
+				specJSON, _ := json.Marshal(item.Specifications)
+				rows.values = append(rows.values, []driver.Value{
+					item.ProductID, int64(item.Quantity), item.UnitPrice.String(), string(specJSON),
+				})
+			}
+		}
+		return rows, nil
+	}
+
+	limit := len(orders)
+	if match := fakeLimit.FindStringSubmatch(s.query); match != nil {
+		limit, _ = strconv.Atoi(match[1])
+	} else if len(args) > 0 {
+		if n, ok := args[0].(int64); ok {
+			limit = int(n)
+		}
+	}
+	if limit > len(orders) {
+		limit = len(orders)
+	}
+
+	if strings.Contains(s.query, "json_agg") {
+		rows := &fakeOrdersRows{columns: make([]string, 14)}
+		for _, order := range orders[:limit] {
+			itemsJSON, _ := json.Marshal(order.Items)
+			rows.values = append(rows.values, []driver.Value{
+				order.ID, order.CustomerID, order.TotalAmount.String(), order.Currency,
+				order.DeliveryDate, string(order.Status), order.CreatedAt, int64(order.Version),
+				nil, nil, nil, nil, nil,
+				itemsJSON,
+			})
+		}
+		return rows, nil
+	}
+
+	rows := &fakeOrdersRows{columns: make([]string, 6)}
+	for _, order := range orders[:limit] {
+		rows.values = append(rows.values, []driver.Value{
+			order.ID, order.CustomerID, order.TotalAmount.String(),
+			order.DeliveryDate, string(order.Status), order.CreatedAt,
+		})
+	}
+	return rows, nil
+}
+
+type fakeOrdersRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeOrdersRows) Columns() []string { return r.columns }
+func (r *fakeOrdersRows) Close() error      { return nil }
+
+func (r *fakeOrdersRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestFakeReadPathMatchesItemQueries(t *testing.T) {
+	p := fakeOrdersDB(t)
+
+	expected, err := listOrdersWithItemQueries(p, models.MaxPageSize)
+	if err != nil {
+		t.Fatalf("Baseline query failed: %v", err)
+	}
+
+	var streamed []*models.Order
+	if _, err := p.List(models.OrderQuery{Limit: models.MaxPageSize}, func(order *models.Order) error {
+		streamed = append(streamed, order)
+		return nil
+	}); err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(streamed) != models.MaxPageSize || len(streamed) != len(expected) {
+		t.Fatalf("Expected %d orders on both paths, got %d and %d", models.MaxPageSize, len(expected), len(streamed))
+	}
+	for i := range expected {
+		if expected[i].ID != streamed[i].ID || len(streamed[i].Items) != 3 {
+			t.Errorf("Order %d differs: %s vs %s with %d items", i, expected[i].ID, streamed[i].ID, len(streamed[i].Items))
+		}
+	}
+}
+
+func BenchmarkFakeReadPathItemQueries(b *testing.B) {
+	p := fakeOrdersDB(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		orders, err := listOrdersWithItemQueries(p, models.MaxPageSize)
+		if err != nil {
+			b.Fatal(err)
+		}
+		json.NewEncoder(io.Discard).Encode(orders)
+	}
+}
+
+func BenchmarkFakeReadPathAggregated(b *testing.B) {
+	p := fakeOrdersDB(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		encoder := json.NewEncoder(io.Discard)
+		_, err := p.List(models.OrderQuery{Limit: models.MaxPageSize}, func(order *models.Order) error {
+			return encoder.Encode(order)
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package repository
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jogardn/strangler-demo/pkg/models"
+)
+
+// Memory keeps orders in memory. It follows the same rules as Postgres and
+// records events instead of publishing them. It is meant for tests.
+type Memory struct {
+	mutex  sync.Mutex
+	orders map[string]*models.Order
+	// keys maps idempotency keys to order IDs
+	keys map[string]string
+	// hashes maps order IDs to the requestHash they were stored with
+	hashes map[string]string
+	events []Event
+	audit  []models.AuditEntry
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		orders: make(map[string]*models.Order),
+		keys:   make(map[string]string),
+		hashes: make(map[string]string),
+	}
+}
+
+// Events returns the events recorded so far, oldest first.
+func (m *Memory) Events() []Event {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]Event(nil), m.events...)
+}
+
+func (m *Memory) Ping() error {
+	return nil
+}
+
+// clone copies an order so callers never share state with the store.
+func clone(order *models.Order) *models.Order {
+	c := *order
+	c.Items = make([]models.OrderItem, len(order.Items))
+	for i, item := range order.Items {
+		c.Items[i] = item
+		if item.Specifications != nil {
+			c.Items[i].Specifications = make(map[string]string, len(item.Specifications))
+			for k, v := range item.Specifications {
+				c.Items[i].Specifications[k] = v
+			}
+		}
+	}
+	if order.Cancellation != nil {
+		cancellation := *order.Cancellation
+		c.Cancellation = &cancellation
+	}
+	return &c
+}
+
+// stored returns a copy of a stored order as Get returns it, without its
+// idempotency key or the amount SAP converts.
+func stored(order *models.Order) *models.Order {
+	c := clone(order)
+	c.IdempotencyKey = ""
+	c.CompanyCodeAmount = nil
+	return c
+}
+
+func (m *Memory) record(event *Event) {
+	if event != nil {
+		m.events = append(m.events, *event)
+	}
+}
+
+// recordChange adds an entry to the audit trail. before is nil for a new
+// order.
+func (m *Memory) recordChange(audit Audit, action string, before, after *models.Order) {
+	entry := models.AuditEntry{
+		ID:        int64(len(m.audit) + 1),
+		OrderID:   after.ID,
+		Action:    action,
+		Actor:     audit.Actor,
+		Source:    audit.Source,
+		CreatedAt: time.Now(),
+	}
+	if before != nil {
+		entry.Before, _ = json.Marshal(before)
+	}
+	entry.After, _ = json.Marshal(after)
+	m.audit = append(m.audit, entry)
+}
+
+func (m *Memory) Save(order *models.Order, audit Audit, event *Event) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.save(order, audit, event)
+}
+
+func (m *Memory) SaveBatch(orders []*models.Order, audit Audit, events []*Event) ([]error, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	results := make([]error, len(orders))
+	for i, order := range orders {
+		results[i] = m.save(order, audit, events[i])
+	}
+	return results, nil
+}
+
+func (m *Memory) save(order *models.Order, audit Audit, event *Event) error {
+	existing, ok := m.orders[order.ID]
+	if !ok && order.IdempotencyKey != "" {
+		existing, ok = m.orders[m.keys[order.IdempotencyKey]]
+	}
+	if ok {
+		return duplicateOf(order, clone(existing), m.hashes[existing.ID])
+	}
+
+	order.Version = 1
+	c := clone(order)
+	c.Currency = models.CurrencyOrDefault(c.Currency)
+	m.orders[order.ID] = c
+	m.hashes[order.ID] = requestHash(order)
+	if order.IdempotencyKey != "" {
+		m.keys[order.IdempotencyKey] = order.ID
+	}
+	m.record(event)
+	m.recordChange(audit, models.AuditActionCreated, nil, stored(order))
+	return nil
+}
+
+func (m *Memory) Get(orderID string) (*models.Order, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	order, ok := m.orders[orderID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return stored(order), nil
+}
+
+func (m *Memory) List(q models.OrderQuery, emit func(*models.Order) error) (string, error) {
+	m.mutex.Lock()
+	all := make([]*models.Order, 0, len(m.orders))
+	for _, order := range m.orders {
+		all = append(all, stored(order))
+	}
+	m.mutex.Unlock()
+
+	page, next, err := models.PageOrders(all, func(order *models.Order) *models.Order { return order }, q)
+	if err != nil {
+		return "", err
+	}
+	for _, order := range page {
+		if err := emit(order); err != nil {
+			return "", err
+		}
+	}
+	return next, nil
+}
+
+func (m *Memory) Update(orderID string, version int, audit Audit, update UpdateFunc) (*models.Order, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	current, ok := m.orders[orderID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if version != 0 && current.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	order := stored(current)
+	order.Version++
+	event, err := update(order)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		order.Version--
+		return order, nil
+	}
+
+	before := stored(current)
+	current.DeliveryDate = order.DeliveryDate
+	current.TotalAmount = order.TotalAmount
+	current.Items = clone(order).Items
+	current.Version = order.Version
+	m.record(event)
+	m.recordChange(audit, models.AuditActionUpdated, before, stored(current))
+	return order, nil
+}
+
+func (m *Memory) UpdateStatus(orderID, status string, audit Audit, event EventFunc) (string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	order, ok := m.orders[orderID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	if order.Cancellation != nil && order.Cancellation.State == models.CancellationRequested {
+		return "", ErrCancellationPending
+	}
+	current := order.Status
+	if err := models.ValidateTransition(current, status); err != nil {
+		return "", err
+	}
+
+	before := stored(order)
+	order.Status = status
+	order.Version++
+	e := event(current)
+	m.record(&e)
+	m.recordChange(audit, models.AuditActionStatusChanged, before, stored(order))
+	return current, nil
+}
+
+func (m *Memory) RequestCancellation(orderID string, req models.CancellationRequest, requestedAt time.Time, audit Audit, event Event) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	order, ok := m.orders[orderID]
+	if !ok {
+		return ErrNotFound
+	}
+	if order.Cancellation != nil && order.Cancellation.State == models.CancellationRequested {
+		return ErrCancellationPending
+	}
+	if err := models.ValidateTransition(order.Status, models.StatusCancelled); err != nil {
+		return err
+	}
+
+	before := stored(order)
+	order.Cancellation = &models.Cancellation{
+		ReasonCode:  req.ReasonCode,
+		Note:        req.Note,
+		State:       models.CancellationRequested,
+		RequestedAt: requestedAt,
+	}
+	order.Version++
+	m.record(&event)
+	m.recordChange(audit, models.AuditActionCancellationRequested, before, stored(order))
+	return nil
+}
+
+func (m *Memory) RejectCancellation(orderID, message string, audit Audit) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	order, ok := m.orders[orderID]
+	if ok && order.Cancellation != nil && order.Cancellation.State == models.CancellationRequested {
+		before := stored(order)
+		order.Cancellation.State = models.CancellationRejected
+		order.Cancellation.Message = message
+		order.Version++
+		m.recordChange(audit, models.AuditActionCancellationRejected, before, stored(order))
+	}
+	return nil
+}
+
+func (m *Memory) ConfirmCancellation(orderID, message string, audit Audit, event EventFunc) (string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	order, ok := m.orders[orderID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	status := order.Status
+	if status == models.StatusCancelled {
+		return "", nil
+	}
+	before := stored(order)
+	if order.Cancellation == nil {
+		order.Cancellation = &models.Cancellation{}
+	}
+
+	if transitionErr := models.ValidateTransition(status, models.StatusCancelled); transitionErr != nil {
+		order.Cancellation.State = models.CancellationRejected
+		order.Cancellation.Message = transitionErr.Error()
+		order.Version++
+		m.recordChange(audit, models.AuditActionCancellationRejected, before, stored(order))
+		return "", transitionErr
+	}
+
+	order.Status = models.StatusCancelled
+	order.Cancellation.State = models.CancellationConfirmed
+	order.Cancellation.Message = message
+	order.Version++
+	e := event(status)
+	m.record(&e)
+	m.recordChange(audit, models.AuditActionCancellationConfirmed, before, stored(order))
+	return status, nil
+}
+
+func (m *Memory) History(orderID string) ([]models.AuditEntry, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	history := []models.AuditEntry{}
+	for _, entry := range m.audit {
+		if entry.OrderID == orderID {
+			history = append(history, entry)
+		}
+	}
+	return history, nil
+}
+
+func (m *Memory) CustomerSummary(customerID string, window models.AnalyticsWindow) (*models.CustomerOrderSummary, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	summary := models.NewCustomerOrderSummary(customerID, window)
+	for _, order := range m.orders {
+		if order.CustomerID != customerID || !window.Contains(order.CreatedAt) {
+			continue
+		}
+		quantity := 0
+		for _, item := range order.Items {
+			quantity += item.Quantity
+		}
+		summary.AddOrders(order.Status, order.Currency, 1, order.TotalAmount, len(order.Items), quantity, order.CreatedAt, order.CreatedAt)
+	}
+	return summary, nil
+}
+
+func (m *Memory) Analytics(window models.AnalyticsWindow, topCustomers int) (*models.OrderAnalytics, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	analytics := models.NewOrderAnalytics(window)
+	// Days and customers are keyed by currency as well, as amounts in
+	// different currencies cannot be added
+	type key struct{ id, currency string }
+	days := make(map[key]*models.DailyRevenue)
+	customers := make(map[key]*models.CustomerRevenue)
+	for _, order := range m.orders {
+		if !window.Contains(order.CreatedAt) {
+			continue
+		}
+		currency := models.CurrencyOrDefault(order.Currency)
+		analytics.Add(order.Status, currency, 1, order.TotalAmount)
+		if !models.CountsAsRevenue(order.Status) {
+			continue
+		}
+
+		day := key{order.CreatedAt.UTC().Format("2006-01-02"), currency}
+		if days[day] == nil {
+			days[day] = &models.DailyRevenue{Date: day.id, Currency: currency}
+		}
+		days[day].Add(1, order.TotalAmount)
+
+		customer := key{order.CustomerID, currency}
+		if customers[customer] == nil {
+			customers[customer] = &models.CustomerRevenue{CustomerID: order.CustomerID, Currency: currency}
+		}
+		customers[customer].Add(1, order.TotalAmount)
+	}
+
+	for _, day := range days {
+		analytics.RevenueByDay = append(analytics.RevenueByDay, *day)
+	}
+	sort.Slice(analytics.RevenueByDay, func(i, j int) bool {
+		a, b := analytics.RevenueByDay[i], analytics.RevenueByDay[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		return a.Currency < b.Currency
+	})
+
+	var ranked []models.CustomerRevenue
+	for _, customer := range customers {
+		ranked = append(ranked, *customer)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.Currency != b.Currency {
+			return a.Currency < b.Currency
+		}
+		if a.Revenue != b.Revenue {
+			return a.Revenue > b.Revenue
+		}
+		return a.CustomerID < b.CustomerID
+	})
+	ranks := make(map[string]int)
+	for _, customer := range ranked {
+		if ranks[customer.Currency] < topCustomers {
+			analytics.TopCustomers = append(analytics.TopCustomers, customer)
+		}
+		ranks[customer.Currency]++
+	}
+	return analytics, nil
+}
@@ -0,0 +1,878 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jogardn/strangler-demo/internal/outbox"
+	"github.com/jogardn/strangler-demo/pkg/models"
+	"github.com/lib/pq"
+)
+
+// Postgres stores orders in the schema managed by internal/migrations and
+// writes events to its outbox.
+type Postgres struct {
+	db *sql.DB
+}
+
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+func (p *Postgres) Ping() error {
+	return p.db.Ping()
+}
+
+func enqueue(tx *sql.Tx, event *Event) error {
+	if event == nil {
+		return nil
+	}
+	return outbox.Enqueue(tx, event.Topic, event.Key, event.Payload)
+}
+
+// auditRow returns the order_audit columns of a change. before is nil for a
+// new order.
+func auditRow(audit Audit, action string, before, after *models.Order) ([]interface{}, error) {
+	var beforeJSON interface{}
+	if before != nil {
+		data, err := json.Marshal(before)
+		if err != nil {
+			return nil, err
+		}
+		beforeJSON = string(data)
+	}
+	afterJSON, err := json.Marshal(after)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{after.ID, action, audit.Actor, audit.Source, beforeJSON, string(afterJSON)}, nil
+}
+
+const insertAudit = `INSERT INTO order_audit (order_id, action, actor, source, before_state, after_state)`
+
+// recordChange writes the audit row of a change to an order, reading the
+// order as the change left it. before is the order as the transaction
+// locked it, or nil if the transaction created it.
+func recordChange(tx *sql.Tx, audit Audit, action, orderID string, before *models.Order) error {
+	after, err := scanOrder(tx.QueryRow(`SELECT `+orderColumns+` FROM orders o WHERE o.id = $1`, orderID))
+	if err != nil {
+		return err
+	}
+	row, err := auditRow(audit, action, before, after)
+	if err != nil {
+		return err
+	}
+	return insertRows(tx, insertAudit, "", [][]interface{}{row}, nil)
+}
+
+func (p *Postgres) Save(order *models.Order, audit Audit, event *Event) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Insert order. The unique constraints on id and idempotency_key decide
+	// which of two concurrent retries wins; the loser inserts nothing and is
+	// compared with the winner by request_hash.
+	query := `
+		INSERT INTO orders (id, customer_id, total_amount, currency, delivery_date, status, created_at, idempotency_key, request_hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9)
+		ON CONFLICT DO NOTHING
+	`
+	result, err := tx.Exec(query, order.ID, order.CustomerID, order.TotalAmount, models.CurrencyOrDefault(order.Currency),
+		order.DeliveryDate, order.Status, order.CreatedAt, order.IdempotencyKey, requestHash(order))
+	if err != nil {
+		return err
+	}
+	if inserted, err := result.RowsAffected(); err != nil {
+		return err
+	} else if inserted == 0 {
+		tx.Rollback()
+		return p.duplicate(order)
+	}
+
+	if err := insertItems(tx, order.ID, order.Items); err != nil {
+		return err
+	}
+	if err := enqueue(tx, event); err != nil {
+		return err
+	}
+	if err := recordChange(tx, audit, models.AuditActionCreated, order.ID, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	order.Version = 1
+	return nil
+}
+
+// maxRowsPerInsert keeps each multi-row insert of SaveBatch well below
+// Postgres' limit of 65535 parameters.
+const maxRowsPerInsert = 1000
+
+// insertRows inserts rows with multi-row VALUES lists appended to insert.
+// Each statement also gets suffix, and its result rows, if any, are passed
+// to scan.
+func insertRows(tx *sql.Tx, insert, suffix string, rows [][]interface{}, scan func(*sql.Rows) error) error {
+	for start := 0; start < len(rows); start += maxRowsPerInsert {
+		end := start + maxRowsPerInsert
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		var values strings.Builder
+		var args []interface{}
+		for i, row := range rows[start:end] {
+			if i > 0 {
+				values.WriteString(", ")
+			}
+			values.WriteString("(")
+			for j, value := range row {
+				if j > 0 {
+					values.WriteString(", ")
+				}
+				args = append(args, value)
+				fmt.Fprintf(&values, "$%d", len(args))
+			}
+			values.WriteString(")")
+		}
+
+		result, err := tx.Query(insert+" VALUES "+values.String()+" "+suffix, args...)
+		if err != nil {
+			return err
+		}
+		for result.Next() {
+			if err := scan(result); err != nil {
+				result.Close()
+				return err
+			}
+		}
+		result.Close()
+		if err := result.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Postgres) SaveBatch(orders []*models.Order, audit Audit, events []*Event) ([]error, error) {
+	results := make([]error, len(orders))
+
+	// Repeats within the batch are left out of the insert and reported
+	// like conflicts with stored orders
+	seen := make(map[string]bool)
+	included := make([]bool, len(orders))
+	var orderRows [][]interface{}
+	for i, order := range orders {
+		key := "key:" + order.IdempotencyKey
+		if seen["id:"+order.ID] || (order.IdempotencyKey != "" && seen[key]) {
+			continue
+		}
+		seen["id:"+order.ID] = true
+		if order.IdempotencyKey != "" {
+			seen[key] = true
+		}
+		included[i] = true
+		orderRows = append(orderRows, []interface{}{order.ID, order.CustomerID, order.TotalAmount, models.CurrencyOrDefault(order.Currency),
+			order.DeliveryDate, order.Status, order.CreatedAt,
+			sql.NullString{String: order.IdempotencyKey, Valid: order.IdempotencyKey != ""}, requestHash(order)})
+	}
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	inserted := make(map[string]bool)
+	err = insertRows(tx, `INSERT INTO orders (id, customer_id, total_amount, currency, delivery_date, status, created_at, idempotency_key, request_hash)`,
+		`ON CONFLICT DO NOTHING RETURNING id`, orderRows, func(rows *sql.Rows) error {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			inserted[id] = true
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	var itemRows, auditRows [][]interface{}
+	var entries []outbox.Entry
+	for i, order := range orders {
+		if !included[i] || !inserted[order.ID] {
+			continue
+		}
+		for _, item := range order.Items {
+			specJSON, _ := json.Marshal(item.Specifications)
+			itemRows = append(itemRows, []interface{}{order.ID, item.ProductID, item.Quantity, item.UnitPrice, string(specJSON)})
+		}
+		if events[i] != nil {
+			entries = append(entries, outbox.Entry{Topic: events[i].Topic, Key: events[i].Key, Event: events[i].Payload})
+		}
+
+		// The batch is stored as given, so the audit row is built from it
+		// instead of reading every order back
+		created := *order
+		created.Version = 1
+		row, err := auditRow(audit, models.AuditActionCreated, nil, &created)
+		if err != nil {
+			return nil, err
+		}
+		auditRows = append(auditRows, row)
+	}
+
+	err = insertRows(tx, `INSERT INTO order_items (order_id, product_id, quantity, unit_price, specifications)`,
+		"", itemRows, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := outbox.EnqueueAll(tx, entries); err != nil {
+		return nil, err
+	}
+	if err := insertRows(tx, insertAudit, "", auditRows, nil); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for i, order := range orders {
+		if included[i] && inserted[order.ID] {
+			order.Version = 1
+			continue
+		}
+		results[i] = p.duplicate(order)
+	}
+	return results, nil
+}
+
+func insertItems(tx *sql.Tx, orderID string, items []models.OrderItem) error {
+	for _, item := range items {
+		specJSON, _ := json.Marshal(item.Specifications)
+		_, err := tx.Exec(`
+			INSERT INTO order_items (order_id, product_id, quantity, unit_price, specifications)
+			VALUES ($1, $2, $3, $4, $5)
+		`, orderID, item.ProductID, item.Quantity, item.UnitPrice, string(specJSON))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// duplicate loads the stored order that conflicts with order and returns
+// the *DuplicateOrderError comparing the two, or the error loading it.
+func (p *Postgres) duplicate(order *models.Order) error {
+	var existingID, existingKey, existingHash string
+	query := `
+		SELECT id, COALESCE(idempotency_key, ''), COALESCE(request_hash, '') FROM orders
+		WHERE id = $1 OR (idempotency_key IS NOT NULL AND idempotency_key = NULLIF($2, ''))
+		LIMIT 1
+	`
+	if err := p.db.QueryRow(query, order.ID, order.IdempotencyKey).Scan(&existingID, &existingKey, &existingHash); err != nil {
+		return notFound(err)
+	}
+
+	existing, err := p.Get(existingID)
+	if err != nil {
+		return err
+	}
+	existing.IdempotencyKey = existingKey
+	return duplicateOf(order, existing, existingHash)
+}
+
+func (p *Postgres) Get(orderID string) (*models.Order, error) {
+	query := `SELECT ` + orderColumns + ` FROM orders o WHERE o.id = $1`
+	order, err := scanOrder(p.db.QueryRow(query, orderID))
+	return order, notFound(err)
+}
+
+// notFound translates sql.ErrNoRows to ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// lockOrder locks an order for the rest of the transaction and returns it.
+func lockOrder(tx *sql.Tx, orderID string) (*models.Order, error) {
+	query := `SELECT ` + orderColumns + ` FROM orders o WHERE o.id = $1 FOR UPDATE`
+	order, err := scanOrder(tx.QueryRow(query, orderID))
+	return order, notFound(err)
+}
+
+// cancellationState returns the state of an order's cancellation, if any.
+func cancellationState(order *models.Order) string {
+	if order.Cancellation == nil {
+		return ""
+	}
+	return order.Cancellation.State
+}
+
+func (p *Postgres) Update(orderID string, version int, audit Audit, update UpdateFunc) (*models.Order, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := lockOrder(tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && before.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	order := *before
+	order.Items = append([]models.OrderItem(nil), before.Items...)
+	order.Version++
+	event, err := update(&order)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return before, nil
+	}
+
+	_, err = tx.Exec(`UPDATE orders SET delivery_date = $1, total_amount = $2, version = $3 WHERE id = $4`,
+		order.DeliveryDate, order.TotalAmount, order.Version, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM order_items WHERE order_id = $1`, orderID); err != nil {
+		return nil, err
+	}
+	if err := insertItems(tx, orderID, order.Items); err != nil {
+		return nil, err
+	}
+	if err := enqueue(tx, event); err != nil {
+		return nil, err
+	}
+	if err := recordChange(tx, audit, models.AuditActionUpdated, orderID, before); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (p *Postgres) UpdateStatus(orderID, status string, audit Audit, event EventFunc) (string, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	before, err := lockOrder(tx, orderID)
+	if err != nil {
+		return "", err
+	}
+	if cancellationState(before) == models.CancellationRequested {
+		return "", ErrCancellationPending
+	}
+	current := before.Status
+	if err := models.ValidateTransition(current, status); err != nil {
+		return "", err
+	}
+
+	if _, err := tx.Exec(`UPDATE orders SET status = $1, version = version + 1 WHERE id = $2`, status, orderID); err != nil {
+		return "", err
+	}
+	e := event(current)
+	if err := enqueue(tx, &e); err != nil {
+		return "", err
+	}
+	if err := recordChange(tx, audit, models.AuditActionStatusChanged, orderID, before); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return current, nil
+}
+
+func (p *Postgres) RequestCancellation(orderID string, req models.CancellationRequest, requestedAt time.Time, audit Audit, event Event) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockOrder(tx, orderID)
+	if err != nil {
+		return err
+	}
+	if cancellationState(before) == models.CancellationRequested {
+		return ErrCancellationPending
+	}
+	if err := models.ValidateTransition(before.Status, models.StatusCancelled); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE orders SET cancellation_reason = $1, cancellation_note = $2,
+			cancellation_state = $3, cancellation_message = NULL, cancellation_requested_at = $4,
+			version = version + 1
+		WHERE id = $5
+	`, req.ReasonCode, req.Note, models.CancellationRequested, requestedAt, orderID)
+	if err != nil {
+		return err
+	}
+	if err := enqueue(tx, &event); err != nil {
+		return err
+	}
+	if err := recordChange(tx, audit, models.AuditActionCancellationRequested, orderID, before); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (p *Postgres) RejectCancellation(orderID, message string, audit Audit) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockOrder(tx, orderID)
+	if errors.Is(err, ErrNotFound) || (err == nil && cancellationState(before) != models.CancellationRequested) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE orders SET cancellation_state = $1, cancellation_message = $2, version = version + 1
+		WHERE id = $3
+	`, models.CancellationRejected, message, orderID)
+	if err != nil {
+		return err
+	}
+	if err := recordChange(tx, audit, models.AuditActionCancellationRejected, orderID, before); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (p *Postgres) ConfirmCancellation(orderID, message string, audit Audit, event EventFunc) (string, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	before, err := lockOrder(tx, orderID)
+	if err != nil {
+		return "", err
+	}
+	status := before.Status
+	if status == models.StatusCancelled {
+		return "", nil
+	}
+
+	if transitionErr := models.ValidateTransition(status, models.StatusCancelled); transitionErr != nil {
+		_, err := tx.Exec(`
+			UPDATE orders SET cancellation_state = $1, cancellation_message = $2, version = version + 1
+			WHERE id = $3
+		`, models.CancellationRejected, transitionErr.Error(), orderID)
+		if err != nil {
+			return "", err
+		}
+		if err := recordChange(tx, audit, models.AuditActionCancellationRejected, orderID, before); err != nil {
+			return "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return "", err
+		}
+		return "", transitionErr
+	}
+
+	_, err = tx.Exec(`
+		UPDATE orders SET status = $1, cancellation_state = $2, cancellation_message = $3,
+			version = version + 1
+		WHERE id = $4
+	`, models.StatusCancelled, models.CancellationConfirmed, message, orderID)
+	if err != nil {
+		return "", err
+	}
+	e := event(status)
+	if err := enqueue(tx, &e); err != nil {
+		return "", err
+	}
+	if err := recordChange(tx, audit, models.AuditActionCancellationConfirmed, orderID, before); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return status, nil
+}
+
+func (p *Postgres) History(orderID string) ([]models.AuditEntry, error) {
+	rows, err := p.db.Query(`
+		SELECT id, order_id, action, actor, source, before_state, after_state, created_at
+		FROM order_audit WHERE order_id = $1
+		ORDER BY id
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		var before, after []byte
+		err := rows.Scan(&entry.ID, &entry.OrderID, &entry.Action, &entry.Actor, &entry.Source,
+			&before, &after, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if before != nil {
+			entry.Before = json.RawMessage(before)
+		}
+		entry.After = json.RawMessage(after)
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
+
+// orderSortColumns maps sort fields to columns. Only these columns are ever
+// interpolated into the query.
+var orderSortColumns = map[string]string{
+	models.SortCreatedAt:    "created_at",
+	models.SortDeliveryDate: "delivery_date",
+	models.SortTotalAmount:  "total_amount",
+}
+
+// List reads orders and their items with a single query. It pages by keyset
+// on (sort column, id), so later pages cost the same as the first.
+func (p *Postgres) List(q models.OrderQuery, emit func(*models.Order) error) (string, error) {
+	field, descending, err := q.SortField()
+	if err != nil {
+		return "", err
+	}
+	cursor, err := q.DecodeCursor()
+	if err != nil {
+		return "", err
+	}
+	column := orderSortColumns[field]
+
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, values ...interface{}) {
+		placeholders := make([]interface{}, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			placeholders[i] = len(args)
+		}
+		conditions = append(conditions, fmt.Sprintf(condition, placeholders...))
+	}
+
+	if q.CustomerID != "" {
+		where("customer_id = $%d", q.CustomerID)
+	}
+	if q.Status != "" {
+		where("status = $%d", q.Status)
+	}
+	if !q.CreatedFrom.IsZero() {
+		where("created_at >= $%d", q.CreatedFrom)
+	}
+	if !q.CreatedTo.IsZero() {
+		where("created_at < $%d", q.CreatedTo)
+	}
+	if !q.DeliveryFrom.IsZero() {
+		where("delivery_date >= $%d", q.DeliveryFrom)
+	}
+	if !q.DeliveryTo.IsZero() {
+		where("delivery_date < $%d", q.DeliveryTo)
+	}
+	if q.FiltersItems() {
+		// One item has to match every item filter. The containment test is
+		// served by the GIN index on specifications.
+		itemConditions := []string{"i.order_id = o.id"}
+		var itemArgs []interface{}
+		if q.ProductID != "" {
+			itemConditions = append(itemConditions, "i.product_id = $%d")
+			itemArgs = append(itemArgs, q.ProductID)
+		}
+		if len(q.Specifications) > 0 {
+			specJSON, _ := json.Marshal(q.Specifications)
+			itemConditions = append(itemConditions, "i.specifications @> $%d::jsonb")
+			itemArgs = append(itemArgs, string(specJSON))
+		}
+		where("EXISTS (SELECT 1 FROM order_items i WHERE "+strings.Join(itemConditions, " AND ")+")", itemArgs...)
+	}
+
+	direction := "ASC"
+	comparison := ">"
+	if descending {
+		direction = "DESC"
+		comparison = "<"
+	}
+	if cursor != nil {
+		where("("+column+", id) "+comparison+" ($%d, $%d)", cursor.SortValue(field), cursor.ID)
+	}
+
+	query := `SELECT ` + orderColumns + ` FROM orders o`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	// Fetch one extra row to know whether there is a next page
+	size := q.PageSize()
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %d", column, direction, direction, size+1)
+
+	rows, err := p.db.Query(query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var last *models.Order
+	count := 0
+	for rows.Next() {
+		if count == size {
+			return q.CursorAfter(last), rows.Err()
+		}
+
+		order, err := scanOrder(rows)
+		if err != nil {
+			return "", err
+		}
+		if err := emit(order); err != nil {
+			return "", err
+		}
+		last = order
+		count++
+	}
+
+	return "", rows.Err()
+}
+
+// orderColumns selects an order with its items aggregated into a JSON array,
+// so reading any number of orders is a single round trip. Use it with the
+// orders table aliased as o.
+const orderColumns = `
+	o.id, o.customer_id, o.total_amount, o.currency, o.delivery_date, o.status, o.created_at, o.version,
+	o.cancellation_reason, o.cancellation_note, o.cancellation_state, o.cancellation_message, o.cancellation_requested_at,
+	COALESCE((
+		SELECT json_agg(json_build_object(
+			'product_id', i.product_id,
+			'quantity', i.quantity,
+			'unit_price', i.unit_price,
+			'specifications', i.specifications
+		) ORDER BY i.id)
+		FROM order_items i WHERE i.order_id = o.id
+	), '[]')`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// cancellationColumns holds the nullable cancellation columns of an order.
+type cancellationColumns struct {
+	reason      sql.NullString
+	note        sql.NullString
+	state       sql.NullString
+	message     sql.NullString
+	requestedAt sql.NullTime
+}
+
+func (c cancellationColumns) toModel() *models.Cancellation {
+	if !c.state.Valid {
+		return nil
+	}
+	return &models.Cancellation{
+		ReasonCode:  c.reason.String,
+		Note:        c.note.String,
+		State:       c.state.String,
+		Message:     c.message.String,
+		RequestedAt: c.requestedAt.Time,
+	}
+}
+
+// scanOrder reads a row selected with orderColumns.
+func scanOrder(row rowScanner) (*models.Order, error) {
+	order := &models.Order{}
+	var cancellation cancellationColumns
+	var itemsJSON []byte
+	err := row.Scan(
+		&order.ID, &order.CustomerID, &order.TotalAmount, &order.Currency,
+		&order.DeliveryDate, &order.Status, &order.CreatedAt, &order.Version,
+		&cancellation.reason, &cancellation.note, &cancellation.state,
+		&cancellation.message, &cancellation.requestedAt,
+		&itemsJSON,
+	)
+	if err != nil {
+		return nil, err
+	}
+	order.Cancellation = cancellation.toModel()
+
+	if err := json.Unmarshal(itemsJSON, &order.Items); err != nil {
+		return nil, fmt.Errorf("failed to decode items of order %s: %w", order.ID, err)
+	}
+	return order, nil
+}
+
+// createdIn adds the conditions selecting orders created in the window to
+// conditions and returns them as a WHERE clause.
+func createdIn(window models.AnalyticsWindow, conditions []string, args []interface{}) (string, []interface{}) {
+	if !window.From.IsZero() {
+		args = append(args, window.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if !window.To.IsZero() {
+		args = append(args, window.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// analyticsTx runs read in a read-only transaction, so the queries of a
+// summary see the same orders.
+func (p *Postgres) analyticsTx(read func(tx *sql.Tx) error) error {
+	tx, err := p.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := read(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CustomerSummary reads the order_summaries view, which carries the item
+// count, total quantity and currency of each order.
+func (p *Postgres) CustomerSummary(customerID string, window models.AnalyticsWindow) (*models.CustomerOrderSummary, error) {
+	where, args := createdIn(window, []string{"customer_id = $1"}, []interface{}{customerID})
+	query := `
+		SELECT status, currency, COUNT(*), COALESCE(SUM(total_amount), 0), COALESCE(SUM(item_count), 0),
+			COALESCE(SUM(total_quantity), 0), MIN(created_at), MAX(created_at)
+		FROM order_summaries` + where + `
+		GROUP BY status, currency`
+
+	rows, err := p.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summary := models.NewCustomerOrderSummary(customerID, window)
+	for rows.Next() {
+		var status, currency string
+		var orders, items, quantity int
+		var amount models.Money
+		var first, last time.Time
+		if err := rows.Scan(&status, &currency, &orders, &amount, &items, &quantity, &first, &last); err != nil {
+			return nil, err
+		}
+		summary.AddOrders(status, currency, orders, amount, items, quantity, first, last)
+	}
+	return summary, rows.Err()
+}
+
+func (p *Postgres) Analytics(window models.AnalyticsWindow, topCustomers int) (*models.OrderAnalytics, error) {
+	analytics := models.NewOrderAnalytics(window)
+	where, args := createdIn(window, nil, nil)
+	revenueWhere, revenueArgs := createdIn(window, []string{"status <> ALL($1)"}, []interface{}{pq.Array(models.NonRevenueStatuses)})
+
+	err := p.analyticsTx(func(tx *sql.Tx) error {
+		err := queryRows(tx, `SELECT status, currency, COUNT(*), SUM(total_amount) FROM order_summaries`+where+` GROUP BY status, currency`,
+			args, func(rows *sql.Rows) error {
+				var status, currency string
+				var orders int
+				var amount models.Money
+				if err := rows.Scan(&status, &currency, &orders, &amount); err != nil {
+					return err
+				}
+				analytics.Add(status, currency, orders, amount)
+				return nil
+			})
+		if err != nil {
+			return err
+		}
+
+		err = queryRows(tx, `
+			SELECT to_char(date_trunc('day', created_at), 'YYYY-MM-DD') AS day, currency, COUNT(*), SUM(total_amount)
+			FROM order_summaries`+revenueWhere+`
+			GROUP BY day, currency ORDER BY day, currency`,
+			revenueArgs, func(rows *sql.Rows) error {
+				var day models.DailyRevenue
+				var orders int
+				var amount models.Money
+				if err := rows.Scan(&day.Date, &day.Currency, &orders, &amount); err != nil {
+					return err
+				}
+				day.Add(orders, amount)
+				analytics.RevenueByDay = append(analytics.RevenueByDay, day)
+				return nil
+			})
+		if err != nil || topCustomers == 0 {
+			return err
+		}
+
+		// Customers are ranked within each currency
+		return queryRows(tx, `
+			SELECT customer_id, currency, orders, revenue FROM (
+				SELECT customer_id, currency, COUNT(*) AS orders, SUM(total_amount) AS revenue,
+					ROW_NUMBER() OVER (PARTITION BY currency ORDER BY SUM(total_amount) DESC, customer_id) AS customer_rank
+				FROM order_summaries`+revenueWhere+`
+				GROUP BY customer_id, currency
+			) ranked
+			WHERE customer_rank <= `+fmt.Sprint(topCustomers)+`
+			ORDER BY currency, customer_rank`,
+			revenueArgs, func(rows *sql.Rows) error {
+				var customer models.CustomerRevenue
+				var orders int
+				var amount models.Money
+				if err := rows.Scan(&customer.CustomerID, &customer.Currency, &orders, &amount); err != nil {
+					return err
+				}
+				customer.Add(orders, amount)
+				analytics.TopCustomers = append(analytics.TopCustomers, customer)
+				return nil
+			})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return analytics, nil
+}
+
+// queryRows passes each result row of query to scan.
+func queryRows(tx *sql.Tx, query string, args []interface{}, scan func(*sql.Rows) error) error {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
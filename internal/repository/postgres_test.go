@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jogardn/strangler-demo/internal/migrations"
+	"github.com/jogardn/strangler-demo/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+// The read path tests run against a real Postgres. Set
+// ORDER_SERVICE_TEST_DSN to a key=value DSN, e.g. with docker-compose up:
+//
+//	ORDER_SERVICE_TEST_DSN="host=localhost user=orderservice password=orderservice dbname=orderservice sslmode=disable" \
+//	  go test ./internal/repository -run ReadPath -bench . -benchmem
+//
+// The data is seeded into a temporary schema that is dropped afterwards.
+const seededOrders = 1000
+
+var (
+	seedOnce   sync.Once
+	seedRepo   *Postgres
+	seedSchema string
+	seedErr    error
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if seedSchema != "" {
+		if db, err := sql.Open("postgres", os.Getenv("ORDER_SERVICE_TEST_DSN")); err == nil {
+			db.Exec(`DROP SCHEMA IF EXISTS ` + seedSchema + ` CASCADE`)
+			db.Close()
+		}
+	}
+	os.Exit(code)
+}
+
+// seededRepo returns a repository on a schema holding seededOrders orders
+// with three items each.
+func seededRepo(tb testing.TB) *Postgres {
+	tb.Helper()
+
+	dsn := os.Getenv("ORDER_SERVICE_TEST_DSN")
+	if dsn == "" {
+		tb.Skip("ORDER_SERVICE_TEST_DSN not set")
+	}
+
+	seedOnce.Do(func() {
+		seedSchema = fmt.Sprintf("read_path_%d", os.Getpid())
+		admin, err := sql.Open("postgres", dsn)
+		if err != nil {
+			seedErr = err
+			return
+		}
+		defer admin.Close()
+		if _, seedErr = admin.Exec(`CREATE SCHEMA ` + seedSchema); seedErr != nil {
+			return
+		}
+
+		db, err := sql.Open("postgres", dsn+" search_path="+seedSchema)
+		if err != nil {
+			seedErr = err
+			return
+		}
+		logger := logrus.New()
+		logger.SetOutput(io.Discard)
+		migrator, err := migrations.New(db, logger)
+		if err != nil {
+			seedErr = err
+			return
+		}
+		if _, seedErr = migrator.Up(); seedErr != nil {
+			return
+		}
+		seedRepo = NewPostgres(db)
+
+		created := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+		for i := 0; i < seededOrders; i++ {
+			order := &models.Order{
+				ID:           fmt.Sprintf("order-%05d", i),
+				CustomerID:   fmt.Sprintf("customer-%d", i%50),
+				DeliveryDate: created.AddDate(0, 0, 14),
+				Status:       models.StatusPending,
+				CreatedAt:    created.Add(time.Duration(i) * time.Minute),
+			}
+			for j := 0; j < 3; j++ {
+				order.Items = append(order.Items, models.OrderItem{
+					ProductID:      fmt.Sprintf("P-%d", j),
+					Quantity:       j + 1,
+					UnitPrice:      999,
+					Specifications: map[string]string{"color": "red"},
+				})
+				order.TotalAmount += models.Money(999).Times(j + 1)
+			}
+			if seedErr = seedRepo.Save(order, testAudit, nil); seedErr != nil {
+				return
+			}
+		}
+	})
+
+	if seedErr != nil {
+		tb.Fatalf("Failed to seed orders: %v", seedErr)
+	}
+	return seedRepo
+}
+
+// listOrdersWithItemQueries is the previous read path: one query for the
+// orders, then one query per order for its items. It is kept as the
+// benchmark baseline.
+func listOrdersWithItemQueries(p *Postgres, limit int) ([]*models.Order, error) {
+	rows, err := p.db.Query(`
+		SELECT id, customer_id, total_amount, delivery_date, status, created_at
+		FROM orders ORDER BY created_at DESC LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []*models.Order
+	for rows.Next() {
+		order := &models.Order{}
+		err := rows.Scan(&order.ID, &order.CustomerID, &order.TotalAmount,
+			&order.DeliveryDate, &order.Status, &order.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	for _, order := range orders {
+		itemRows, err := p.db.Query(`
+			SELECT product_id, quantity, unit_price, specifications
+			FROM order_items WHERE order_id = $1
+		`, order.ID)
+		if err != nil {
+			return nil, err
+		}
+		for itemRows.Next() {
+			var item models.OrderItem
+			var specJSON string
+			if err := itemRows.Scan(&item.ProductID, &item.Quantity, &item.UnitPrice, &specJSON); err != nil {
+				itemRows.Close()
+				return nil, err
+			}
+			json.Unmarshal([]byte(specJSON), &item.Specifications)
+			order.Items = append(order.Items, item)
+		}
+		itemRows.Close()
+	}
+
+	return orders, nil
+}
+
+func TestReadPathMatchesItemQueries(t *testing.T) {
+	p := seededRepo(t)
+
+	expected, err := listOrdersWithItemQueries(p, models.MaxPageSize)
+	if err != nil {
+		t.Fatalf("Baseline query failed: %v", err)
+	}
+
+	var streamed []*models.Order
+	next, err := p.List(models.OrderQuery{Limit: models.MaxPageSize}, func(order *models.Order) error {
+		streamed = append(streamed, order)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if next != "" {
+		t.Errorf("Expected a single page, got cursor %q", next)
+	}
+	if len(streamed) != len(expected) {
+		t.Fatalf("Expected %d orders, got %d", len(expected), len(streamed))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(expected[i].Items, streamed[i].Items) {
+			t.Errorf("Items of %s differ: %v vs %v", expected[i].ID, expected[i].Items, streamed[i].Items)
+		}
+	}
+}
+
+func TestReadPathFiltersAndPages(t *testing.T) {
+	p := seededRepo(t)
+
+	q := models.OrderQuery{CustomerID: "customer-1", Limit: 10}
+	var orders []*models.Order
+	next, err := p.List(q, func(order *models.Order) error {
+		orders = append(orders, order)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(orders) != 10 || next == "" {
+		t.Fatalf("Expected a full first page with a cursor, got %d orders and cursor %q", len(orders), next)
+	}
+	for _, order := range orders {
+		if order.CustomerID != "customer-1" || len(order.Items) != 3 {
+			t.Errorf("Unexpected order %s of %s with %d items", order.ID, order.CustomerID, len(order.Items))
+		}
+	}
+
+	q.Cursor = next
+	_, err = p.List(q, func(order *models.Order) error {
+		if order.ID == orders[len(orders)-1].ID {
+			t.Errorf("Order %s returned on two pages", order.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List of second page failed: %v", err)
+	}
+}
+
+func BenchmarkReadPathItemQueries(b *testing.B) {
+	p := seededRepo(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		orders, err := listOrdersWithItemQueries(p, models.MaxPageSize)
+		if err != nil {
+			b.Fatal(err)
+		}
+		json.NewEncoder(io.Discard).Encode(orders)
+	}
+}
+
+func BenchmarkReadPathAggregated(b *testing.B) {
+	p := seededRepo(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		encoder := json.NewEncoder(io.Discard)
+		_, err := p.List(models.OrderQuery{Limit: models.MaxPageSize}, func(order *models.Order) error {
+			return encoder.Encode(order)
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadPathGetOrder(b *testing.B) {
+	p := seededRepo(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Get(fmt.Sprintf("order-%05d", i%seededOrders)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
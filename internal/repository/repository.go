@@ -0,0 +1,167 @@
+// Package repository stores the Order Service's orders. OrderRepository is
+// implemented for Postgres, which the service runs on, and in memory, which
+// lets the HTTP handlers be tested without a database.
+//
+// Changes that publish an event take the event as an argument: the Postgres
+// implementation writes it to the outbox in the same transaction, so an
+// event exists if and only if its change was stored. The same goes for the
+// order's audit trail: every change records who made it and the order
+// before and after.
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jogardn/strangler-demo/pkg/models"
+)
+
+var (
+	// ErrNotFound is returned when no order has the requested ID.
+	ErrNotFound = errors.New("order not found")
+	// ErrCancellationPending is returned when a cancel is requested twice,
+	// or when the status of an order awaiting SAP's answer is changed.
+	ErrCancellationPending = errors.New("a cancellation is already pending for this order")
+	// ErrVersionMismatch is returned when an order changed since the version
+	// an update was based on.
+	ErrVersionMismatch = errors.New("order version does not match")
+)
+
+// DuplicateOrderError is returned by Save when the order ID or idempotency
+// key is already stored. Existing is the stored order. Conflict is empty
+// when Existing was stored from the same order, so the save was a retry;
+// otherwise it names what a different order reused.
+type DuplicateOrderError struct {
+	Existing *models.Order
+	Conflict Conflict
+}
+
+func (e *DuplicateOrderError) Error() string {
+	switch e.Conflict {
+	case ConflictIdempotencyKey:
+		return fmt.Sprintf("idempotency key of order %s was reused for a different order", e.Existing.ID)
+	case ConflictID:
+		return fmt.Sprintf("order ID %s is taken by a different order", e.Existing.ID)
+	}
+	return fmt.Sprintf("order %s already exists", e.Existing.ID)
+}
+
+// Conflict says why a new order collides with a stored one it differs from.
+type Conflict string
+
+const (
+	// ConflictIdempotencyKey is an idempotency key sent again with a
+	// different order.
+	ConflictIdempotencyKey Conflict = "idempotency_key"
+	// ConflictID is an order ID already used by a different order.
+	ConflictID Conflict = "id"
+)
+
+// requestHash identifies what a client asked to store, so a retry can be
+// told apart from a different order with the same ID or idempotency key.
+// The ID, status and creation time are left out: the proxy fills them in
+// when they are missing, so they may differ between retries.
+func requestHash(order *models.Order) string {
+	data, _ := json.Marshal(struct {
+		CustomerID   string             `json:"customer_id"`
+		Items        []models.OrderItem `json:"items"`
+		TotalAmount  models.Money       `json:"total_amount"`
+		Currency     string             `json:"currency"`
+		DeliveryDate time.Time          `json:"delivery_date"`
+	}{order.CustomerID, order.Items, order.TotalAmount, models.CurrencyOrDefault(order.Currency), order.DeliveryDate.UTC()})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// duplicateOf reports a save that collided with existing, which was stored
+// with storedHash. Orders stored before hashes were kept have none and are
+// taken as retries.
+func duplicateOf(order, existing *models.Order, storedHash string) *DuplicateOrderError {
+	duplicate := &DuplicateOrderError{Existing: existing}
+	if storedHash == "" || storedHash == requestHash(order) {
+		return duplicate
+	}
+	if order.IdempotencyKey != "" && order.IdempotencyKey == existing.IdempotencyKey {
+		duplicate.Conflict = ConflictIdempotencyKey
+	} else {
+		duplicate.Conflict = ConflictID
+	}
+	return duplicate
+}
+
+// Event is a message to publish once the change it describes is stored.
+// Key is the Kafka message key, the order ID.
+type Event struct {
+	Topic   string
+	Key     string
+	Payload interface{}
+}
+
+// Audit says who made a change and through what, for the order's history.
+// Source is one of the models.AuditSource constants.
+type Audit struct {
+	Actor  string
+	Source string
+}
+
+// EventFunc builds the event of a status change from the status it replaced.
+type EventFunc func(previous string) Event
+
+// UpdateFunc changes a locked order, which already carries its next version,
+// and returns the event describing the change. A nil event means nothing
+// changed and nothing is stored.
+type UpdateFunc func(order *models.Order) (*Event, error)
+
+// OrderRepository stores orders and their lifecycle changes. Status changes
+// are checked against models.ValidateTransition while the order is locked,
+// so concurrent changes are checked against the status they replace. Every
+// change increments the order's version.
+type OrderRepository interface {
+	// Save stores a new order at version 1 with its items and, if not nil,
+	// its event. It fails with a *DuplicateOrderError if the ID or
+	// idempotency key is taken.
+	Save(order *models.Order, audit Audit, event *Event) error
+	// SaveBatch saves orders like Save, all in one transaction, where
+	// events[i] is the event of orders[i]. It returns one error per order:
+	// nil if it was stored, or a *DuplicateOrderError. An order that
+	// repeats the ID or idempotency key of an earlier one in the batch is a
+	// duplicate of it. The returned error is set if nothing was stored.
+	SaveBatch(orders []*models.Order, audit Audit, events []*Event) ([]error, error)
+	// Get returns an order with its items.
+	Get(orderID string) (*models.Order, error)
+	// List passes one page of orders to emit as they are read and returns
+	// the cursor of the next page.
+	List(q models.OrderQuery, emit func(*models.Order) error) (string, error)
+	// Update changes an order's delivery date and items if it is still at
+	// version, or at any version if version is 0, and returns the order as
+	// stored.
+	Update(orderID string, version int, audit Audit, update UpdateFunc) (*models.Order, error)
+	// UpdateStatus moves an order to status and returns the previous status.
+	// It fails with ErrCancellationPending while SAP has not answered a
+	// cancellation request.
+	UpdateStatus(orderID, status string, audit Audit, event EventFunc) (string, error)
+	// RequestCancellation records that a cancel is waiting for SAP. A
+	// rejected cancellation can be requested again.
+	RequestCancellation(orderID string, req models.CancellationRequest, requestedAt time.Time, audit Audit, event Event) error
+	// RejectCancellation records SAP's refusal of a pending cancellation.
+	RejectCancellation(orderID, message string, audit Audit) error
+	// ConfirmCancellation marks the order cancelled and returns the previous
+	// status, or an empty status if it was already cancelled. If the order
+	// can no longer be cancelled, the request is recorded as rejected and
+	// the transition error returned.
+	ConfirmCancellation(orderID, message string, audit Audit, event EventFunc) (string, error)
+	// History returns the audit trail of an order, oldest change first. It
+	// is empty for an unknown order.
+	History(orderID string) ([]models.AuditEntry, error)
+	// CustomerSummary sums the orders of a customer created in the window.
+	CustomerSummary(customerID string, window models.AnalyticsWindow) (*models.CustomerOrderSummary, error)
+	// Analytics sums all orders created in the window and ranks the top
+	// customers by revenue.
+	Analytics(window models.AnalyticsWindow, topCustomers int) (*models.OrderAnalytics, error)
+	// Ping checks that the store is reachable.
+	Ping() error
+}
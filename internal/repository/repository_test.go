@@ -0,0 +1,478 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jogardn/strangler-demo/internal/migrations"
+	"github.com/jogardn/strangler-demo/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Both implementations run the same tests, so the in-memory repository the
+// handler tests use behaves like the one in production.
+func TestMemoryRepository(t *testing.T) {
+	testRepository(t, func(t *testing.T) OrderRepository { return NewMemory() })
+}
+
+func TestPostgresRepository(t *testing.T) {
+	testRepository(t, freshPostgres)
+}
+
+// freshPostgres returns a repository on a new, migrated schema. It needs
+// Postgres, so the test is skipped unless ORDER_SERVICE_TEST_DSN is set.
+func freshPostgres(t *testing.T) OrderRepository {
+	t.Helper()
+
+	dsn := os.Getenv("ORDER_SERVICE_TEST_DSN")
+	if dsn == "" {
+		t.Skip("ORDER_SERVICE_TEST_DSN not set")
+	}
+
+	schema := fmt.Sprintf("repository_test_%d", time.Now().UnixNano())
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+		admin.Close()
+	})
+
+	db, err := sql.Open("postgres", dsn+" search_path="+schema)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	migrator, err := migrations.New(db, logger)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	return NewPostgres(db)
+}
+
+func testOrder(id string) *models.Order {
+	created := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	return &models.Order{
+		ID:           id,
+		CustomerID:   "customer-1",
+		Items:        []models.OrderItem{{ProductID: "P-1", Quantity: 2, UnitPrice: 500, Specifications: map[string]string{"color": "red"}}},
+		TotalAmount:  1000,
+		DeliveryDate: created.AddDate(0, 0, 14),
+		Status:       models.StatusPending,
+		CreatedAt:    created,
+	}
+}
+
+var testAudit = Audit{Actor: "tester", Source: models.AuditSourceProxy}
+
+func statusEvent(orderID string) EventFunc {
+	return func(previous string) Event {
+		return Event{Topic: "order.status_changed", Key: orderID, Payload: map[string]string{"from": previous}}
+	}
+}
+
+func testRepository(t *testing.T, newRepo func(*testing.T) OrderRepository) {
+	t.Run("SaveAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+		order := testOrder("order-1")
+		order.IdempotencyKey = "key-1"
+		if err := repo.Save(order, testAudit, nil); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+
+		stored, err := repo.Get("order-1")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if stored.CustomerID != "customer-1" || len(stored.Items) != 1 || stored.Items[0].Specifications["color"] != "red" {
+			t.Errorf("Unexpected stored order %+v", stored)
+		}
+		if stored.Currency != models.DefaultCurrency {
+			t.Errorf("Expected an order without a currency to be in %s, got %q", models.DefaultCurrency, stored.Currency)
+		}
+
+		euros := testOrder("order-2")
+		euros.Currency = "EUR"
+		if err := repo.Save(euros, testAudit, nil); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		if stored, _ := repo.Get("order-2"); stored == nil || stored.Currency != "EUR" {
+			t.Errorf("Expected the order's currency to be stored, got %+v", stored)
+		}
+
+		if _, err := repo.Get("missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("SaveDetectsDuplicates", func(t *testing.T) {
+		repo := newRepo(t)
+		order := testOrder("order-1")
+		order.IdempotencyKey = "key-1"
+		repo.Save(order, testAudit, nil)
+
+		retry := testOrder("order-2")
+		retry.IdempotencyKey = "key-1"
+		var duplicate *DuplicateOrderError
+		if err := repo.Save(retry, testAudit, nil); !errors.As(err, &duplicate) {
+			t.Fatalf("Expected a duplicate error for a reused key, got %v", err)
+		}
+		if duplicate.Existing.ID != "order-1" || duplicate.Existing.IdempotencyKey != "key-1" {
+			t.Errorf("Expected the stored order with its key, got %+v", duplicate.Existing)
+		}
+
+		if err := repo.Save(testOrder("order-1"), testAudit, nil); !errors.As(err, &duplicate) {
+			t.Errorf("Expected a duplicate error for a reused ID, got %v", err)
+		}
+	})
+
+	t.Run("SaveRejectsDifferentOrdersWithTakenKeyOrID", func(t *testing.T) {
+		repo := newRepo(t)
+		order := testOrder("order-1")
+		order.IdempotencyKey = "key-1"
+		repo.Save(order, testAudit, nil)
+
+		var duplicate *DuplicateOrderError
+		if err := repo.Save(testOrder("order-1"), testAudit, nil); !errors.As(err, &duplicate) || duplicate.Conflict != "" {
+			t.Errorf("Expected the same order to be a retry, got %v", err)
+		}
+
+		changed := testOrder("order-2")
+		changed.IdempotencyKey = "key-1"
+		changed.TotalAmount = 2000
+		if err := repo.Save(changed, testAudit, nil); !errors.As(err, &duplicate) || duplicate.Conflict != ConflictIdempotencyKey {
+			t.Errorf("Expected a reused key to conflict, got %v", err)
+		}
+
+		changed = testOrder("order-1")
+		changed.CustomerID = "customer-2"
+		results, err := repo.SaveBatch([]*models.Order{changed}, testAudit, []*Event{nil})
+		if err != nil || !errors.As(results[0], &duplicate) || duplicate.Conflict != ConflictID {
+			t.Errorf("Expected a reused ID to conflict, got %v %v", results, err)
+		}
+	})
+
+	t.Run("SaveBatchReportsEachOrder", func(t *testing.T) {
+		repo := newRepo(t)
+		repo.Save(testOrder("order-1"), testAudit, nil)
+
+		repeated := testOrder("order-4")
+		repeated.IdempotencyKey = "key-3"
+		third := testOrder("order-3")
+		third.IdempotencyKey = "key-3"
+		orders := []*models.Order{testOrder("order-1"), testOrder("order-2"), third, repeated, testOrder("order-2")}
+		events := make([]*Event, len(orders))
+		for i, order := range orders {
+			events[i] = &Event{Topic: "order.created", Key: order.ID}
+		}
+
+		results, err := repo.SaveBatch(orders, testAudit, events)
+		if err != nil {
+			t.Fatalf("SaveBatch failed: %v", err)
+		}
+
+		expectedExisting := []string{"order-1", "", "", "order-3", "order-2"}
+		for i, result := range results {
+			var duplicate *DuplicateOrderError
+			switch {
+			case expectedExisting[i] == "" && result != nil:
+				t.Errorf("Expected order %d to be stored, got %v", i, result)
+			case expectedExisting[i] != "" && (!errors.As(result, &duplicate) || duplicate.Existing.ID != expectedExisting[i]):
+				t.Errorf("Expected order %d to duplicate %s, got %v", i, expectedExisting[i], result)
+			}
+		}
+
+		stored, err := repo.Get("order-2")
+		if err != nil || stored.Version != 1 || len(stored.Items) != 1 {
+			t.Errorf("Expected order-2 at version 1 with one item, got %+v %v", stored, err)
+		}
+		if _, err := repo.Get("order-4"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected the repeated key not to be stored, got %v", err)
+		}
+	})
+
+	t.Run("UpdateStatusFollowsLifecycle", func(t *testing.T) {
+		repo := newRepo(t)
+		repo.Save(testOrder("order-1"), testAudit, nil)
+
+		previous, err := repo.UpdateStatus("order-1", models.StatusConfirmed, testAudit, statusEvent("order-1"))
+		if err != nil || previous != models.StatusPending {
+			t.Fatalf("Expected pending -> confirmed, got %q %v", previous, err)
+		}
+
+		var transitionErr *models.TransitionError
+		if _, err := repo.UpdateStatus("order-1", models.StatusPending, testAudit, statusEvent("order-1")); !errors.As(err, &transitionErr) {
+			t.Errorf("Expected a transition error, got %v", err)
+		}
+		if _, err := repo.UpdateStatus("missing", models.StatusConfirmed, testAudit, statusEvent("missing")); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("CancellationFlow", func(t *testing.T) {
+		repo := newRepo(t)
+		repo.Save(testOrder("order-1"), testAudit, nil)
+		req := models.CancellationRequest{ReasonCode: models.CancelReasonCustomerRequest}
+		event := Event{Topic: "order.cancelled", Key: "order-1", Payload: req}
+
+		if err := repo.RequestCancellation("order-1", req, time.Now(), testAudit, event); err != nil {
+			t.Fatalf("RequestCancellation failed: %v", err)
+		}
+		if err := repo.RequestCancellation("order-1", req, time.Now(), testAudit, event); !errors.Is(err, ErrCancellationPending) {
+			t.Errorf("Expected ErrCancellationPending, got %v", err)
+		}
+		if _, err := repo.UpdateStatus("order-1", models.StatusConfirmed, testAudit, statusEvent("order-1")); !errors.Is(err, ErrCancellationPending) {
+			t.Errorf("Expected the status to be frozen while SAP decides, got %v", err)
+		}
+
+		// A rejection allows the cancel to be requested again
+		repo.RejectCancellation("order-1", "already shipped", testAudit)
+		stored, _ := repo.Get("order-1")
+		if stored.Cancellation == nil || stored.Cancellation.State != models.CancellationRejected {
+			t.Fatalf("Expected a rejected cancellation, got %+v", stored.Cancellation)
+		}
+		if err := repo.RequestCancellation("order-1", req, time.Now(), testAudit, event); err != nil {
+			t.Fatalf("Expected a second request after rejection, got %v", err)
+		}
+
+		previous, err := repo.ConfirmCancellation("order-1", "cancelled in SAP", testAudit, statusEvent("order-1"))
+		if err != nil || previous != models.StatusPending {
+			t.Fatalf("Expected pending -> cancelled, got %q %v", previous, err)
+		}
+		if previous, err := repo.ConfirmCancellation("order-1", "cancelled in SAP", testAudit, statusEvent("order-1")); err != nil || previous != "" {
+			t.Errorf("Expected a redelivered confirmation to be a no-op, got %q %v", previous, err)
+		}
+
+		stored, _ = repo.Get("order-1")
+		if stored.Status != models.StatusCancelled || stored.Cancellation.State != models.CancellationConfirmed {
+			t.Errorf("Expected a confirmed cancellation, got %s %+v", stored.Status, stored.Cancellation)
+		}
+	})
+
+	t.Run("UpdateChecksVersion", func(t *testing.T) {
+		repo := newRepo(t)
+		repo.Save(testOrder("order-1"), testAudit, nil)
+		replace := func(order *models.Order) (*Event, error) {
+			order.Items = []models.OrderItem{{ProductID: "P-2", Quantity: 1, UnitPrice: 700}}
+			order.TotalAmount = 700
+			return &Event{Topic: "order.updated", Key: order.ID}, nil
+		}
+
+		updated, err := repo.Update("order-1", 1, testAudit, replace)
+		if err != nil || updated.Version != 2 {
+			t.Fatalf("Expected version 2, got %+v %v", updated, err)
+		}
+		stored, _ := repo.Get("order-1")
+		if stored.Version != 2 || len(stored.Items) != 1 || stored.Items[0].ProductID != "P-2" || stored.TotalAmount != 700 {
+			t.Errorf("Expected the replaced items at version 2, got %+v", stored)
+		}
+
+		if _, err := repo.Update("order-1", 1, testAudit, replace); !errors.Is(err, ErrVersionMismatch) {
+			t.Errorf("Expected ErrVersionMismatch for a stale version, got %v", err)
+		}
+		if _, err := repo.Update("missing", 0, testAudit, replace); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+
+		unchanged, err := repo.Update("order-1", 0, testAudit, func(*models.Order) (*Event, error) { return nil, nil })
+		if err != nil || unchanged.Version != 2 {
+			t.Errorf("Expected an unchanged order to stay at version 2, got %+v %v", unchanged, err)
+		}
+
+		repo.UpdateStatus("order-1", models.StatusConfirmed, testAudit, statusEvent("order-1"))
+		if stored, _ := repo.Get("order-1"); stored.Version != 3 {
+			t.Errorf("Expected a status change to bump the version to 3, got %d", stored.Version)
+		}
+	})
+
+	t.Run("HistoryRecordsEveryChange", func(t *testing.T) {
+		repo := newRepo(t)
+		repo.Save(testOrder("order-1"), Audit{Actor: "importer", Source: models.AuditSourceMigration}, nil)
+		repo.SaveBatch([]*models.Order{testOrder("order-2"), testOrder("order-1")}, testAudit, []*Event{nil, nil})
+		repo.Update("order-1", 1, testAudit, func(order *models.Order) (*Event, error) {
+			order.TotalAmount = 1200
+			return &Event{Topic: "order.updated", Key: order.ID}, nil
+		})
+		repo.UpdateStatus("order-1", models.StatusConfirmed, testAudit, statusEvent("order-1"))
+		req := models.CancellationRequest{ReasonCode: models.CancelReasonCustomerRequest}
+		repo.RequestCancellation("order-1", req, time.Now(), testAudit, Event{Topic: "order.cancelled", Key: "order-1"})
+		repo.ConfirmCancellation("order-1", "cancelled in SAP", Audit{Actor: "sap", Source: models.AuditSourceSAP}, statusEvent("order-1"))
+
+		history, err := repo.History("order-1")
+		if err != nil {
+			t.Fatalf("History failed: %v", err)
+		}
+		var actions []string
+		for _, entry := range history {
+			actions = append(actions, entry.Action+" by "+entry.Actor+" via "+entry.Source)
+		}
+		expected := "[created by importer via migration updated by tester via proxy status_changed by tester via proxy " +
+			"cancellation_requested by tester via proxy cancellation_confirmed by sap via sap]"
+		if fmt.Sprint(actions) != expected {
+			t.Fatalf("Expected %s, got %v", expected, actions)
+		}
+
+		if history[0].Before != nil {
+			t.Errorf("Expected no before state for the creation, got %s", history[0].Before)
+		}
+		var before, after models.Order
+		json.Unmarshal(history[1].Before, &before)
+		json.Unmarshal(history[1].After, &after)
+		if before.TotalAmount != 1000 || before.Version != 1 || after.TotalAmount != 1200 || after.Version != 2 {
+			t.Errorf("Expected the update from 10 at version 1 to 12 at version 2, got %s -> %s", history[1].Before, history[1].After)
+		}
+		json.Unmarshal(history[4].After, &after)
+		if after.Status != models.StatusCancelled || after.Cancellation == nil || after.Cancellation.State != models.CancellationConfirmed {
+			t.Errorf("Expected the confirmed cancellation in the last entry, got %s", history[4].After)
+		}
+
+		if history, err := repo.History("order-2"); err != nil || len(history) != 1 || history[0].Source != models.AuditSourceProxy {
+			t.Errorf("Expected the batch creation of order-2, got %+v %v", history, err)
+		}
+		if history, err := repo.History("missing"); err != nil || len(history) != 0 {
+			t.Errorf("Expected no history for a missing order, got %+v %v", history, err)
+		}
+	})
+
+	t.Run("ListFiltersItems", func(t *testing.T) {
+		repo := newRepo(t)
+		red := testOrder("order-red")
+		repo.Save(red, testAudit, nil)
+		blue := testOrder("order-blue")
+		blue.Items = append(blue.Items, models.OrderItem{ProductID: "P-2", Quantity: 1, UnitPrice: 500, Specifications: map[string]string{"color": "blue", "size": "L"}})
+		repo.Save(blue, testAudit, nil)
+
+		tests := []struct {
+			query    models.OrderQuery
+			expected []string
+		}{
+			{models.OrderQuery{ProductID: "P-1"}, []string{"order-red", "order-blue"}},
+			{models.OrderQuery{Specifications: map[string]string{"color": "blue"}}, []string{"order-blue"}},
+			{models.OrderQuery{ProductID: "P-1", Specifications: map[string]string{"color": "blue"}}, nil},
+		}
+		for _, tt := range tests {
+			var ids []string
+			_, err := repo.List(tt.query, func(order *models.Order) error {
+				ids = append(ids, order.ID)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.expected) {
+				t.Errorf("Expected %v for %+v, got %v", tt.expected, tt.query, ids)
+			}
+		}
+	})
+
+	t.Run("SummariesAndAnalytics", func(t *testing.T) {
+		repo := newRepo(t)
+		for i, customer := range []string{"customer-1", "customer-1", "customer-2", "customer-1"} {
+			order := testOrder(fmt.Sprintf("order-%d", i))
+			order.CustomerID = customer
+			order.CreatedAt = order.CreatedAt.Add(time.Duration(i) * 12 * time.Hour)
+			repo.Save(order, testAudit, nil)
+		}
+		euro := testOrder("order-eur")
+		euro.CustomerID = "customer-2"
+		euro.Currency = "EUR"
+		euro.TotalAmount = 5000
+		repo.Save(euro, testAudit, nil)
+		repo.UpdateStatus("order-1", models.StatusCancelled, testAudit, statusEvent("order-1"))
+		window := models.AnalyticsWindow{To: testOrder("").CreatedAt.AddDate(0, 0, 1)}
+
+		summary, err := repo.CustomerSummary("customer-1", window)
+		if err != nil {
+			t.Fatalf("CustomerSummary failed: %v", err)
+		}
+		// order-3 was created after the window
+		if summary.OrderCount != 2 || summary.OrdersByStatus[models.StatusCancelled] != 1 ||
+			len(summary.Revenue) != 1 || summary.RevenueIn(models.DefaultCurrency).Revenue != 1000 {
+			t.Errorf("Expected 2 orders with one cancelled and revenue 10, got %+v", summary)
+		}
+		if summary.ItemCount != 2 || summary.TotalQuantity != 4 || !summary.LastOrderAt.Equal(testOrder("").CreatedAt.Add(12*time.Hour)) {
+			t.Errorf("Expected 2 items of quantity 4, got %+v", summary)
+		}
+
+		analytics, err := repo.Analytics(models.AnalyticsWindow{}, 1)
+		if err != nil {
+			t.Fatalf("Analytics failed: %v", err)
+		}
+		expected := []models.CurrencyRevenue{
+			{Currency: "EUR", OrderCount: 1, Revenue: 5000, AverageOrderValue: 5000},
+			{Currency: "USD", OrderCount: 3, Revenue: 3000, AverageOrderValue: 1000},
+		}
+		if analytics.OrderCount != 5 || !reflect.DeepEqual(analytics.Revenue, expected) {
+			t.Errorf("Expected 5 orders with revenue 50 EUR and 30 USD, got %+v", analytics.OrderTotals)
+		}
+		if len(analytics.RevenueByDay) != 3 || analytics.RevenueByDay[0].Date != "2025-06-01" || analytics.RevenueByDay[0].Currency != "EUR" ||
+			analytics.RevenueByDay[1].Currency != "USD" || analytics.RevenueByDay[1].OrderCount != 1 {
+			t.Errorf("Expected revenue on 2 days in 2 currencies, got %+v", analytics.RevenueByDay)
+		}
+		// customer-2 has the most revenue only if euros and dollars are added
+		if len(analytics.TopCustomers) != 2 ||
+			analytics.TopCustomers[0] != (models.CustomerRevenue{CustomerID: "customer-2", Currency: "EUR", OrderCount: 1, Revenue: 5000}) ||
+			analytics.TopCustomers[1] != (models.CustomerRevenue{CustomerID: "customer-1", Currency: "USD", OrderCount: 2, Revenue: 2000}) {
+			t.Errorf("Expected the top customer of each currency, got %+v", analytics.TopCustomers)
+		}
+	})
+
+	t.Run("ListPages", func(t *testing.T) {
+		repo := newRepo(t)
+		for i := 0; i < 5; i++ {
+			order := testOrder(fmt.Sprintf("order-%d", i))
+			order.CreatedAt = order.CreatedAt.Add(time.Duration(i) * time.Hour)
+			repo.Save(order, testAudit, nil)
+		}
+
+		var ids []string
+		q := models.OrderQuery{Limit: 2}
+		for {
+			next, err := repo.List(q, func(order *models.Order) error {
+				ids = append(ids, order.ID)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if next == "" {
+				break
+			}
+			q.Cursor = next
+		}
+
+		expected := []string{"order-4", "order-3", "order-2", "order-1", "order-0"}
+		if fmt.Sprint(ids) != fmt.Sprint(expected) {
+			t.Errorf("Expected %v, got %v", expected, ids)
+		}
+	})
+}
+
+func TestMemoryRecordsEvents(t *testing.T) {
+	repo := NewMemory()
+	repo.Save(testOrder("order-1"), testAudit, &Event{Topic: "order.created", Key: "order-1"})
+	repo.Save(testOrder("order-2"), testAudit, nil)
+	repo.UpdateStatus("order-1", models.StatusConfirmed, testAudit, statusEvent("order-1"))
+
+	events := repo.Events()
+	if len(events) != 2 || events[0].Topic != "order.created" || events[1].Topic != "order.status_changed" {
+		t.Errorf("Expected created and status changed events, got %+v", events)
+	}
+}
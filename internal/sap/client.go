@@ -3,6 +3,7 @@ package sap
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -14,6 +15,18 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// ErrOrderNotFound is returned when SAP has no order with the requested ID.
+// A missing order is a normal answer, so it does not count as a circuit breaker failure.
+var ErrOrderNotFound = errors.New("order not found in SAP")
+
+// OrderRecord is an order as stored by SAP, with the SAP-side bookkeeping
+// fields next to the order fields.
+type OrderRecord struct {
+	models.Order
+	DocumentID string    `json:"sap_document_id,omitempty"`
+	ReceivedAt time.Time `json:"sap_received_at"`
+}
+
 type Client struct {
 	baseURL        string
 	httpClient     *http.Client
@@ -94,12 +107,13 @@ func (c *Client) CreateOrder(order *models.Order) (*models.OrderResponse, error)
 	return orderResp, nil
 }
 
-func (c *Client) GetOrders() ([]models.Order, error) {
+// GetOrders returns one page of the orders matching the query.
+func (c *Client) GetOrders(query models.OrderQuery) (*models.OrderPage, error) {
 	c.logger.Info("Fetching orders from SAP")
 
-	var orders []models.Order
+	var page *models.OrderPage
 	err := c.circuitBreaker.Execute(func() error {
-		req, err := http.NewRequest("GET", c.baseURL+"/orders", nil)
+		req, err := http.NewRequest("GET", c.baseURL+"/orders?"+query.Values().Encode(), nil)
 		if err != nil {
 			return fmt.Errorf("failed to create request: %w", err)
 		}
@@ -111,9 +125,10 @@ func (c *Client) GetOrders() ([]models.Order, error) {
 		defer resp.Body.Close()
 
 		var response struct {
-			Success bool            `json:"success"`
-			Orders  []models.Order  `json:"orders"`
-			Count   int             `json:"count"`
+			Success    bool           `json:"success"`
+			Orders     []models.Order `json:"orders"`
+			Count      int            `json:"count"`
+			NextCursor string         `json:"next_cursor"`
 		}
 
 		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
@@ -124,7 +139,7 @@ func (c *Client) GetOrders() ([]models.Order, error) {
 			return fmt.Errorf("SAP returned error status: %d", resp.StatusCode)
 		}
 
-		orders = response.Orders
+		page = &models.OrderPage{Orders: response.Orders, NextCursor: response.NextCursor}
 		c.logger.WithField("count", response.Count).Info("Retrieved orders from SAP")
 		return nil
 	})
@@ -137,13 +152,23 @@ func (c *Client) GetOrders() ([]models.Order, error) {
 		return nil, err
 	}
 
-	return orders, nil
+	return page, nil
 }
 
 func (c *Client) GetOrder(orderID string) (*models.Order, error) {
+	record, err := c.GetOrderRecord(orderID)
+	if err != nil {
+		return nil, err
+	}
+	return &record.Order, nil
+}
+
+// GetOrderRecord fetches an order together with its SAP receipt details.
+func (c *Client) GetOrderRecord(orderID string) (*OrderRecord, error) {
 	c.logger.WithField("order_id", orderID).Info("Fetching order from SAP")
 
-	var order *models.Order
+	var record *OrderRecord
+	notFound := false
 	err := c.circuitBreaker.Execute(func() error {
 		req, err := http.NewRequest("GET", c.baseURL+"/orders/"+orderID, nil)
 		if err != nil {
@@ -157,19 +182,20 @@ func (c *Client) GetOrder(orderID string) (*models.Order, error) {
 		defer resp.Body.Close()
 
 		if resp.StatusCode == http.StatusNotFound {
-			return fmt.Errorf("order not found in SAP")
+			notFound = true
+			return nil
 		}
 
 		if resp.StatusCode != http.StatusOK {
 			return fmt.Errorf("SAP returned error status: %d", resp.StatusCode)
 		}
 
-		var orderData models.Order
-		if err := json.NewDecoder(resp.Body).Decode(&orderData); err != nil {
+		var recordData OrderRecord
+		if err := json.NewDecoder(resp.Body).Decode(&recordData); err != nil {
 			return fmt.Errorf("failed to decode SAP response: %w", err)
 		}
 
-		order = &orderData
+		record = &recordData
 		c.logger.WithField("order_id", orderID).Info("Retrieved order from SAP")
 		return nil
 	})
@@ -183,7 +209,48 @@ func (c *Client) GetOrder(orderID string) (*models.Order, error) {
 		return nil, err
 	}
 
-	return order, nil
+	if notFound {
+		return nil, ErrOrderNotFound
+	}
+
+	return record, nil
+}
+
+// VoidOrder removes an order from SAP. It is used to compensate a dual
+// write that could not be completed; an order SAP never stored counts as
+// voided.
+func (c *Client) VoidOrder(orderID string) error {
+	c.logger.WithField("order_id", orderID).Info("Voiding order in SAP")
+
+	err := c.circuitBreaker.Execute(func() error {
+		req, err := http.NewRequest("DELETE", c.baseURL+"/orders/"+orderID, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send request to SAP: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+			return fmt.Errorf("SAP returned error status: %d", resp.StatusCode)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{
+			"order_id": orderID,
+			"error": err.Error(),
+			"circuit_breaker_state": c.circuitBreaker.State().String(),
+		}).Error("Failed to void order in SAP")
+		return err
+	}
+
+	return nil
 }
 
 func getHTTPTimeout(envVar, defaultValue string, logger *logrus.Logger) time.Duration {
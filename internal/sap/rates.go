@@ -0,0 +1,120 @@
+package sap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/jogardn/strangler-demo/pkg/models"
+)
+
+// ErrNoExchangeRate is returned when the rate table has no rate for the
+// currency of an amount.
+var ErrNoExchangeRate = errors.New("no exchange rate")
+
+// Converter converts order amounts into the currency of SAP's company code,
+// the currency SAP books them in.
+type Converter struct {
+	currency string
+	// rates holds the value of one unit of each currency in the base
+	// currency of the table
+	rates map[string]*big.Rat
+}
+
+// rateTableFile is the JSON rate table, e.g.
+//
+//	{"base": "EUR", "rates": {"USD": 0.92, "GBP": 1.17}}
+//
+// Each rate is the value of one unit of the currency in the base currency.
+type rateTableFile struct {
+	Base  string                 `json:"base"`
+	Rates map[string]json.Number `json:"rates"`
+}
+
+// LoadConverter reads the rate table at path. Amounts are converted into
+// currency, or into the base currency of the table if currency is empty.
+// An empty path gives no converter.
+func LoadConverter(path, currency string) (*Converter, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exchange rates: %w", err)
+	}
+	return parseConverter(data, currency)
+}
+
+func parseConverter(data []byte, currency string) (*Converter, error) {
+	var file rateTableFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse exchange rates: %w", err)
+	}
+	if !models.IsCurrencyCode(file.Base) {
+		return nil, fmt.Errorf("exchange rates have invalid base currency %q", file.Base)
+	}
+
+	rates := map[string]*big.Rat{file.Base: big.NewRat(1, 1)}
+	for code, value := range file.Rates {
+		if !models.IsCurrencyCode(code) {
+			return nil, fmt.Errorf("exchange rates have invalid currency %q", code)
+		}
+		rate, ok := new(big.Rat).SetString(value.String())
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("exchange rate for %s must be a positive number, got %q", code, value)
+		}
+		if code == file.Base && rate.Cmp(rates[code]) != 0 {
+			return nil, fmt.Errorf("exchange rate for the base currency %s must be 1, got %q", code, value)
+		}
+		rates[code] = rate
+	}
+
+	if currency == "" {
+		currency = file.Base
+	}
+	if rates[currency] == nil {
+		return nil, fmt.Errorf("%w for company code currency %s", ErrNoExchangeRate, currency)
+	}
+	return &Converter{currency: currency, rates: rates}, nil
+}
+
+// Currency returns the company code currency.
+func (c *Converter) Currency() string {
+	return c.currency
+}
+
+// Convert converts an amount in currency from into the company code
+// currency. The result is rounded half away from zero to the cent.
+func (c *Converter) Convert(amount models.Money, from string) (models.ConvertedAmount, error) {
+	fromRate, ok := c.rates[from]
+	if !ok {
+		return models.ConvertedAmount{}, fmt.Errorf("%w for %s", ErrNoExchangeRate, from)
+	}
+
+	converted := new(big.Rat).SetFrac64(int64(amount), 100)
+	converted.Mul(converted, fromRate)
+	converted.Quo(converted, c.rates[c.currency])
+	// FloatString rounds halves away from zero
+	result, err := models.ParseMoney(converted.FloatString(2))
+	if err != nil {
+		return models.ConvertedAmount{}, fmt.Errorf("failed to convert %s %s: %w", amount, from, err)
+	}
+	return models.ConvertedAmount{Amount: result, Currency: c.currency}, nil
+}
+
+// ConvertTotal converts the total of order into the company code currency.
+// A nil converter has no rate table and converts nothing. The Order Service
+// and SAP both convert with it, so they agree on the amount.
+func (c *Converter) ConvertTotal(order *models.Order) (*models.ConvertedAmount, error) {
+	if c == nil {
+		return nil, nil
+	}
+	converted, err := c.Convert(order.TotalAmount, models.CurrencyOrDefault(order.Currency))
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert the total of order %s: %w", order.ID, err)
+	}
+	return &converted, nil
+}
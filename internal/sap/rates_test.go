@@ -0,0 +1,105 @@
+package sap
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jogardn/strangler-demo/pkg/models"
+)
+
+const testRates = `{"base": "EUR", "rates": {"USD": 0.92, "GBP": "1.17", "JPY": 0.0061}}`
+
+func TestConvert(t *testing.T) {
+	converter, err := parseConverter([]byte(testRates), "")
+	if err != nil {
+		t.Fatalf("Failed to parse rates: %v", err)
+	}
+	if converter.Currency() != "EUR" {
+		t.Errorf("Expected the base currency to be the company code currency, got %s", converter.Currency())
+	}
+
+	tests := []struct {
+		amount models.Money
+		from   string
+		want   models.Money
+	}{
+		{10000, "EUR", 10000},
+		{10000, "USD", 9200},
+		{2599, "GBP", 3041},   // 30.4083
+		{150000, "JPY", 915},  // 9.15
+		{75, "USD", 69},       // 0.69
+		{-2599, "GBP", -3041}, // rounds away from zero
+		{1, "JPY", 0},         // less than half a cent
+		{999_999_999_999_999_999, "EUR", 999_999_999_999_999_999},
+	}
+	for _, tt := range tests {
+		got, err := converter.Convert(tt.amount, tt.from)
+		if err != nil || got.Amount != tt.want || got.Currency != "EUR" {
+			t.Errorf("Convert(%s %s): expected %s EUR, got %s %s (%v)", tt.amount, tt.from, tt.want, got.Amount, got.Currency, err)
+		}
+	}
+
+	if _, err := converter.Convert(100, "CHF"); !errors.Is(err, ErrNoExchangeRate) {
+		t.Errorf("Expected ErrNoExchangeRate for a currency without a rate, got %v", err)
+	}
+}
+
+func TestConvertIntoAnotherCurrency(t *testing.T) {
+	converter, err := parseConverter([]byte(testRates), "USD")
+	if err != nil {
+		t.Fatalf("Failed to parse rates: %v", err)
+	}
+
+	// 100 GBP is 117 EUR, which is 127.17 USD
+	got, err := converter.Convert(10000, "GBP")
+	if err != nil || got.Amount != 12717 || got.Currency != "USD" {
+		t.Errorf("Expected 127.17 USD, got %s %s (%v)", got.Amount, got.Currency, err)
+	}
+}
+
+func TestParseConverterRejectsInvalidTables(t *testing.T) {
+	tests := map[string]struct {
+		data     string
+		currency string
+	}{
+		"not json":               {`rates`, ""},
+		"no base":                {`{"rates": {"USD": 1}}`, ""},
+		"invalid currency":       {`{"base": "EUR", "rates": {"usd": 1}}`, ""},
+		"zero rate":              {`{"base": "EUR", "rates": {"USD": 0}}`, ""},
+		"negative rate":          {`{"base": "EUR", "rates": {"USD": -1}}`, ""},
+		"base rate other than 1": {`{"base": "EUR", "rates": {"EUR": 2}}`, ""},
+		"unknown company code":   {`{"base": "EUR", "rates": {"USD": 1}}`, "GBP"},
+	}
+	for name, tt := range tests {
+		if _, err := parseConverter([]byte(tt.data), tt.currency); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadConverterWithoutPath(t *testing.T) {
+	converter, err := LoadConverter("", "EUR")
+	if converter != nil || err != nil {
+		t.Errorf("Expected no converter without a rate table, got %v %v", converter, err)
+	}
+}
+
+func TestConvertTotal(t *testing.T) {
+	converter, err := parseConverter([]byte(testRates), "")
+	if err != nil {
+		t.Fatalf("Failed to parse rates: %v", err)
+	}
+
+	converted, err := converter.ConvertTotal(&models.Order{ID: "order-1", TotalAmount: 10000})
+	if err != nil || converted == nil || converted.Amount != 9200 || converted.Currency != "EUR" {
+		t.Errorf("Expected an order without a currency to be converted from USD, got %v %v", converted, err)
+	}
+	if _, err := converter.ConvertTotal(&models.Order{ID: "order-1", TotalAmount: 100, Currency: "CHF"}); !errors.Is(err, ErrNoExchangeRate) {
+		t.Errorf("Expected ErrNoExchangeRate for a currency without a rate, got %v", err)
+	}
+
+	var none *Converter
+	if converted, err := none.ConvertTotal(&models.Order{ID: "order-1", TotalAmount: 100}); converted != nil || err != nil {
+		t.Errorf("Expected no conversion without a rate table, got %v %v", converted, err)
+	}
+}
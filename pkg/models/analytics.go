@@ -0,0 +1,265 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultAnalyticsDays is the window of order analytics without from and to.
+const DefaultAnalyticsDays = 30
+
+// Number of customers OrderAnalytics.TopCustomers ranks in each currency.
+const (
+	DefaultTopCustomers = 10
+	MaxTopCustomers     = 100
+)
+
+// AnalyticsWindow selects orders by created_at. The start is inclusive, the
+// end exclusive; a zero bound is open.
+type AnalyticsWindow struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// MarshalJSON leaves out open bounds.
+func (w AnalyticsWindow) MarshalJSON() ([]byte, error) {
+	bounds := make(map[string]time.Time)
+	if !w.From.IsZero() {
+		bounds["from"] = w.From
+	}
+	if !w.To.IsZero() {
+		bounds["to"] = w.To
+	}
+	return json.Marshal(bounds)
+}
+
+// ParseAnalyticsWindow reads the from and to parameters (RFC 3339). If
+// defaultDays is positive, a missing from is set defaultDays before to,
+// and a missing to is now.
+func ParseAnalyticsWindow(values url.Values, defaultDays int, now time.Time) (AnalyticsWindow, error) {
+	var window AnalyticsWindow
+	for _, param := range []struct {
+		name   string
+		target *time.Time
+	}{
+		{"from", &window.From},
+		{"to", &window.To},
+	} {
+		value := values.Get(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return window, fmt.Errorf("%s must be an RFC 3339 timestamp", param.name)
+		}
+		*param.target = t.UTC()
+	}
+
+	if defaultDays > 0 {
+		if window.To.IsZero() {
+			window.To = now.UTC()
+		}
+		if window.From.IsZero() {
+			window.From = window.To.AddDate(0, 0, -defaultDays)
+		}
+	}
+	if !window.From.IsZero() && !window.To.IsZero() && !window.From.Before(window.To) {
+		return window, fmt.Errorf("from must be before to")
+	}
+	return window, nil
+}
+
+// Values encodes the window as URL parameters, the inverse of
+// ParseAnalyticsWindow.
+func (w AnalyticsWindow) Values() url.Values {
+	values := url.Values{}
+	if !w.From.IsZero() {
+		values.Set("from", w.From.Format(time.RFC3339))
+	}
+	if !w.To.IsZero() {
+		values.Set("to", w.To.Format(time.RFC3339))
+	}
+	return values
+}
+
+// Contains reports whether t is in the window.
+func (w AnalyticsWindow) Contains(t time.Time) bool {
+	return inRange(t, w.From, w.To)
+}
+
+// ParseTopCustomers reads the top parameter: how many customers to rank in
+// each currency.
+func ParseTopCustomers(values url.Values) (int, error) {
+	value := values.Get("top")
+	if value == "" {
+		return DefaultTopCustomers, nil
+	}
+	top, err := strconv.Atoi(value)
+	if err != nil || top < 0 || top > MaxTopCustomers {
+		return 0, fmt.Errorf("top must be between 0 and %d", MaxTopCustomers)
+	}
+	return top, nil
+}
+
+// NonRevenueStatuses are the statuses of orders that do not count towards
+// revenue.
+var NonRevenueStatuses = []string{StatusCancelled, StatusRejected}
+
+// CountsAsRevenue reports whether orders in status count towards revenue.
+func CountsAsRevenue(status string) bool {
+	for _, excluded := range NonRevenueStatuses {
+		if status == excluded {
+			return false
+		}
+	}
+	return true
+}
+
+// CurrencyRevenue is the revenue of the orders in one currency. Amounts in
+// different currencies are never added together.
+type CurrencyRevenue struct {
+	Currency          string `json:"currency"`
+	OrderCount        int    `json:"order_count"`
+	Revenue           Money  `json:"revenue"`
+	AverageOrderValue Money  `json:"average_order_value"`
+}
+
+// Add counts orders whose total amounts sum to amount.
+func (c *CurrencyRevenue) Add(orders int, amount Money) {
+	c.OrderCount += orders
+	c.Revenue += amount
+	if c.OrderCount > 0 {
+		c.AverageOrderValue = c.Revenue.DividedBy(c.OrderCount)
+	}
+}
+
+// OrderTotals sums a set of orders. Every order is counted by status, but
+// Revenue only includes orders that count as revenue, with one entry per
+// currency sorted by currency.
+type OrderTotals struct {
+	OrderCount     int               `json:"order_count"`
+	OrdersByStatus map[string]int    `json:"orders_by_status"`
+	Revenue        []CurrencyRevenue `json:"revenue"`
+}
+
+// Add counts orders in a status and currency whose total amounts sum to
+// amount.
+func (t *OrderTotals) Add(status, currency string, orders int, amount Money) {
+	if t.OrdersByStatus == nil {
+		t.OrdersByStatus = make(map[string]int)
+	}
+	t.OrderCount += orders
+	t.OrdersByStatus[status] += orders
+	if !CountsAsRevenue(status) {
+		return
+	}
+
+	currency = CurrencyOrDefault(currency)
+	i := sort.Search(len(t.Revenue), func(i int) bool { return t.Revenue[i].Currency >= currency })
+	if i == len(t.Revenue) || t.Revenue[i].Currency != currency {
+		t.Revenue = append(t.Revenue, CurrencyRevenue{})
+		copy(t.Revenue[i+1:], t.Revenue[i:])
+		t.Revenue[i] = CurrencyRevenue{Currency: currency}
+	}
+	t.Revenue[i].Add(orders, amount)
+}
+
+// RevenueIn returns the revenue in currency, which is zero without orders
+// in it.
+func (t *OrderTotals) RevenueIn(currency string) CurrencyRevenue {
+	for _, revenue := range t.Revenue {
+		if revenue.Currency == currency {
+			return revenue
+		}
+	}
+	return CurrencyRevenue{Currency: currency}
+}
+
+// CustomerOrderSummary sums the orders of one customer in a window.
+type CustomerOrderSummary struct {
+	CustomerID string          `json:"customer_id"`
+	Window     AnalyticsWindow `json:"window"`
+	OrderTotals
+	ItemCount     int        `json:"item_count"`
+	TotalQuantity int        `json:"total_quantity"`
+	FirstOrderAt  *time.Time `json:"first_order_at,omitempty"`
+	LastOrderAt   *time.Time `json:"last_order_at,omitempty"`
+}
+
+func NewCustomerOrderSummary(customerID string, window AnalyticsWindow) *CustomerOrderSummary {
+	return &CustomerOrderSummary{
+		CustomerID:  customerID,
+		Window:      window,
+		OrderTotals: OrderTotals{OrdersByStatus: make(map[string]int), Revenue: []CurrencyRevenue{}},
+	}
+}
+
+// AddOrders adds the orders of a status and currency with their item
+// counts and the creation times of the first and last of them.
+func (s *CustomerOrderSummary) AddOrders(status, currency string, orders int, amount Money, items, quantity int, first, last time.Time) {
+	s.Add(status, currency, orders, amount)
+	s.ItemCount += items
+	s.TotalQuantity += quantity
+	if s.FirstOrderAt == nil || first.Before(*s.FirstOrderAt) {
+		s.FirstOrderAt = &first
+	}
+	if s.LastOrderAt == nil || last.After(*s.LastOrderAt) {
+		s.LastOrderAt = &last
+	}
+}
+
+// DailyRevenue is the revenue in one currency of the orders created on a
+// UTC day.
+type DailyRevenue struct {
+	// Date is formatted as 2006-01-02
+	Date       string `json:"date"`
+	Currency   string `json:"currency"`
+	OrderCount int    `json:"order_count"`
+	Revenue    Money  `json:"revenue"`
+}
+
+// Add counts orders of the day whose total amounts sum to amount.
+func (d *DailyRevenue) Add(orders int, amount Money) {
+	d.OrderCount += orders
+	d.Revenue += amount
+}
+
+// CustomerRevenue is a customer's share of the revenue in one currency.
+type CustomerRevenue struct {
+	CustomerID string `json:"customer_id"`
+	Currency   string `json:"currency"`
+	OrderCount int    `json:"order_count"`
+	Revenue    Money  `json:"revenue"`
+}
+
+// Add counts orders of the customer whose total amounts sum to amount.
+func (c *CustomerRevenue) Add(orders int, amount Money) {
+	c.OrderCount += orders
+	c.Revenue += amount
+}
+
+// OrderAnalytics sums all orders in a window. RevenueByDay has an entry per
+// day and currency, sorted by day and then currency, and omits days without
+// revenue. TopCustomers ranks customers by revenue within each currency:
+// it holds the top customers of every currency, sorted by currency and then
+// by revenue.
+type OrderAnalytics struct {
+	Window AnalyticsWindow `json:"window"`
+	OrderTotals
+	RevenueByDay []DailyRevenue    `json:"revenue_by_day"`
+	TopCustomers []CustomerRevenue `json:"top_customers"`
+}
+
+func NewOrderAnalytics(window AnalyticsWindow) *OrderAnalytics {
+	return &OrderAnalytics{
+		Window:       window,
+		OrderTotals:  OrderTotals{OrdersByStatus: make(map[string]int), Revenue: []CurrencyRevenue{}},
+		RevenueByDay: []DailyRevenue{},
+		TopCustomers: []CustomerRevenue{},
+	}
+}
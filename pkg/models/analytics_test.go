@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAnalyticsWindow(t *testing.T) {
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	window, err := ParseAnalyticsWindow(url.Values{}, 30, now)
+	if err != nil || !window.To.Equal(now) || !window.From.Equal(now.AddDate(0, 0, -30)) {
+		t.Errorf("Expected the last 30 days, got %+v %v", window, err)
+	}
+
+	window, err = ParseAnalyticsWindow(url.Values{"from": {"2025-06-01T00:00:00+02:00"}}, 0, now)
+	if err != nil || !window.From.Equal(time.Date(2025, 5, 31, 22, 0, 0, 0, time.UTC)) || !window.To.IsZero() {
+		t.Errorf("Expected an open end, got %+v %v", window, err)
+	}
+	data, _ := json.Marshal(window)
+	if string(data) != `{"from":"2025-05-31T22:00:00Z"}` {
+		t.Errorf("Expected the open bound to be left out, got %s", data)
+	}
+
+	for _, values := range []url.Values{
+		{"from": {"yesterday"}},
+		{"from": {"2025-06-02T00:00:00Z"}, "to": {"2025-06-01T00:00:00Z"}},
+	} {
+		if _, err := ParseAnalyticsWindow(values, 30, now); err == nil {
+			t.Errorf("Expected %v to be rejected", values)
+		}
+	}
+}
+
+func TestOrderTotalsExcludeCancelledRevenue(t *testing.T) {
+	var totals OrderTotals
+	totals.Add(StatusPending, "", 2, 3010)
+	totals.Add(StatusDelivered, "USD", 1, 20)
+	totals.Add(StatusCancelled, "USD", 4, 100000)
+
+	if totals.OrderCount != 7 || totals.OrdersByStatus[StatusCancelled] != 4 {
+		t.Errorf("Expected every order to be counted, got %+v", totals)
+	}
+	if revenue := totals.RevenueIn("USD"); len(totals.Revenue) != 1 || revenue.Revenue != 3030 || revenue.AverageOrderValue != 1010 {
+		t.Errorf("Expected revenue 30.30 and average 10.10, got %+v", totals.Revenue)
+	}
+}
+
+func TestOrderTotalsKeepCurrenciesApart(t *testing.T) {
+	var totals OrderTotals
+	totals.Add(StatusPending, "USD", 1, 1000)
+	totals.Add(StatusPending, "EUR", 1, 3000)
+	totals.Add(StatusDelivered, "USD", 1, 2000)
+
+	expected := []CurrencyRevenue{
+		{Currency: "EUR", OrderCount: 1, Revenue: 3000, AverageOrderValue: 3000},
+		{Currency: "USD", OrderCount: 2, Revenue: 3000, AverageOrderValue: 1500},
+	}
+	if !reflect.DeepEqual(totals.Revenue, expected) {
+		t.Errorf("Expected revenue per currency %+v, got %+v", expected, totals.Revenue)
+	}
+	if revenue := totals.RevenueIn("GBP"); revenue.Revenue != 0 || revenue.OrderCount != 0 {
+		t.Errorf("Expected no revenue in GBP, got %+v", revenue)
+	}
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Sources of order changes recorded in the audit trail.
+const (
+	// AuditSourceProxy is a live change the proxy sent to the Order Service
+	AuditSourceProxy = "proxy"
+	// AuditSourceAPI is a live change sent to the Order Service's REST API
+	// by anything other than the proxy
+	AuditSourceAPI = "api"
+	// AuditSourceGRPC is a live change sent to the Order Service's gRPC API
+	// by anything other than the proxy
+	AuditSourceGRPC = "grpc"
+	// AuditSourceMigration is an order copied by the data migrator
+	AuditSourceMigration = "migration"
+	// AuditSourceHistoricalImport is an order stored without an event
+	// through the historical endpoint by anything else
+	AuditSourceHistoricalImport = "historical_import"
+	// AuditSourceShadow is a copy of an order SAP created in the shadow
+	// phase, stored through the historical endpoint. The proxy's own shadow
+	// writes are dry runs and store nothing
+	AuditSourceShadow = "shadow"
+	// AuditSourceDualWrite is the Order Service write of a dual-write saga
+	AuditSourceDualWrite = "dual_write"
+	// AuditSourceSAP is a change applied from SAP's answer to a request
+	AuditSourceSAP = "sap"
+)
+
+// Actions recorded in the audit trail.
+const (
+	AuditActionCreated               = "created"
+	AuditActionUpdated               = "updated"
+	AuditActionStatusChanged         = "status_changed"
+	AuditActionCancellationRequested = "cancellation_requested"
+	AuditActionCancellationRejected  = "cancellation_rejected"
+	AuditActionCancellationConfirmed = "cancellation_confirmed"
+)
+
+// AnonymousActor is recorded for changes whose request named no actor.
+const AnonymousActor = "anonymous"
+
+// ParseImportSource checks the source of a historical write, which is
+// AuditSourceHistoricalImport unless it says otherwise.
+func ParseImportSource(source string) (string, error) {
+	switch source {
+	case "":
+		return AuditSourceHistoricalImport, nil
+	case AuditSourceHistoricalImport, AuditSourceMigration, AuditSourceShadow, AuditSourceDualWrite:
+		return source, nil
+	}
+	return "", fmt.Errorf("unknown source %q: historical orders come from %s, %s, %s or %s",
+		source, AuditSourceHistoricalImport, AuditSourceMigration, AuditSourceShadow, AuditSourceDualWrite)
+}
+
+// AuditEntry is one change to an order. Before and After are the order as
+// stored; Before is null for the change that created it.
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	OrderID   string          `json:"order_id"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	Source    string          `json:"source"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// OrderHistoryResponse is the body of GET /orders/{id}/history, oldest
+// change first.
+type OrderHistoryResponse struct {
+	Success bool         `json:"success"`
+	OrderID string       `json:"order_id"`
+	History []AuditEntry `json:"history"`
+}
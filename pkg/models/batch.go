@@ -0,0 +1,76 @@
+package models
+
+import "net/http"
+
+// MaxBatchSize is the most orders POST /orders/batch accepts.
+const MaxBatchSize = 500
+
+// BatchOrderRequest is the body of POST /orders/batch.
+type BatchOrderRequest struct {
+	Orders []Order `json:"orders"`
+}
+
+// BatchOrderResult is the outcome of one order of a batch. Status is the
+// HTTP status the order would have got from POST /orders on its own, e.g.
+// 201 when it was created, 200 when it already existed and 422 when it is
+// invalid.
+type BatchOrderResult struct {
+	// Index is the position of the order in the request
+	Index   int    `json:"index"`
+	OrderID string `json:"order_id,omitempty"`
+	Status  int    `json:"status"`
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	// Errors lists the validation problems of an invalid order
+	Errors interface{} `json:"errors,omitempty"`
+	Order  *Order      `json:"order,omitempty"`
+}
+
+// BatchOrderResponse reports every order of a batch. Success is true only
+// if every order succeeded.
+type BatchOrderResponse struct {
+	Success bool               `json:"success"`
+	Message string             `json:"message"`
+	Total   int                `json:"total"`
+	Created int                `json:"created"`
+	Existed int                `json:"existed"`
+	Failed  int                `json:"failed"`
+	Results []BatchOrderResult `json:"results"`
+}
+
+// Retryable reports whether an order of the batch failed on the server's
+// side, e.g. because a backend was down or its circuit breaker open. Such
+// a response is not stored for its Idempotency-Key, so a retry with the
+// same key runs the batch again; the orders that were created keep the IDs
+// derived from the key and are reported as existing.
+func (r *BatchOrderResponse) Retryable() bool {
+	for _, result := range r.Results {
+		if result.Status >= http.StatusInternalServerError {
+			return true
+		}
+	}
+	return false
+}
+
+// NewBatchOrderResponse counts the outcomes of a batch.
+func NewBatchOrderResponse(results []BatchOrderResult) *BatchOrderResponse {
+	response := &BatchOrderResponse{Total: len(results), Results: results}
+	for _, result := range results {
+		switch {
+		case !result.Success:
+			response.Failed++
+		case result.Status == http.StatusOK:
+			response.Existed++
+		default:
+			response.Created++
+		}
+	}
+
+	response.Success = response.Failed == 0
+	if response.Success {
+		response.Message = "All orders processed successfully"
+	} else {
+		response.Message = "Some orders failed; see results"
+	}
+	return response
+}
@@ -0,0 +1,187 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in cents, so sums and comparisons are exact. It is
+// written to JSON as a decimal number such as 25.99, like the float64
+// amounts it replaced, and stored in NUMERIC columns.
+type Money int64
+
+// DefaultCurrency is the currency of orders that do not name one, which
+// includes every order stored before orders had a currency.
+const DefaultCurrency = "USD"
+
+// ConvertedAmount is an amount converted into another currency.
+type ConvertedAmount struct {
+	Amount   Money  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// IsCurrencyCode reports whether code has the form of an ISO 4217 currency
+// code: three uppercase letters.
+func IsCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// CurrencyOrDefault returns code, or DefaultCurrency if it is empty.
+func CurrencyOrDefault(code string) string {
+	if code == "" {
+		return DefaultCurrency
+	}
+	return code
+}
+
+// maxMoneyExponent bounds the exponent ParseMoney accepts. Amounts that
+// need more are out of range anyway.
+const maxMoneyExponent = 30
+
+// ParseMoney reads a decimal amount such as "25.99", "-3" or "1.5e3".
+// Fractions of a cent are rounded half away from zero, as clients sending
+// binary floating point amounts expect.
+func ParseMoney(s string) (Money, error) {
+	mantissa, exponent := s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(strings.TrimPrefix(s[i+1:], "+"))
+		if err != nil || e < -maxMoneyExponent || e > maxMoneyExponent {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+		mantissa, exponent = s[:i], e
+	}
+
+	negative := strings.HasPrefix(mantissa, "-")
+	whole, fraction, _ := strings.Cut(strings.TrimPrefix(mantissa, "-"), ".")
+	digits := whole + fraction
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	cents, _ := new(big.Int).SetString(digits, 10)
+	if shift := exponent - len(fraction) + 2; shift >= 0 {
+		cents.Mul(cents, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(shift)), nil))
+	} else {
+		divisor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-shift)), nil)
+		var remainder big.Int
+		cents.QuoRem(cents, divisor, &remainder)
+		if remainder.Lsh(&remainder, 1).Cmp(divisor) >= 0 {
+			cents.Add(cents, big.NewInt(1))
+		}
+	}
+	if negative {
+		cents.Neg(cents)
+	}
+	if !cents.IsInt64() {
+		return 0, fmt.Errorf("amount %s is out of range", s)
+	}
+	return Money(cents.Int64()), nil
+}
+
+// MoneyFromFloat rounds a float64 amount to the cent.
+func MoneyFromFloat(amount float64) Money {
+	return Money(math.Round(amount * 100))
+}
+
+// Times returns the amount multiplied by quantity.
+func (m Money) Times(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// DividedBy returns the amount divided by n, rounded half away from zero to
+// the cent.
+func (m Money) DividedBy(n int) Money {
+	quotient, remainder := m/Money(n), m%Money(n)
+	if remainder < 0 {
+		remainder = -remainder
+	}
+	if 2*remainder >= Money(n) {
+		if m < 0 {
+			quotient--
+		} else {
+			quotient++
+		}
+	}
+	return quotient
+}
+
+// Float64 returns the amount in whole units, for display and statistics.
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+// String formats the amount with two decimals, e.g. 25.99.
+func (m Money) String() string {
+	sign := ""
+	cents := uint64(m)
+	if m < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON reads a JSON number. Like a float64, the amount is left
+// unchanged by null.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	amount, err := ParseMoney(string(data))
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
+
+// Value stores the amount as a decimal string, which Postgres converts to
+// NUMERIC exactly.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan reads a NUMERIC column, which the driver returns as text.
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return m.parse(string(v))
+	case string:
+		return m.parse(v)
+	case int64:
+		*m = Money(v) * 100
+		return nil
+	case float64:
+		*m = MoneyFromFloat(v)
+		return nil
+	case nil:
+		*m = 0
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into Money", src)
+}
+
+func (m *Money) parse(s string) error {
+	amount, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := map[string]Money{
+		"25.99":  2599,
+		"-3":     -300,
+		"0.1":    10,
+		"1.5e3":  150000,
+		"12.345": 1235,
+		"-0.005": -1,
+		"0.004":  0,
+		"1E-2":   1,
+	}
+	for input, want := range tests {
+		got, err := ParseMoney(input)
+		if err != nil || got != want {
+			t.Errorf("ParseMoney(%q): expected %d, got %d (%v)", input, want, got, err)
+		}
+	}
+
+	for _, input := range []string{"", "-", "1.2.3", "abc", "1e", "1e999", "99999999999999999999"} {
+		if _, err := ParseMoney(input); err == nil {
+			t.Errorf("ParseMoney(%q): expected an error", input)
+		}
+	}
+}
+
+func TestMoneyJSONIsADecimalNumber(t *testing.T) {
+	var item OrderItem
+	if err := json.Unmarshal([]byte(`{"unit_price": 0.1, "quantity": 3}`), &item); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	total := item.UnitPrice.Times(item.Quantity)
+	if total != 30 {
+		t.Errorf("Expected 0.1 * 3 to be exactly 0.30, got %s", total)
+	}
+
+	data, err := json.Marshal(map[string]Money{"amount": -1205})
+	if err != nil || string(data) != `{"amount":-12.05}` {
+		t.Errorf("Expected -12.05, got %s (%v)", data, err)
+	}
+
+	amount := Money(100)
+	if err := json.Unmarshal([]byte("null"), &amount); err != nil || amount != 100 {
+		t.Errorf("Expected null to leave the amount unchanged, got %s (%v)", amount, err)
+	}
+	if err := json.Unmarshal([]byte(`"1.00"`), &amount); err == nil {
+		t.Error("Expected a string amount to be rejected")
+	}
+}
+
+func TestMoneyDividedByRoundsHalfAwayFromZero(t *testing.T) {
+	tests := []struct {
+		amount Money
+		n      int
+		want   Money
+	}{
+		{1000, 3, 333},
+		{1000, 6, 167},
+		{5, 2, 3},
+		{-5, 2, -3},
+		{-1000, 3, -333},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.DividedBy(tt.n); got != tt.want {
+			t.Errorf("%s / %d: expected %s, got %s", tt.amount, tt.n, tt.want, got)
+		}
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		src  interface{}
+		want Money
+	}{
+		{[]byte("99999999.99"), 9999999999},
+		{"0.50", 50},
+		{int64(7), 700},
+		{12.34, 1234},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		var m Money
+		if err := m.Scan(tt.src); err != nil || m != tt.want {
+			t.Errorf("Scan(%v): expected %s, got %s (%v)", tt.src, tt.want, m, err)
+		}
+	}
+
+	var m Money
+	if err := m.Scan(true); err == nil {
+		t.Error("Expected scanning a bool to fail")
+	}
+}
+
+func TestIsCurrencyCode(t *testing.T) {
+	for _, code := range []string{"USD", "EUR", "JPY"} {
+		if !IsCurrencyCode(code) {
+			t.Errorf("Expected %q to be a currency code", code)
+		}
+	}
+	for _, code := range []string{"", "usd", "US", "USDT", "U5D", "ÄUD"} {
+		if IsCurrencyCode(code) {
+			t.Errorf("Expected %q not to be a currency code", code)
+		}
+	}
+	if CurrencyOrDefault("") != DefaultCurrency || CurrencyOrDefault("EUR") != "EUR" {
+		t.Error("Expected only an empty currency to default")
+	}
+}
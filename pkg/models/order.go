@@ -8,16 +8,29 @@ type Order struct {
 	ID           string      `json:"id"`
 	CustomerID   string      `json:"customer_id"`
 	Items        []OrderItem `json:"items"`
-	TotalAmount  float64     `json:"total_amount"`
+	TotalAmount  Money       `json:"total_amount"`
+	Currency     string      `json:"currency"`
 	DeliveryDate time.Time   `json:"delivery_date"`
 	Status       string      `json:"status"`
 	CreatedAt    time.Time   `json:"created_at"`
+	// CompanyCodeAmount is set by SAP, which books TotalAmount converted into
+	// the currency of its company code
+	CompanyCodeAmount *ConvertedAmount `json:"company_code_amount,omitempty"`
+	// Version counts the changes to the order and is sent as its ETag
+	Version int `json:"version,omitempty"`
+	// Cancellation is set once a cancel has been requested
+	Cancellation *Cancellation `json:"cancellation,omitempty"`
+	// IdempotencyKey travels as the Idempotency-Key header, not in the body
+	IdempotencyKey string `json:"-"`
+	// Actor names who sent the order for the audit trail. It travels as the
+	// X-Actor header
+	Actor string `json:"-"`
 }
 
 type OrderItem struct {
 	ProductID      string            `json:"product_id"`
 	Quantity       int               `json:"quantity"`
-	UnitPrice      float64           `json:"unit_price"`
+	UnitPrice      Money             `json:"unit_price"`
 	Specifications map[string]string `json:"specifications"`
 }
 
@@ -25,4 +38,11 @@ type OrderResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
 	Order   *Order `json:"order,omitempty"`
-}
\ No newline at end of file
+	// SyncState is SyncStatePending when SAP accepted the order but the
+	// dual write has not reached the Order Service yet
+	SyncState string `json:"sync_state,omitempty"`
+}
+
+// SyncStatePending marks an order whose dual-write saga is still retrying.
+// The order may yet be voided in SAP if every retry fails.
+const SyncStatePending = "pending"
\ No newline at end of file
@@ -0,0 +1,445 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page sizes for order listings.
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// Sort fields for order listings. Prefix a field with "-" to sort descending.
+const (
+	SortCreatedAt    = "created_at"
+	SortDeliveryDate = "delivery_date"
+	SortTotalAmount  = "total_amount"
+)
+
+// DefaultOrderSort lists the newest orders first.
+const DefaultOrderSort = "-" + SortCreatedAt
+
+// ErrInvalidCursor is returned for a cursor that cannot be decoded or was
+// issued for a different sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// specificationParamPrefix prefixes the URL parameters that match item
+// specifications, e.g. specifications.color=blue.
+const specificationParamPrefix = "specifications."
+
+// OrderQuery filters, sorts and pages an order listing. Zero values mean no
+// filter; a zero Limit means DefaultPageSize.
+type OrderQuery struct {
+	CustomerID   string
+	Status       string
+	CreatedFrom  time.Time
+	CreatedTo    time.Time
+	DeliveryFrom time.Time
+	DeliveryTo   time.Time
+	// ProductID and Specifications match orders with an item of the
+	// product that has all the specifications
+	ProductID      string
+	Specifications map[string]string
+	Sort           string
+	Limit          int
+	Cursor         string
+}
+
+// OrderPage is one page of an order listing. NextCursor is empty on the last
+// page.
+type OrderPage struct {
+	Orders     []Order
+	NextCursor string
+}
+
+// OrderCursor marks the last order of a page: its sort value and ID.
+type OrderCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// ParseOrderQuery reads an order query from URL parameters:
+// customer_id, status, created_from, created_to, delivery_from, delivery_to
+// (RFC 3339), product_id, specifications.<key>, sort, limit and cursor.
+func ParseOrderQuery(values url.Values) (OrderQuery, error) {
+	q := OrderQuery{
+		CustomerID: values.Get("customer_id"),
+		Status:     values.Get("status"),
+		ProductID:  values.Get("product_id"),
+		Sort:       values.Get("sort"),
+		Cursor:     values.Get("cursor"),
+	}
+
+	for name := range values {
+		if !strings.HasPrefix(name, specificationParamPrefix) {
+			continue
+		}
+		key := strings.TrimPrefix(name, specificationParamPrefix)
+		if key == "" {
+			return q, fmt.Errorf("%s must be followed by a specification key", specificationParamPrefix)
+		}
+		if q.Specifications == nil {
+			q.Specifications = make(map[string]string)
+		}
+		q.Specifications[key] = values.Get(name)
+	}
+
+	times := []struct {
+		name   string
+		target *time.Time
+	}{
+		{"created_from", &q.CreatedFrom},
+		{"created_to", &q.CreatedTo},
+		{"delivery_from", &q.DeliveryFrom},
+		{"delivery_to", &q.DeliveryTo},
+	}
+	for _, param := range times {
+		value := values.Get(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return q, fmt.Errorf("%s must be an RFC 3339 timestamp", param.name)
+		}
+		*param.target = t.UTC()
+	}
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > MaxPageSize {
+			return q, fmt.Errorf("limit must be between 1 and %d", MaxPageSize)
+		}
+		q.Limit = limit
+	}
+
+	return q, q.Validate()
+}
+
+// Validate checks the status, limit, sort and cursor of a query, for queries
+// that do not come from ParseOrderQuery. A zero limit means DefaultPageSize.
+func (q OrderQuery) Validate() error {
+	if q.Status != "" && !IsValidStatus(q.Status) {
+		return fmt.Errorf("unknown status %q", q.Status)
+	}
+	if q.Limit < 0 || q.Limit > MaxPageSize {
+		return fmt.Errorf("limit must be between 1 and %d", MaxPageSize)
+	}
+	if _, _, err := q.SortField(); err != nil {
+		return err
+	}
+	if _, err := q.DecodeCursor(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ParseOrderSearch reads an order search: an order query that filters by
+// product_id or at least one specification.
+func ParseOrderSearch(values url.Values) (OrderQuery, error) {
+	q, err := ParseOrderQuery(values)
+	if err != nil {
+		return q, err
+	}
+	if !q.FiltersItems() {
+		return q, fmt.Errorf("search requires product_id or a specification such as %scolor=blue", specificationParamPrefix)
+	}
+	return q, nil
+}
+
+// Values encodes the query as URL parameters, the inverse of ParseOrderQuery.
+func (q OrderQuery) Values() url.Values {
+	values := url.Values{}
+	set := func(name, value string) {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+	setTime := func(name string, t time.Time) {
+		if !t.IsZero() {
+			values.Set(name, t.Format(time.RFC3339))
+		}
+	}
+
+	set("customer_id", q.CustomerID)
+	set("status", q.Status)
+	setTime("created_from", q.CreatedFrom)
+	setTime("created_to", q.CreatedTo)
+	setTime("delivery_from", q.DeliveryFrom)
+	setTime("delivery_to", q.DeliveryTo)
+	set("product_id", q.ProductID)
+	for key, value := range q.Specifications {
+		values.Set(specificationParamPrefix+key, value)
+	}
+	set("sort", q.Sort)
+	if q.Limit > 0 {
+		values.Set("limit", strconv.Itoa(q.Limit))
+	}
+	set("cursor", q.Cursor)
+	return values
+}
+
+// PageSize returns the limit, or DefaultPageSize if none was set.
+func (q OrderQuery) PageSize() int {
+	if q.Limit <= 0 {
+		return DefaultPageSize
+	}
+	if q.Limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return q.Limit
+}
+
+// SortField returns the field to sort by and whether the sort is descending.
+func (q OrderQuery) SortField() (string, bool, error) {
+	sortBy := q.Sort
+	if sortBy == "" {
+		sortBy = DefaultOrderSort
+	}
+
+	descending := strings.HasPrefix(sortBy, "-")
+	field := strings.TrimPrefix(sortBy, "-")
+	switch field {
+	case SortCreatedAt, SortDeliveryDate, SortTotalAmount:
+		return field, descending, nil
+	}
+	return "", false, fmt.Errorf("unknown sort %q (allowed: created_at, delivery_date, total_amount, prefixed with - for descending)", q.Sort)
+}
+
+// Matches reports whether an order passes the query's filters. Ranges
+// include their start and exclude their end.
+func (q OrderQuery) Matches(order *Order) bool {
+	if q.CustomerID != "" && order.CustomerID != q.CustomerID {
+		return false
+	}
+	if q.Status != "" && order.Status != q.Status {
+		return false
+	}
+	if !inRange(order.CreatedAt, q.CreatedFrom, q.CreatedTo) ||
+		!inRange(order.DeliveryDate, q.DeliveryFrom, q.DeliveryTo) {
+		return false
+	}
+	if !q.FiltersItems() {
+		return true
+	}
+	for _, item := range order.Items {
+		if q.matchesItem(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// FiltersItems reports whether the query filters by product or
+// specifications.
+func (q OrderQuery) FiltersItems() bool {
+	return q.ProductID != "" || len(q.Specifications) > 0
+}
+
+func (q OrderQuery) matchesItem(item OrderItem) bool {
+	if q.ProductID != "" && item.ProductID != q.ProductID {
+		return false
+	}
+	for key, value := range q.Specifications {
+		if spec, ok := item.Specifications[key]; !ok || spec != value {
+			return false
+		}
+	}
+	return true
+}
+
+func inRange(t, from, to time.Time) bool {
+	if !from.IsZero() && t.Before(from) {
+		return false
+	}
+	if !to.IsZero() && !t.Before(to) {
+		return false
+	}
+	return true
+}
+
+// DecodeCursor returns the position the page starts after, or nil for the
+// first page.
+func (q OrderQuery) DecodeCursor() (*OrderCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor OrderCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	field, descending, err := q.SortField()
+	if err != nil {
+		return nil, err
+	}
+	if cursor.Sort != sortKey(field, descending) {
+		return nil, fmt.Errorf("%w: cursor was issued for sort %q", ErrInvalidCursor, cursor.Sort)
+	}
+	if _, err := cursor.position(field); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// CursorAfter returns the cursor that continues a listing after the order.
+func (q OrderQuery) CursorAfter(order *Order) string {
+	field, descending, _ := q.SortField()
+
+	var value string
+	switch field {
+	case SortDeliveryDate:
+		value = order.DeliveryDate.UTC().Format(time.RFC3339Nano)
+	case SortTotalAmount:
+		value = order.TotalAmount.String()
+	default:
+		value = order.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	data, _ := json.Marshal(OrderCursor{Sort: sortKey(field, descending), Value: value, ID: order.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// SortValue returns the cursor's sort value typed for the field: a time.Time
+// or Money.
+func (c *OrderCursor) SortValue(field string) interface{} {
+	position, _ := c.position(field)
+	switch field {
+	case SortDeliveryDate:
+		return position.DeliveryDate
+	case SortTotalAmount:
+		return position.TotalAmount
+	default:
+		return position.CreatedAt
+	}
+}
+
+// position returns an order holding only the cursor's sort value and ID, so
+// it can be compared with compareBy.
+func (c *OrderCursor) position(field string) (*Order, error) {
+	order := &Order{ID: c.ID}
+	var err error
+	switch field {
+	case SortDeliveryDate:
+		order.DeliveryDate, err = time.Parse(time.RFC3339Nano, c.Value)
+	case SortTotalAmount:
+		order.TotalAmount, err = ParseMoney(c.Value)
+	default:
+		order.CreatedAt, err = time.Parse(time.RFC3339Nano, c.Value)
+	}
+	return order, err
+}
+
+func sortKey(field string, descending bool) string {
+	if descending {
+		return "-" + field
+	}
+	return field
+}
+
+// compareBy orders two orders by the field, then by ID so the order is
+// total and a cursor identifies a single position.
+func compareBy(field string, a, b *Order) int {
+	var result int
+	switch field {
+	case SortDeliveryDate:
+		result = a.DeliveryDate.Compare(b.DeliveryDate)
+	case SortTotalAmount:
+		switch {
+		case a.TotalAmount < b.TotalAmount:
+			result = -1
+		case a.TotalAmount > b.TotalAmount:
+			result = 1
+		}
+	default:
+		result = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if result != 0 {
+		return result
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// PageOrders applies a query to an in-memory listing. order returns the order
+// of each item, so records that embed an order can be paged directly.
+func PageOrders[T any](items []T, order func(T) *Order, q OrderQuery) ([]T, string, error) {
+	field, descending, err := q.SortField()
+	if err != nil {
+		return nil, "", err
+	}
+	cursor, err := q.DecodeCursor()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var after *Order
+	if cursor != nil {
+		after, _ = cursor.position(field)
+	}
+
+	matching := make([]T, 0, len(items))
+	for _, item := range items {
+		o := order(item)
+		if !q.Matches(o) {
+			continue
+		}
+		if after != nil {
+			c := compareBy(field, o, after)
+			if (descending && c >= 0) || (!descending && c <= 0) {
+				continue
+			}
+		}
+		matching = append(matching, item)
+	}
+
+	sort.Slice(matching, func(i, j int) bool {
+		c := compareBy(field, order(matching[i]), order(matching[j]))
+		if descending {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	size := q.PageSize()
+	if len(matching) <= size {
+		return matching, "", nil
+	}
+	page := matching[:size]
+	return page, q.CursorAfter(order(page[size-1])), nil
+}
+
+// CollectOrders follows the cursors of a listing and returns every matching
+// order. It is meant for jobs that need the full set, such as comparisons
+// and migrations; request handlers should return pages instead.
+func CollectOrders(fetch func(OrderQuery) (*OrderPage, error), q OrderQuery) ([]Order, error) {
+	q.Limit = MaxPageSize
+	q.Cursor = ""
+
+	var orders []Order
+	for {
+		page, err := fetch(q)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, page.Orders...)
+		if page.NextCursor == "" {
+			return orders, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
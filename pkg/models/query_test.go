@@ -0,0 +1,215 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testOrders() []*Order {
+	base := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	var orders []*Order
+	for i := 0; i < 7; i++ {
+		orders = append(orders, &Order{
+			ID:           fmt.Sprintf("order-%d", i),
+			CustomerID:   fmt.Sprintf("customer-%d", i%2),
+			Status:       StatusPending,
+			TotalAmount:  Money(10000 - i*1000),
+			DeliveryDate: base.AddDate(0, 0, 10+i),
+			// Two orders share a timestamp to exercise the ID tie-break
+			CreatedAt: base.Add(time.Duration(i/2) * time.Hour),
+		})
+	}
+	return orders
+}
+
+func identity(order *Order) *Order { return order }
+
+func TestParseOrderQuery(t *testing.T) {
+	values := url.Values{
+		"customer_id":          {"customer-1"},
+		"status":               {StatusConfirmed},
+		"created_from":         {"2025-06-01T02:00:00+02:00"},
+		"sort":                 {"-delivery_date"},
+		"limit":                {"25"},
+		"product_id":           {"WIDGET-001"},
+		"specifications.color": {"blue"},
+	}
+
+	q, err := ParseOrderQuery(values)
+	if err != nil {
+		t.Fatalf("Expected query to parse, got %v", err)
+	}
+	if !q.CreatedFrom.Equal(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)) || q.CreatedFrom.Location() != time.UTC {
+		t.Errorf("Expected created_from in UTC, got %v", q.CreatedFrom)
+	}
+	if q.PageSize() != 25 {
+		t.Errorf("Expected page size 25, got %d", q.PageSize())
+	}
+
+	roundTrip, err := ParseOrderQuery(q.Values())
+	if err != nil || !reflect.DeepEqual(roundTrip, q) {
+		t.Errorf("Expected Values to round-trip, got %+v %v", roundTrip, err)
+	}
+}
+
+func TestParseOrderQueryRejectsInvalidParameters(t *testing.T) {
+	tests := []url.Values{
+		{"status": {"lost"}},
+		{"created_to": {"yesterday"}},
+		{"limit": {"0"}},
+		{"limit": {"5000"}},
+		{"sort": {"customer_id"}},
+		{"cursor": {"not-a-cursor"}},
+		{"specifications.": {"blue"}},
+	}
+
+	for _, values := range tests {
+		if _, err := ParseOrderQuery(values); err == nil {
+			t.Errorf("Expected %v to be rejected", values)
+		}
+	}
+}
+
+func TestOrderQueryValidate(t *testing.T) {
+	if err := (OrderQuery{}).Validate(); err != nil {
+		t.Errorf("Expected an empty query to be valid, got %v", err)
+	}
+	for _, q := range []OrderQuery{{Status: "lost"}, {Limit: -1}, {Limit: MaxPageSize + 1}, {Sort: "id"}, {Cursor: "?"}} {
+		if err := q.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", q)
+		}
+	}
+}
+
+func TestPageOrdersWalksAllPages(t *testing.T) {
+	orders := testOrders()
+
+	for _, sortBy := range []string{"", "created_at", "-total_amount", "delivery_date"} {
+		q := OrderQuery{Sort: sortBy, Limit: 3}
+		seen := make(map[string]bool)
+		pages := 0
+		for {
+			page, next, err := PageOrders(orders, identity, q)
+			if err != nil {
+				t.Fatalf("sort %q: unexpected error %v", sortBy, err)
+			}
+			pages++
+			for _, order := range page {
+				if seen[order.ID] {
+					t.Errorf("sort %q: order %s returned twice", sortBy, order.ID)
+				}
+				seen[order.ID] = true
+			}
+			if next == "" {
+				break
+			}
+			q.Cursor = next
+		}
+
+		if len(seen) != len(orders) || pages != 3 {
+			t.Errorf("sort %q: expected %d orders in 3 pages, got %d in %d", sortBy, len(orders), len(seen), pages)
+		}
+	}
+}
+
+func TestPageOrdersSortsAndFilters(t *testing.T) {
+	orders := testOrders()
+
+	page, next, err := PageOrders(orders, identity, OrderQuery{CustomerID: "customer-0", Sort: "total_amount"})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if next != "" {
+		t.Errorf("Expected a single page, got cursor %q", next)
+	}
+	expected := []string{"order-6", "order-4", "order-2", "order-0"}
+	if len(page) != len(expected) {
+		t.Fatalf("Expected %d orders, got %d", len(expected), len(page))
+	}
+	for i, id := range expected {
+		if page[i].ID != id {
+			t.Errorf("Position %d: expected %s, got %s", i, id, page[i].ID)
+		}
+	}
+
+	from := time.Date(2025, 6, 12, 0, 0, 0, 0, time.UTC)
+	page, _, _ = PageOrders(orders, identity, OrderQuery{DeliveryFrom: from, DeliveryTo: from.AddDate(0, 0, 2)})
+	if len(page) != 2 {
+		t.Errorf("Expected 2 orders delivered in the range, got %d", len(page))
+	}
+}
+
+func TestCursorIsTiedToSort(t *testing.T) {
+	orders := testOrders()
+
+	_, next, _ := PageOrders(orders, identity, OrderQuery{Sort: "-created_at", Limit: 2})
+	_, err := OrderQuery{Sort: "created_at", Cursor: next}.DecodeCursor()
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected cursor for another sort to be rejected, got %v", err)
+	}
+}
+
+func TestCollectOrdersFollowsCursors(t *testing.T) {
+	orders := testOrders()
+	calls := 0
+	fetch := func(q OrderQuery) (*OrderPage, error) {
+		calls++
+		q.Limit = 2
+		page, next, err := PageOrders(orders, identity, q)
+		if err != nil {
+			return nil, err
+		}
+		result := &OrderPage{NextCursor: next}
+		for _, order := range page {
+			result.Orders = append(result.Orders, *order)
+		}
+		return result, nil
+	}
+
+	collected, err := CollectOrders(fetch, OrderQuery{Status: StatusPending})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(collected) != len(orders) || calls != 4 {
+		t.Errorf("Expected %d orders in 4 calls, got %d in %d", len(orders), len(collected), calls)
+	}
+}
+
+func TestOrderQueryMatchesItems(t *testing.T) {
+	order := &Order{Items: []OrderItem{
+		{ProductID: "WIDGET-001", Specifications: map[string]string{"color": "red"}},
+		{ProductID: "WIDGET-002", Specifications: map[string]string{"color": "blue", "size": "large"}},
+	}}
+
+	tests := []struct {
+		query   OrderQuery
+		matches bool
+	}{
+		{OrderQuery{ProductID: "WIDGET-002"}, true},
+		{OrderQuery{Specifications: map[string]string{"color": "blue"}}, true},
+		{OrderQuery{ProductID: "WIDGET-002", Specifications: map[string]string{"color": "blue", "size": "large"}}, true},
+		// Both filters have to match the same item
+		{OrderQuery{ProductID: "WIDGET-001", Specifications: map[string]string{"color": "blue"}}, false},
+		{OrderQuery{Specifications: map[string]string{"material": "steel"}}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.query.Matches(order); got != tt.matches {
+			t.Errorf("Expected %+v to match %v, got %v", tt.query, tt.matches, got)
+		}
+	}
+}
+
+func TestParseOrderSearchRequiresItemFilter(t *testing.T) {
+	if _, err := ParseOrderSearch(url.Values{"customer_id": {"customer-1"}}); err == nil {
+		t.Error("Expected a search without item filters to be rejected")
+	}
+	q, err := ParseOrderSearch(url.Values{"specifications.color": {"blue"}})
+	if err != nil || q.Specifications["color"] != "blue" {
+		t.Errorf("Expected a specification search, got %+v %v", q, err)
+	}
+}
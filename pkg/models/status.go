@@ -0,0 +1,144 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Order statuses. An order moves forward through pending, confirmed, picked,
+// shipped and delivered; it can be cancelled until it ships, and rejected
+// while still pending.
+const (
+	StatusPending   = "pending"
+	StatusConfirmed = "confirmed"
+	StatusPicked    = "picked"
+	StatusShipped   = "shipped"
+	StatusDelivered = "delivered"
+	StatusCancelled = "cancelled"
+	StatusRejected  = "rejected"
+)
+
+var statusTransitions = map[string][]string{
+	StatusPending:   {StatusConfirmed, StatusCancelled, StatusRejected},
+	StatusConfirmed: {StatusPicked, StatusCancelled},
+	StatusPicked:    {StatusShipped, StatusCancelled},
+	StatusShipped:   {StatusDelivered},
+	StatusDelivered: {},
+	StatusCancelled: {},
+	StatusRejected:  {},
+}
+
+var (
+	ErrUnknownStatus     = errors.New("unknown order status")
+	ErrInvalidTransition = errors.New("invalid order status transition")
+)
+
+// TransitionError describes a status change the lifecycle does not allow.
+type TransitionError struct {
+	From    string
+	To      string
+	Allowed []string
+}
+
+func (e *TransitionError) Error() string {
+	if len(e.Allowed) == 0 {
+		return fmt.Sprintf("order status %q is final and cannot change to %q", e.From, e.To)
+	}
+	return fmt.Sprintf("order status cannot change from %q to %q (allowed: %v)", e.From, e.To, e.Allowed)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// IsValidStatus reports whether status is part of the order lifecycle.
+func IsValidStatus(status string) bool {
+	_, exists := statusTransitions[status]
+	return exists
+}
+
+// IsFinalStatus reports whether no further transition is allowed.
+func IsFinalStatus(status string) bool {
+	return IsValidStatus(status) && len(statusTransitions[status]) == 0
+}
+
+// AllowedTransitions lists the statuses an order in status can move to.
+func AllowedTransitions(status string) []string {
+	return append([]string(nil), statusTransitions[status]...)
+}
+
+// ValidateTransition returns nil if an order may change from one status to
+// the other, ErrUnknownStatus for statuses outside the lifecycle, and a
+// *TransitionError otherwise.
+func ValidateTransition(from, to string) error {
+	if !IsValidStatus(from) {
+		return fmt.Errorf("%w: %q", ErrUnknownStatus, from)
+	}
+	if !IsValidStatus(to) {
+		return fmt.Errorf("%w: %q", ErrUnknownStatus, to)
+	}
+
+	for _, allowed := range statusTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return &TransitionError{From: from, To: to, Allowed: AllowedTransitions(from)}
+}
+
+// Cancellation reason codes accepted by the cancel endpoints.
+const (
+	CancelReasonCustomerRequest = "customer_request"
+	CancelReasonOutOfStock      = "out_of_stock"
+	CancelReasonPaymentFailed   = "payment_failed"
+	CancelReasonDuplicate       = "duplicate_order"
+	CancelReasonFraudSuspected  = "fraud_suspected"
+	CancelReasonOther           = "other"
+)
+
+var cancellationReasons = []string{
+	CancelReasonCustomerRequest,
+	CancelReasonOutOfStock,
+	CancelReasonPaymentFailed,
+	CancelReasonDuplicate,
+	CancelReasonFraudSuspected,
+	CancelReasonOther,
+}
+
+// CancellationReasons lists the accepted reason codes.
+func CancellationReasons() []string {
+	return append([]string(nil), cancellationReasons...)
+}
+
+func IsValidCancellationReason(code string) bool {
+	for _, reason := range cancellationReasons {
+		if reason == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Cancellation states. A cancellation is requested by the Order Service and
+// confirmed or rejected by SAP.
+const (
+	CancellationRequested = "requested"
+	CancellationConfirmed = "confirmed"
+	CancellationRejected  = "rejected"
+)
+
+// CancellationRequest is the body of the cancel endpoints.
+type CancellationRequest struct {
+	ReasonCode string `json:"reason_code"`
+	Note       string `json:"note,omitempty"`
+}
+
+// Cancellation tracks a cancel request for an order.
+type Cancellation struct {
+	ReasonCode  string    `json:"reason_code"`
+	Note        string    `json:"note,omitempty"`
+	State       string    `json:"state"`
+	Message     string    `json:"message,omitempty"`
+	RequestedAt time.Time `json:"requested_at"`
+}
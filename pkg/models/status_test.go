@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateTransitionHappyPath(t *testing.T) {
+	path := []string{StatusPending, StatusConfirmed, StatusPicked, StatusShipped, StatusDelivered}
+	for i := 0; i < len(path)-1; i++ {
+		if err := ValidateTransition(path[i], path[i+1]); err != nil {
+			t.Errorf("Expected %s -> %s to be allowed, got %v", path[i], path[i+1], err)
+		}
+	}
+}
+
+func TestValidateTransitionRejectsIllegalChanges(t *testing.T) {
+	tests := []struct{ from, to string }{
+		{StatusPending, StatusShipped},
+		{StatusShipped, StatusCancelled},
+		{StatusDelivered, StatusPending},
+		{StatusCancelled, StatusConfirmed},
+		{StatusConfirmed, StatusRejected},
+		{StatusPicked, StatusPicked},
+	}
+
+	for _, tt := range tests {
+		err := ValidateTransition(tt.from, tt.to)
+		if !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("Expected %s -> %s to be rejected, got %v", tt.from, tt.to, err)
+		}
+		var transitionErr *TransitionError
+		if !errors.As(err, &transitionErr) || transitionErr.From != tt.from || transitionErr.To != tt.to {
+			t.Errorf("Expected a TransitionError for %s -> %s, got %v", tt.from, tt.to, err)
+		}
+	}
+}
+
+func TestValidateTransitionUnknownStatus(t *testing.T) {
+	if err := ValidateTransition(StatusPending, "lost"); !errors.Is(err, ErrUnknownStatus) {
+		t.Errorf("Expected unknown status error, got %v", err)
+	}
+	if err := ValidateTransition("", StatusConfirmed); !errors.Is(err, ErrUnknownStatus) {
+		t.Errorf("Expected unknown status error, got %v", err)
+	}
+}
+
+func TestFinalStatuses(t *testing.T) {
+	for _, status := range []string{StatusDelivered, StatusCancelled, StatusRejected} {
+		if !IsFinalStatus(status) {
+			t.Errorf("Expected %s to be final", status)
+		}
+	}
+	if IsFinalStatus(StatusShipped) {
+		t.Error("Expected shipped not to be final")
+	}
+}
+
+func TestCancellationReasons(t *testing.T) {
+	for _, reason := range CancellationReasons() {
+		if !IsValidCancellationReason(reason) {
+			t.Errorf("Expected %s to be a valid reason", reason)
+		}
+	}
+	if IsValidCancellationReason("changed_mind") || IsValidCancellationReason("") {
+		t.Error("Expected unknown reasons to be rejected")
+	}
+}
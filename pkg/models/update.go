@@ -0,0 +1,109 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fields an OrderUpdate can change, as listed in order.updated events.
+const (
+	FieldDeliveryDate = "delivery_date"
+	FieldItems        = "items"
+	FieldTotalAmount  = "total_amount"
+)
+
+// OrderUpdate is the body of PUT /orders/{id}. Omitted fields are left
+// unchanged; items, when present, replace all items of the order.
+type OrderUpdate struct {
+	DeliveryDate *time.Time  `json:"delivery_date,omitempty"`
+	Items        []OrderItem `json:"items,omitempty"`
+}
+
+// IsEmpty reports whether the update changes nothing.
+func (u OrderUpdate) IsEmpty() bool {
+	return u.DeliveryDate == nil && u.Items == nil
+}
+
+// Apply changes the order and returns the fields that actually changed. The
+// total amount follows the items.
+func (u OrderUpdate) Apply(order *Order) []string {
+	var changed []string
+	if u.DeliveryDate != nil && !u.DeliveryDate.Equal(order.DeliveryDate) {
+		order.DeliveryDate = *u.DeliveryDate
+		changed = append(changed, FieldDeliveryDate)
+	}
+	if u.Items != nil && !sameItems(u.Items, order.Items) {
+		order.Items = append([]OrderItem(nil), u.Items...)
+		changed = append(changed, FieldItems)
+
+		var total Money
+		for _, item := range order.Items {
+			total += item.UnitPrice.Times(item.Quantity)
+		}
+		if total != order.TotalAmount {
+			order.TotalAmount = total
+			changed = append(changed, FieldTotalAmount)
+		}
+	}
+	return changed
+}
+
+func sameItems(a, b []OrderItem) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ProductID != b[i].ProductID || a[i].Quantity != b[i].Quantity || a[i].UnitPrice != b[i].UnitPrice {
+			return false
+		}
+		if len(a[i].Specifications) != len(b[i].Specifications) {
+			return false
+		}
+		for key, value := range a[i].Specifications {
+			if other, ok := b[i].Specifications[key]; !ok || other != value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ErrOrderNotModifiable is returned for updates to an order that is already
+// being picked or has left the pending and confirmed statuses.
+var ErrOrderNotModifiable = errors.New("order can no longer be modified")
+
+// CheckModifiable returns nil while an order's delivery date and items may
+// still change: before it is picked.
+func CheckModifiable(status string) error {
+	if status == StatusPending || status == StatusConfirmed {
+		return nil
+	}
+	return fmt.Errorf("%w in status %q", ErrOrderNotModifiable, status)
+}
+
+// ETag formats an order version as a strong entity tag.
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ParseIfMatch reads the version of an If-Match header. A wildcard matches
+// any version and is returned as 0. Anything else that is not an ETag from
+// this service can never match and returns an error.
+func ParseIfMatch(header string) (int, error) {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return 0, nil
+	}
+	unquoted, err := strconv.Unquote(header)
+	if err != nil {
+		return 0, fmt.Errorf("invalid If-Match header %q", header)
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("invalid If-Match header %q", header)
+	}
+	return version, nil
+}
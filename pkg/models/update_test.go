@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestOrderUpdateApply(t *testing.T) {
+	delivery := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	order := &Order{
+		Items:        []OrderItem{{ProductID: "P-1", Quantity: 2, UnitPrice: 500}},
+		TotalAmount:  1000,
+		DeliveryDate: delivery,
+	}
+
+	same := OrderUpdate{DeliveryDate: &delivery, Items: []OrderItem{{ProductID: "P-1", Quantity: 2, UnitPrice: 500}}}
+	if changed := same.Apply(order); len(changed) != 0 {
+		t.Errorf("Expected no changes, got %v", changed)
+	}
+
+	later := delivery.AddDate(0, 0, 7)
+	update := OrderUpdate{DeliveryDate: &later, Items: []OrderItem{{ProductID: "P-1", Quantity: 3, UnitPrice: 10}}}
+	changed := update.Apply(order)
+	if fmt.Sprint(changed) != "[delivery_date items total_amount]" {
+		t.Errorf("Expected all fields to change, got %v", changed)
+	}
+	if order.TotalAmount != 30 || !order.DeliveryDate.Equal(later) {
+		t.Errorf("Expected total 0.30 and the new date, got %v %v", order.TotalAmount, order.DeliveryDate)
+	}
+}
+
+func TestCheckModifiable(t *testing.T) {
+	for _, status := range []string{StatusPending, StatusConfirmed} {
+		if err := CheckModifiable(status); err != nil {
+			t.Errorf("Expected %s to be modifiable, got %v", status, err)
+		}
+	}
+	for _, status := range []string{StatusPicked, StatusShipped, StatusCancelled} {
+		if err := CheckModifiable(status); !errors.Is(err, ErrOrderNotModifiable) {
+			t.Errorf("Expected %s to be rejected, got %v", status, err)
+		}
+	}
+}
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		header  string
+		version int
+		valid   bool
+	}{
+		{`"3"`, 3, true},
+		{"*", 0, true},
+		{`W/"3"`, 0, false},
+		{"3", 0, false},
+		{`"0"`, 0, false},
+		{`"abc"`, 0, false},
+	}
+
+	for _, tt := range tests {
+		version, err := ParseIfMatch(tt.header)
+		if (err == nil) != tt.valid || version != tt.version {
+			t.Errorf("ParseIfMatch(%q) = %d, %v", tt.header, version, err)
+		}
+	}
+	if ETag(3) != `"3"` {
+		t.Errorf("Expected ETag \"3\", got %s", ETag(3))
+	}
+}
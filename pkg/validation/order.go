@@ -0,0 +1,122 @@
+// Package validation checks orders before they are accepted by the proxy or
+// the Order Service.
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jogardn/strangler-demo/pkg/models"
+)
+
+// Error codes returned in FieldError.Code.
+const (
+	CodeRequired   = "required"
+	CodeTooLong    = "too_long"
+	CodeMinItems   = "min_items"
+	CodeOutOfRange = "out_of_range"
+	CodeMismatch   = "mismatch"
+	CodeInvalid    = "invalid"
+)
+
+const (
+	// maxIDLength matches the VARCHAR(255) columns of the orders schema
+	maxIDLength = 255
+	// maxAmount is the largest value NUMERIC(18,2) can hold
+	maxAmount models.Money = 999_999_999_999_999_999
+)
+
+// FieldError describes one invalid field. Path uses JSON field names, e.g.
+// "items[1].quantity".
+type FieldError struct {
+	Path    string `json:"path"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors is the list of problems found in a request.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fmt.Sprintf("%s: %s", fieldErr.Path, fieldErr.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *Errors) add(path, code, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Path: path, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// ValidateOrder checks every field of the order and returns all problems
+// found. An empty result means the order is valid.
+func ValidateOrder(order *models.Order) Errors {
+	var errs Errors
+
+	validateID(&errs, "id", order.ID)
+	validateID(&errs, "customer_id", order.CustomerID)
+
+	// An empty status is filled in with pending by the proxy
+	if order.Status != "" && !models.IsValidStatus(order.Status) {
+		errs.add("status", CodeInvalid, "status %q is not a known order status", order.Status)
+	}
+
+	// An empty currency is filled in with models.DefaultCurrency
+	if order.Currency != "" && !models.IsCurrencyCode(order.Currency) {
+		errs.add("currency", CodeInvalid, "currency %q is not a three-letter ISO 4217 code", order.Currency)
+	}
+
+	if order.DeliveryDate.IsZero() {
+		errs.add("delivery_date", CodeRequired, "delivery_date is required")
+	}
+
+	if len(order.Items) == 0 {
+		errs.add("items", CodeMinItems, "order must contain at least one item")
+	}
+
+	var itemsTotal models.Money
+	itemsTooLarge := false
+	for i, item := range order.Items {
+		path := fmt.Sprintf("items[%d]", i)
+		validateID(&errs, path+".product_id", item.ProductID)
+
+		if item.Quantity <= 0 {
+			errs.add(path+".quantity", CodeOutOfRange, "quantity must be greater than 0, got %d", item.Quantity)
+		}
+		if item.UnitPrice < 0 || item.UnitPrice > maxAmount {
+			errs.add(path+".unit_price", CodeOutOfRange, "unit_price must be between 0 and %s, got %s", maxAmount, item.UnitPrice)
+		}
+
+		switch {
+		case item.Quantity <= 0 || item.UnitPrice < 0 || item.UnitPrice > maxAmount:
+			// Reported above and left out of the item total
+		case item.UnitPrice > 0 && models.Money(item.Quantity) > (maxAmount-itemsTotal)/item.UnitPrice:
+			// No valid total is this large, and summing on could overflow
+			itemsTooLarge = true
+		default:
+			itemsTotal += item.UnitPrice.Times(item.Quantity)
+		}
+	}
+
+	switch {
+	case order.TotalAmount < 0 || order.TotalAmount > maxAmount:
+		errs.add("total_amount", CodeOutOfRange, "total_amount must be between 0 and %s, got %s", maxAmount, order.TotalAmount)
+	case itemsTooLarge:
+		errs.add("total_amount", CodeMismatch, "total_amount %s does not match the item total, which exceeds %s", order.TotalAmount, maxAmount)
+	case len(order.Items) > 0 && order.TotalAmount != itemsTotal:
+		errs.add("total_amount", CodeMismatch, "total_amount %s does not match the item total %s", order.TotalAmount, itemsTotal)
+	}
+
+	return errs
+}
+
+func validateID(errs *Errors, path, value string) {
+	switch {
+	case strings.TrimSpace(value) == "":
+		errs.add(path, CodeRequired, "%s is required", path)
+	case len(value) > maxIDLength:
+		errs.add(path, CodeTooLong, "%s must be at most %d characters", path, maxIDLength)
+	}
+}
+
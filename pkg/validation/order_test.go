@@ -0,0 +1,148 @@
+package validation
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jogardn/strangler-demo/pkg/models"
+)
+
+func validOrder() *models.Order {
+	return &models.Order{
+		ID:         "order-1",
+		CustomerID: "customer-1",
+		Items: []models.OrderItem{
+			{ProductID: "WIDGET-001", Quantity: 10, UnitPrice: 2599},
+			{ProductID: "COMPONENT-042", Quantity: 5, UnitPrice: 14999},
+		},
+		TotalAmount:  100985,
+		DeliveryDate: time.Now().Add(24 * time.Hour),
+	}
+}
+
+func hasError(errs Errors, path, code string) bool {
+	for _, err := range errs {
+		if err.Path == path && err.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidOrder(t *testing.T) {
+	if errs := ValidateOrder(validOrder()); len(errs) != 0 {
+		t.Fatalf("Expected no errors, got %v", errs)
+	}
+}
+
+func TestEmptyOrderReportsAllFields(t *testing.T) {
+	errs := ValidateOrder(&models.Order{})
+
+	for _, expected := range []struct{ path, code string }{
+		{"id", CodeRequired},
+		{"customer_id", CodeRequired},
+		{"delivery_date", CodeRequired},
+		{"items", CodeMinItems},
+	} {
+		if !hasError(errs, expected.path, expected.code) {
+			t.Errorf("Expected %s error for %s, got %v", expected.code, expected.path, errs)
+		}
+	}
+}
+
+func TestInvalidItems(t *testing.T) {
+	order := validOrder()
+	order.Items[0].ProductID = " "
+	order.Items[1].Quantity = -1
+	order.Items[1].UnitPrice = -500
+	order.TotalAmount = 25990
+
+	errs := ValidateOrder(order)
+
+	if !hasError(errs, "items[0].product_id", CodeRequired) {
+		t.Errorf("Expected product_id error, got %v", errs)
+	}
+	if !hasError(errs, "items[1].quantity", CodeOutOfRange) {
+		t.Errorf("Expected quantity error, got %v", errs)
+	}
+	if !hasError(errs, "items[1].unit_price", CodeOutOfRange) {
+		t.Errorf("Expected unit_price error, got %v", errs)
+	}
+}
+
+func TestTotalAmountMismatch(t *testing.T) {
+	order := validOrder()
+	order.TotalAmount = 100000
+
+	errs := ValidateOrder(order)
+	if !hasError(errs, "total_amount", CodeMismatch) {
+		t.Fatalf("Expected total_amount mismatch, got %v", errs)
+	}
+}
+
+func TestTotalAmountToleratesFloatRounding(t *testing.T) {
+	// 3 * 0.1 is not 0.3 in binary floating point, but amounts are read
+	// as exact decimals
+	order := validOrder()
+	body := `{"items": [{"product_id": "P", "quantity": 3, "unit_price": 0.1}], "total_amount": 0.3}`
+	if err := json.Unmarshal([]byte(body), order); err != nil {
+		t.Fatalf("Failed to decode order: %v", err)
+	}
+
+	if errs := ValidateOrder(order); len(errs) != 0 {
+		t.Fatalf("Expected no errors, got %v", errs)
+	}
+}
+
+func TestAmountsAboveDecimal10AreValid(t *testing.T) {
+	order := validOrder()
+	order.Items = []models.OrderItem{{ProductID: "P", Quantity: 2, UnitPrice: 7_500_000_000_00}}
+	order.TotalAmount = 15_000_000_000_00
+
+	if errs := ValidateOrder(order); len(errs) != 0 {
+		t.Fatalf("Expected no errors, got %v", errs)
+	}
+}
+
+func TestItemTotalTooLargeToSum(t *testing.T) {
+	order := validOrder()
+	order.Items = []models.OrderItem{{ProductID: "P", Quantity: 1 << 30, UnitPrice: maxAmount}}
+	order.TotalAmount = maxAmount
+
+	if errs := ValidateOrder(order); !hasError(errs, "total_amount", CodeMismatch) {
+		t.Fatalf("Expected total_amount mismatch, got %v", errs)
+	}
+}
+
+func TestTooLongID(t *testing.T) {
+	order := validOrder()
+	order.CustomerID = strings.Repeat("c", 256)
+
+	if errs := ValidateOrder(order); !hasError(errs, "customer_id", CodeTooLong) {
+		t.Fatalf("Expected too_long error, got %v", errs)
+	}
+}
+
+func TestUnknownStatus(t *testing.T) {
+	order := validOrder()
+	order.Status = "lost"
+
+	if errs := ValidateOrder(order); !hasError(errs, "status", CodeInvalid) {
+		t.Fatalf("Expected invalid status error, got %v", errs)
+	}
+}
+
+func TestInvalidCurrency(t *testing.T) {
+	order := validOrder()
+	order.Currency = "EUR"
+	if errs := ValidateOrder(order); len(errs) != 0 {
+		t.Fatalf("Expected no errors, got %v", errs)
+	}
+
+	order.Currency = "eur"
+	if errs := ValidateOrder(order); !hasError(errs, "currency", CodeInvalid) {
+		t.Fatalf("Expected invalid currency error, got %v", errs)
+	}
+}
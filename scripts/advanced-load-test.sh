@@ -254,7 +254,8 @@ generate_realistic_order() {
     local quantity=$((order_num % 5 + 1))
     local base_price=$((order_num % 100 + 20))
     local unit_price="${base_price}.99"
-    local total_amount=$(echo "$quantity * $base_price" | bc).99
+    local total_cents=$((quantity * (base_price * 100 + 99)))
+    local total_amount=$(printf "%d.%02d" $((total_cents / 100)) $((total_cents % 100)))
     
     # Realistic specifications
     local colors=("red" "blue" "green" "yellow" "black" "white")
@@ -47,7 +47,7 @@ create_test_orders() {
                 \"size\": \"medium\"
               }
             }],
-            \"total_amount\": $(echo "$i * 10.99" | bc),
+            \"total_amount\": $(echo "$i * $i * 10.99" | bc),
             \"delivery_date\": \"2025-07-01T00:00:00Z\"
           }" > /dev/null
         
@@ -66,11 +66,11 @@ compare_counts() {
     echo "-------------------------"
     
     # Get order service count
-    ORDER_SERVICE_RESPONSE=$(curl -s http://localhost:8081/orders)
+    ORDER_SERVICE_RESPONSE=$(curl -s "http://localhost:8081/orders?limit=1000")
     ORDER_SERVICE_COUNT=$(echo "$ORDER_SERVICE_RESPONSE" | jq -r '.count // 0')
     
     # Get SAP mock count
-    SAP_RESPONSE=$(curl -s http://localhost:8082/orders)
+    SAP_RESPONSE=$(curl -s "http://localhost:8082/orders?limit=1000")
     SAP_COUNT=$(echo "$SAP_RESPONSE" | jq -r '.count // 0')
     
     echo "Order Service: $ORDER_SERVICE_COUNT orders"
@@ -43,7 +43,7 @@ for i in {1..3}; do
             \"priority\": \"$([ $i -eq 1 ] && echo 'high' || echo 'normal')\"
           }
         }],
-        \"total_amount\": $((i * 2 * (i * 15 + 5))).99,
+        \"total_amount\": $(printf "%d.%02d" $((i * 2 * ((i * 15 + 5) * 100 + 99) / 100)) $((i * 2 * ((i * 15 + 5) * 100 + 99) % 100))),
         \"delivery_date\": \"2025-07-0${i}T00:00:00Z\"
       }")
     
@@ -67,12 +67,12 @@ echo ""
 
 # Check Order Service
 echo -n "Order Service count: "
-ORDER_SERVICE_COUNT=$(curl -s http://localhost:8081/orders | jq -r '.count')
+ORDER_SERVICE_COUNT=$(curl -s "http://localhost:8081/orders?limit=1000" | jq -r '.count')
 echo -e "${CYAN}$ORDER_SERVICE_COUNT orders${NC}"
 
 # Check SAP Mock
 echo -n "SAP Mock count: "
-SAP_COUNT=$(curl -s http://localhost:8082/orders | jq -r '.count')
+SAP_COUNT=$(curl -s "http://localhost:8082/orders?limit=1000" | jq -r '.count')
 echo -e "${CYAN}$SAP_COUNT orders${NC}"
 
 if [ "$ORDER_SERVICE_COUNT" -eq "$SAP_COUNT" ]; then
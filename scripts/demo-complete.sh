@@ -96,7 +96,7 @@ create_sample_orders() {
             }
         }
     ],
-    "total_amount": $((20 + i * 10)).99,
+    "total_amount": $(printf "%d.%02d" $(((i % 5 + 1) * ((10 + i * 5) * 100 + 99) / 100)) $(((i % 5 + 1) * ((10 + i * 5) * 100 + 99) % 100))),
     "delivery_date": "$(date -d "+$((i * 7)) days" -Iseconds)",
     "status": "pending"
 }
@@ -135,8 +135,8 @@ check_service_health() {
 show_data_comparison() {
     log "Comparing data between Order Service and SAP..."
     
-    local os_count=$(curl -s "$ORDER_SERVICE_URL/orders" | jq '.count // 0' 2>/dev/null || echo "0")
-    local sap_count=$(curl -s "$SAP_URL/orders" | jq 'length // 0' 2>/dev/null || echo "0")
+    local os_count=$(curl -s "$ORDER_SERVICE_URL/orders?limit=1000" | jq '.count // 0' 2>/dev/null || echo "0")
+    local sap_count=$(curl -s "$SAP_URL/orders?limit=1000" | jq 'length // 0' 2>/dev/null || echo "0")
     
     echo "📊 Data Comparison:"
     echo "   Order Service: $os_count orders"
@@ -234,8 +234,8 @@ main() {
     echo "=================="
     
     # Get final counts
-    local final_os_count=$(curl -s "$ORDER_SERVICE_URL/orders" | jq '.count // 0' 2>/dev/null || echo "0")
-    local final_sap_count=$(curl -s "$SAP_URL/orders" | jq 'length // 0' 2>/dev/null || echo "0")
+    local final_os_count=$(curl -s "$ORDER_SERVICE_URL/orders?limit=1000" | jq '.count // 0' 2>/dev/null || echo "0")
+    local final_sap_count=$(curl -s "$SAP_URL/orders?limit=1000" | jq 'length // 0' 2>/dev/null || echo "0")
     
     echo "• Total Orders Created: $((final_os_count))"
     echo "• Order Service Orders: $final_os_count"
@@ -90,6 +90,8 @@ generate_order() {
     local customer_num=$((order_id % 100 + 1))
     local product_count=$((order_id % 3 + 1))
     local base_price=$((order_id % 50 + 10))
+    # total_amount must equal quantity * unit_price (checked by order validation)
+    local total_cents=$((product_count * 2 * (base_price * 100 + 99)))
     
     cat <<EOF
 {
@@ -108,7 +110,7 @@ generate_order() {
       }
     }
   ],
-  "total_amount": $((product_count * 2 * base_price)).99,
+  "total_amount": $(printf "%d.%02d" $((total_cents / 100)) $((total_cents % 100))),
   "delivery_date": "2025-07-$(printf "%02d" $((order_id % 28 + 1)))T00:00:00Z"
 }
 EOF